@@ -1,18 +1,33 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/jwtauth"
 	"github.com/melkzsiqueira/water-gas-measurement/configs"
 	_ "github.com/melkzsiqueira/water-gas-measurement/docs"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/detection"
 	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
 	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
 	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/gemini"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/logger"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/metrics"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/ocrcache"
 	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/storage"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/tracing"
 	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/webserver/handlers"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/webserver/middlewares"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/worker"
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/imaging"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -39,58 +54,281 @@ func main() {
 		panic(err)
 	}
 
-	db, err := gorm.Open(postgres.Open(config.DBDSN), &gorm.Config{})
+	log, err := logger.New(config.LogLevel, os.Stdout)
+	if err != nil {
+		panic(err)
+	}
+	slog.SetDefault(log)
+
+	shutdownTracing, err := tracing.Setup(config.TracingExporter, config.TracingEndpoint, config.TracingService, context.Background())
+	if err != nil {
+		panic(err)
+	}
+
+	db, err := gorm.Open(postgres.Open(config.DBDSN), &gorm.Config{
+		Logger: logger.NewGorm(200 * time.Millisecond),
+	})
+	if err != nil {
+		panic(err)
+	}
+	err = db.Use(tracing.NewGormPlugin())
+	if err != nil {
+		panic(err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.User{}, &entity.AuditEvent{}, &entity.OCRCacheEntry{}, &entity.IdempotencyKey{}, &entity.Tariff{}, &entity.TariffTier{}, &entity.Invoice{}, &entity.InvoiceLine{}, &entity.Alert{})
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic(err)
+	}
+	err = metrics.RegisterDB(sqlDB, config.DBName)
 	if err != nil {
 		panic(err)
 	}
-	db.AutoMigrate(&entity.Measurement{}, &entity.User{})
 	userDB := database.NewUser(db)
 	userHandler := handlers.NewUserHandler(userDB)
 	measurementDB := database.NewMeasurement(db)
+	unitOfWork := database.NewUnitOfWork(db)
+	auditEventDB := database.NewAuditEvent(db)
+	auditHandler := handlers.NewAuditHandler(auditEventDB)
+	tariffDB := database.NewTariff(db)
+	tariffHandler := handlers.NewTariffHandler(tariffDB)
+	invoiceDB := database.NewInvoice(db)
+	invoiceHandler := handlers.NewInvoiceHandler(invoiceDB, tariffDB, measurementDB)
+	analyticsHandler := handlers.NewAnalyticsHandler(database.NewAnalytics(db))
+	alertDB := database.NewAlert(db)
+	alertHandler := handlers.NewAlertHandler(alertDB)
+	idempotencyHandler := handlers.NewIdempotencyHandler(database.NewIdempotencyKey(db), config.IdempotencyTTL, config.WriteTimeout)
 
 	measurementStorage, err := storage.NewStorage(config.StorageName, config.StorageAPIKey, config.StorageAPISecret)
 	if err != nil {
 		panic(err)
 	}
 
-	gemini, err := gemini.NewGeminiClient(config.GeminiKey, config.GeminiModel)
+	prompts, err := gemini.NewPrompts(config.PromptsDir, config.Prompt, config.PromptCandidate, config.PromptPercent)
+	if err != nil {
+		panic(err)
+	}
+
+	gemini, err := gemini.NewGeminiClient(config.GeminiKey, config.GeminiModel, prompts, gemini.Options{
+		Timeout:          config.GeminiTimeout,
+		Budget:           config.GeminiBudget,
+		MaxRetries:       config.GeminiRetries,
+		Backoff:          config.GeminiBackoff,
+		BreakerThreshold: config.GeminiThreshold,
+		BreakerCooldown:  config.GeminiCooldown,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	ocrCache, err := ocrcache.NewStore(config.OCRCache, db, config.OCRCacheSize, config.OCRCacheTTL)
+	if err != nil {
+		panic(err)
+	}
+
+	pool := worker.NewPool(config.Workers, config.WorkerQueueSize)
+
+	pipeline, err := imaging.NewPipeline(imaging.Options{
+		MaxDimension: config.ImageMaxSize,
+		MaxPixels:    config.ImageMaxPixels,
+		Format:       config.ImageFormat,
+		Quality:      config.ImageQuality,
+		Normalize:    config.ImageNormalize,
+	})
 	if err != nil {
 		panic(err)
 	}
 
-	measurementHandler := handlers.NewMeasurementHandler(measurementDB, measurementStorage, gemini)
+	instrumentedStorage := metrics.NewStorage(tracing.NewStorage(measurementStorage))
+	statementHandler := handlers.NewStatementHandler(userDB, invoiceDB, measurementDB, instrumentedStorage)
+
+	anomalyThresholds := map[string]detection.Thresholds{
+		entity.MeasurementTypeWater: {Warning: config.WaterAnomalyWarn, Critical: config.WaterAnomalyCrit, MinHistory: config.AnomalyMinHist, Lookback: config.AnomalyLookback},
+		entity.MeasurementTypeGas:   {Warning: config.GasAnomalyWarn, Critical: config.GasAnomalyCrit, MinHistory: config.AnomalyMinHist, Lookback: config.AnomalyLookback},
+	}
+	for _, thresholds := range anomalyThresholds {
+		if err := thresholds.Validate(); err != nil {
+			panic(err)
+		}
+	}
+
+	measurementHandler := handlers.NewMeasurementHandler(
+		measurementDB,
+		alertDB,
+		unitOfWork,
+		instrumentedStorage,
+		ocrcache.NewGemini(metrics.NewGemini(tracing.NewGemini(gemini)), ocrCache, config.GeminiModel, prompts),
+		pool,
+		pipeline,
+		handlers.MeasurementOptions{
+			MinConfidence:     config.OCRMinConfidence,
+			KeepOriginal:      config.KeepOriginal,
+			DuplicateDistance: config.DuplicateMaxDist,
+			DuplicateLookback: config.DuplicateWindow,
+			ImportChunkSize:   config.ImportChunkSize,
+			ImportTimeout:     config.ImportTimeout,
+			ExportTimeout:     config.ExportTimeout,
+			Anomaly:           anomalyThresholds,
+		},
+	)
+
+	// Third-party checks are opt-in: an outage of a shared provider would
+	// otherwise take every replica out of rotation at once.
+	healthChecks := []handlers.HealthCheck{
+		{Name: "database", Check: sqlDB.PingContext},
+	}
+	if config.HealthCheckStore {
+		healthChecks = append(healthChecks, handlers.HealthCheck{Name: "storage", Check: measurementStorage.Ping, CacheFor: config.HealthCacheTTL})
+	}
+	if config.HealthCheckOCR {
+		healthChecks = append(healthChecks, handlers.HealthCheck{Name: "ocr", Check: gemini.Ping, CacheFor: config.HealthCacheTTL})
+	}
+	healthHandler := handlers.NewHealthHandler(config.HealthTimeout, healthChecks...)
+
+	proxies, err := middlewares.ParseProxies(config.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
 
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(tracing.Middleware)
+	r.Use(middleware.RequestID)
+	r.Use(middlewares.RealIP(proxies))
+	r.Use(logger.Middleware(log))
+	r.Use(metrics.Middleware)
 	r.Use(middleware.WithValue("token", config.TokenAuth))
 	r.Use(middleware.WithValue("token_expires_in", config.JWTExpiresIn))
 	r.Use(middleware.Recoverer)
+	r.NotFound(handlers.NotFound)
+	r.MethodNotAllowed(handlers.MethodNotAllowed)
+	r.Handle("/metrics", metrics.Handler())
+	r.Get("/healthz", healthHandler.Liveness)
+	r.Get("/readyz", healthHandler.Readiness)
 	r.Route("/"+config.APIVersion, func(r chi.Router) {
 		r.Route("/measurements", func(r chi.Router) {
 			r.Use(jwtauth.Verifier(config.TokenAuth))
 			r.Use(jwtauth.Authenticator)
+			r.Use(middlewares.Audit)
 
-			r.Post("/", measurementHandler.CreateMeasurement)
+			r.With(idempotencyHandler.Middleware).Post("/", measurementHandler.CreateMeasurement)
 			r.Get("/", measurementHandler.GetMeasurements)
+			r.Post("/import", measurementHandler.ImportMeasurements)
+			r.Get("/export", measurementHandler.ExportMeasurements)
 
 			r.Route("/{id}", func(r chi.Router) {
 				r.Get("/", measurementHandler.GetMeasurement)
 				r.Put("/", measurementHandler.UpdateMeasurement)
+				r.Patch("/", measurementHandler.PatchMeasurement)
 				r.Delete("/", measurementHandler.DeleteMeasurement)
 				r.Get("/image", measurementHandler.GetMeasurementImage)
 			})
 		})
+		r.Route("/audit", func(r chi.Router) {
+			r.Use(jwtauth.Verifier(config.TokenAuth))
+			r.Use(jwtauth.Authenticator)
+			r.Use(middlewares.Audit)
+
+			r.Get("/", auditHandler.GetAuditEvents)
+		})
+		r.Route("/tariffs", func(r chi.Router) {
+			r.Use(jwtauth.Verifier(config.TokenAuth))
+			r.Use(jwtauth.Authenticator)
+			r.Use(middlewares.Audit)
+
+			r.Post("/", tariffHandler.CreateTariff)
+			r.Get("/", tariffHandler.GetTariffs)
+			r.Get("/{id}", tariffHandler.GetTariff)
+		})
+		r.Route("/invoices", func(r chi.Router) {
+			r.Use(jwtauth.Verifier(config.TokenAuth))
+			r.Use(jwtauth.Authenticator)
+			r.Use(middlewares.Audit)
+
+			r.Post("/", invoiceHandler.CreateInvoice)
+			r.Get("/", invoiceHandler.GetInvoices)
+			r.Get("/{id}", invoiceHandler.GetInvoice)
+		})
+		r.Route("/analytics", func(r chi.Router) {
+			r.Use(jwtauth.Verifier(config.TokenAuth))
+			r.Use(jwtauth.Authenticator)
+			r.Use(middlewares.Audit)
+
+			r.Get("/consumption", analyticsHandler.GetConsumption)
+			r.Get("/moving-average", analyticsHandler.GetMovingAverage)
+			r.Get("/year-over-year", analyticsHandler.GetYearOverYear)
+			r.Get("/percentiles", analyticsHandler.GetPercentiles)
+		})
+		r.Route("/alerts", func(r chi.Router) {
+			r.Use(jwtauth.Verifier(config.TokenAuth))
+			r.Use(jwtauth.Authenticator)
+			r.Use(middlewares.Audit)
+
+			r.Get("/", alertHandler.GetAlerts)
+		})
 		r.Route("/users", func(r chi.Router) {
 			r.Post("/", userHandler.CreateUser)
 			r.Post("/token", userHandler.GetToken)
+			r.Group(func(r chi.Router) {
+				r.Use(jwtauth.Verifier(config.TokenAuth))
+				r.Use(jwtauth.Authenticator)
+				r.Use(middlewares.Audit)
+
+				r.Get("/me/statements/{period}.pdf", statementHandler.GetStatement)
+			})
 		})
 		r.Route("/docs", func(r chi.Router) {
 			r.Get("/*", httpSwagger.Handler(httpSwagger.URL(config.SwaggerURL)))
 		})
 	})
 
-	err = http.ListenAndServe(":"+config.WebServerPort, r)
-	if err != nil {
-		panic(err)
+	server := &http.Server{
+		Addr:              ":" + config.WebServerPort,
+		Handler:           r,
+		ReadTimeout:       config.ReadTimeout,
+		ReadHeaderTimeout: config.HeaderTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Info("server started", slog.String("addr", server.Addr))
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	case <-ctx.Done():
+		stop()
+	}
+
+	log.Info("shutting down", slog.Duration("timeout", config.ShutdownTimeout))
+	healthHandler.Drain()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	// In-flight requests may still schedule background tasks, so the worker
+	// pool is only drained once the server has stopped accepting them.
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("failed to drain HTTP requests", slog.String("error", err.Error()))
+	}
+	if err := pool.Shutdown(shutdownCtx); err != nil {
+		log.Error("failed to drain background tasks", slog.String("error", err.Error()))
+	}
+	if err := gemini.Close(); err != nil {
+		log.Error("failed to close OCR client", slog.String("error", err.Error()))
+	}
+	if err := sqlDB.Close(); err != nil {
+		log.Error("failed to close database", slog.String("error", err.Error()))
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error("failed to flush traces", slog.String("error", err.Error()))
 	}
+	log.Info("server stopped")
 }
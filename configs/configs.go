@@ -1,30 +1,84 @@
 package configs
 
 import (
+	"time"
+
 	"github.com/go-chi/jwtauth"
 	"github.com/melkzsiqueira/water-gas-measurement/docs"
 	"github.com/spf13/viper"
 )
 
+// ocrWriteMargin is the part of the write timeout kept for the work done on
+// a new reading after OCR.
+const ocrWriteMargin = 15 * time.Second
+
 type conf struct {
-	DBDriver         string `mapstructure:"DB_DRIVER"`
-	DBHost           string `mapstructure:"DB_HOST"`
-	DBPort           string `mapstructure:"DB_PORT"`
-	DBUser           string `mapstructure:"DB_USER"`
-	DBPassword       string `mapstructure:"DB_PASSWORD"`
-	DBName           string `mapstructure:"DB_NAME"`
-	DBSSLMode        string `mapstructure:"DB_SSL_MODE"`
-	DBTimezone       string `mapstructure:"DB_TIMEZONE"`
-	WebServerPort    string `mapstructure:"WEB_SERVER_PORT"`
-	WebServerHost    string `mapstructure:"WEB_SERVER_HOST"`
-	JWTSecret        string `mapstructure:"JWT_SECRET"`
-	JWTExpiresIn     int    `mapstructure:"JWT_EXPIRES_IN"`
-	APIVersion       string `mapstructure:"API_VERSION"`
-	GeminiKey        string `mapstructure:"GEMINI_API_KEY"`
-	GeminiModel      string `mapstructure:"GEMINI_MODEL"`
-	StorageAPIKey    string `mapstructure:"STORAGE_API_KEY"`
-	StorageAPISecret string `mapstructure:"STORAGE_API_SECRET"`
-	StorageName      string `mapstructure:"STORAGE_NAME"`
+	DBDriver         string        `mapstructure:"DB_DRIVER"`
+	DBHost           string        `mapstructure:"DB_HOST"`
+	DBPort           string        `mapstructure:"DB_PORT"`
+	DBUser           string        `mapstructure:"DB_USER"`
+	DBPassword       string        `mapstructure:"DB_PASSWORD"`
+	DBName           string        `mapstructure:"DB_NAME"`
+	DBSSLMode        string        `mapstructure:"DB_SSL_MODE"`
+	DBTimezone       string        `mapstructure:"DB_TIMEZONE"`
+	WebServerPort    string        `mapstructure:"WEB_SERVER_PORT"`
+	WebServerHost    string        `mapstructure:"WEB_SERVER_HOST"`
+	JWTSecret        string        `mapstructure:"JWT_SECRET"`
+	JWTExpiresIn     int           `mapstructure:"JWT_EXPIRES_IN"`
+	APIVersion       string        `mapstructure:"API_VERSION"`
+	GeminiKey        string        `mapstructure:"GEMINI_API_KEY"`
+	GeminiModel      string        `mapstructure:"GEMINI_MODEL"`
+	GeminiTimeout    time.Duration `mapstructure:"GEMINI_TIMEOUT"`
+	GeminiBudget     time.Duration `mapstructure:"GEMINI_TOTAL_TIMEOUT"`
+	GeminiRetries    int           `mapstructure:"GEMINI_MAX_RETRIES"`
+	GeminiBackoff    time.Duration `mapstructure:"GEMINI_RETRY_BACKOFF"`
+	GeminiThreshold  int           `mapstructure:"GEMINI_BREAKER_THRESHOLD"`
+	GeminiCooldown   time.Duration `mapstructure:"GEMINI_BREAKER_COOLDOWN"`
+	PromptsDir       string        `mapstructure:"GEMINI_PROMPTS_DIR"`
+	Prompt           string        `mapstructure:"GEMINI_PROMPT"`
+	PromptCandidate  string        `mapstructure:"GEMINI_PROMPT_CANDIDATE"`
+	PromptPercent    int           `mapstructure:"GEMINI_PROMPT_CANDIDATE_PERCENT"`
+	OCRMinConfidence float64       `mapstructure:"OCR_MIN_CONFIDENCE"`
+	OCRCache         string        `mapstructure:"OCR_CACHE"`
+	OCRCacheSize     int           `mapstructure:"OCR_CACHE_SIZE"`
+	OCRCacheTTL      time.Duration `mapstructure:"OCR_CACHE_TTL"`
+	ImageMaxSize     int           `mapstructure:"IMAGE_MAX_DIMENSION"`
+	ImageMaxPixels   int           `mapstructure:"IMAGE_MAX_PIXELS"`
+	ImageFormat      string        `mapstructure:"IMAGE_FORMAT"`
+	ImageQuality     int           `mapstructure:"IMAGE_QUALITY"`
+	ImageNormalize   bool          `mapstructure:"IMAGE_NORMALIZE_CONTRAST"`
+	KeepOriginal     bool          `mapstructure:"IMAGE_KEEP_ORIGINAL"`
+	DuplicateMaxDist int           `mapstructure:"IMAGE_DUPLICATE_DISTANCE"`
+	DuplicateWindow  int           `mapstructure:"IMAGE_DUPLICATE_LOOKBACK"`
+	ImportChunkSize  int           `mapstructure:"IMPORT_CHUNK_SIZE"`
+	ImportTimeout    time.Duration `mapstructure:"IMPORT_TIMEOUT"`
+	ExportTimeout    time.Duration `mapstructure:"EXPORT_TIMEOUT"`
+	StorageAPIKey    string        `mapstructure:"STORAGE_API_KEY"`
+	StorageAPISecret string        `mapstructure:"STORAGE_API_SECRET"`
+	StorageName      string        `mapstructure:"STORAGE_NAME"`
+	TracingExporter  string        `mapstructure:"TRACING_EXPORTER"`
+	TracingEndpoint  string        `mapstructure:"TRACING_ENDPOINT"`
+	TracingService   string        `mapstructure:"TRACING_SERVICE_NAME"`
+	LogLevel         string        `mapstructure:"LOG_LEVEL"`
+	HealthTimeout    time.Duration `mapstructure:"HEALTH_CHECK_TIMEOUT"`
+	HealthCheckOCR   bool          `mapstructure:"HEALTH_CHECK_OCR"`
+	HealthCheckStore bool          `mapstructure:"HEALTH_CHECK_STORAGE"`
+	HealthCacheTTL   time.Duration `mapstructure:"HEALTH_CHECK_CACHE_TTL"`
+	ReadTimeout      time.Duration `mapstructure:"WEB_SERVER_READ_TIMEOUT"`
+	HeaderTimeout    time.Duration `mapstructure:"WEB_SERVER_READ_HEADER_TIMEOUT"`
+	WriteTimeout     time.Duration `mapstructure:"WEB_SERVER_WRITE_TIMEOUT"`
+	IdleTimeout      time.Duration `mapstructure:"WEB_SERVER_IDLE_TIMEOUT"`
+	TrustedProxies   string        `mapstructure:"TRUSTED_PROXIES"`
+	ShutdownTimeout  time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	Workers          int           `mapstructure:"WORKERS"`
+	WorkerQueueSize  int           `mapstructure:"WORKER_QUEUE_SIZE"`
+	IdempotencyTTL   time.Duration `mapstructure:"IDEMPOTENCY_KEY_TTL"`
+	WaterAnomalyWarn float64       `mapstructure:"ANOMALY_WATER_WARNING"`
+	WaterAnomalyCrit float64       `mapstructure:"ANOMALY_WATER_CRITICAL"`
+	GasAnomalyWarn   float64       `mapstructure:"ANOMALY_GAS_WARNING"`
+	GasAnomalyCrit   float64       `mapstructure:"ANOMALY_GAS_CRITICAL"`
+	AnomalyMinHist   int           `mapstructure:"ANOMALY_MIN_HISTORY"`
+	AnomalyLookback  int           `mapstructure:"ANOMALY_LOOKBACK"`
 	DBDSN            string
 	SwaggerURL       string
 	TokenAuth        *jwtauth.JWTAuth
@@ -38,6 +92,54 @@ func LoadConfig(path string) (*conf, error) {
 	viper.AddConfigPath(path)
 	viper.SetConfigFile(".env")
 	viper.AutomaticEnv()
+	viper.SetDefault("GEMINI_TIMEOUT", "20s")
+	viper.SetDefault("GEMINI_TOTAL_TIMEOUT", "40s")
+	viper.SetDefault("GEMINI_MAX_RETRIES", 2)
+	viper.SetDefault("GEMINI_RETRY_BACKOFF", "500ms")
+	viper.SetDefault("GEMINI_BREAKER_THRESHOLD", 5)
+	viper.SetDefault("GEMINI_BREAKER_COOLDOWN", "30s")
+	viper.SetDefault("GEMINI_PROMPTS_DIR", "")
+	viper.SetDefault("GEMINI_PROMPT", "meter-reading@v3")
+	viper.SetDefault("GEMINI_PROMPT_CANDIDATE", "")
+	viper.SetDefault("GEMINI_PROMPT_CANDIDATE_PERCENT", 0)
+	viper.SetDefault("OCR_MIN_CONFIDENCE", 0.6)
+	viper.SetDefault("OCR_CACHE", "memory")
+	viper.SetDefault("OCR_CACHE_SIZE", 1000)
+	viper.SetDefault("OCR_CACHE_TTL", "24h")
+	viper.SetDefault("IMAGE_MAX_DIMENSION", 1600)
+	viper.SetDefault("IMAGE_MAX_PIXELS", 40000000)
+	viper.SetDefault("IMAGE_FORMAT", "jpeg")
+	viper.SetDefault("IMAGE_QUALITY", 85)
+	viper.SetDefault("IMAGE_NORMALIZE_CONTRAST", true)
+	viper.SetDefault("IMAGE_KEEP_ORIGINAL", false)
+	viper.SetDefault("IMAGE_DUPLICATE_DISTANCE", 6)
+	viper.SetDefault("IMAGE_DUPLICATE_LOOKBACK", 50)
+	viper.SetDefault("IMPORT_CHUNK_SIZE", 500)
+	viper.SetDefault("IMPORT_TIMEOUT", "10m")
+	viper.SetDefault("EXPORT_TIMEOUT", "10m")
+	viper.SetDefault("TRACING_EXPORTER", "none")
+	viper.SetDefault("TRACING_ENDPOINT", "")
+	viper.SetDefault("TRACING_SERVICE_NAME", "water-gas-measurement")
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", "2s")
+	viper.SetDefault("HEALTH_CHECK_OCR", false)
+	viper.SetDefault("HEALTH_CHECK_STORAGE", false)
+	viper.SetDefault("HEALTH_CHECK_CACHE_TTL", "60s")
+	viper.SetDefault("WEB_SERVER_READ_TIMEOUT", "15s")
+	viper.SetDefault("WEB_SERVER_READ_HEADER_TIMEOUT", "5s")
+	viper.SetDefault("WEB_SERVER_WRITE_TIMEOUT", "60s")
+	viper.SetDefault("WEB_SERVER_IDLE_TIMEOUT", "120s")
+	viper.SetDefault("TRUSTED_PROXIES", "")
+	viper.SetDefault("SHUTDOWN_TIMEOUT", "30s")
+	viper.SetDefault("WORKERS", 4)
+	viper.SetDefault("WORKER_QUEUE_SIZE", 100)
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL", "24h")
+	viper.SetDefault("ANOMALY_WATER_WARNING", 3.5)
+	viper.SetDefault("ANOMALY_WATER_CRITICAL", 6)
+	viper.SetDefault("ANOMALY_GAS_WARNING", 3.5)
+	viper.SetDefault("ANOMALY_GAS_CRITICAL", 6)
+	viper.SetDefault("ANOMALY_MIN_HISTORY", 6)
+	viper.SetDefault("ANOMALY_LOOKBACK", 12)
 
 	err := viper.ReadInConfig()
 
@@ -51,6 +153,13 @@ func LoadConfig(path string) (*conf, error) {
 		panic(err)
 	}
 
+	// A reading is only worth charging for if the client gets the response,
+	// so OCR must finish early enough to leave time for storage and the
+	// database before the server closes the connection.
+	if cfg.WriteTimeout > 0 && (cfg.GeminiBudget <= 0 || cfg.GeminiBudget > cfg.WriteTimeout-ocrWriteMargin) {
+		cfg.GeminiBudget = max(cfg.WriteTimeout-ocrWriteMargin, cfg.WriteTimeout/2)
+	}
+
 	cfg.TokenAuth = jwtauth.New("HS256", []byte(cfg.JWTSecret), nil)
 	cfg.DBDSN = "host=" + cfg.DBHost + " port=" + cfg.DBPort + " user=" + cfg.DBUser + " dbname=" + cfg.DBName + " password=" + cfg.DBPassword + " sslmode=" + cfg.DBSSLMode + " TimeZone=" + cfg.DBTimezone
 	cfg.SwaggerURL = "http://" + cfg.WebServerHost + ":" + cfg.WebServerPort + "/" + cfg.APIVersion + "/docs/doc.json"
@@ -0,0 +1,199 @@
+// Package analytics turns the monthly consumption aggregated by the
+// database into the series served by the analytics endpoints.
+//
+// Consumption is measured between two consecutive confirmed readings of a
+// meter and is attributed to the month of the later one, as billing does.
+// Daily averages divide it by the days the readings are apart, so they can
+// be compared across months and meters read on different days.
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/billing"
+)
+
+type Granularity string
+
+const (
+	Month   Granularity = "month"
+	Quarter Granularity = "quarter"
+	Season  Granularity = "season"
+	Year    Granularity = "year"
+)
+
+// DefaultRanks are the percentiles reported when none are requested.
+var DefaultRanks = []int{50, 75, 90, 95, 99}
+
+var (
+	ErrInvalidGranularity = errors.New("invalid granularity")
+	ErrInvalidRank        = errors.New("invalid percentile rank")
+)
+
+// seasons are the meteorological seasons, named after their months so the
+// labels hold in both hemispheres. December opens the DJF season of the
+// following year.
+var seasons = [12]string{"DJF", "DJF", "MAM", "MAM", "MAM", "JJA", "JJA", "JJA", "SON", "SON", "SON", "DJF"}
+
+// Monthly is the consumption attributed to one month across the meters of
+// a query. Month is a month index, see MonthIndex.
+type Monthly struct {
+	Month       int
+	Consumption int64
+	Days        float64
+	Readings    int64
+}
+
+// Usage is the consumption of a period. Readings counts the intervals
+// between readings that ended in it.
+type Usage struct {
+	Period       string  `json:"period"`
+	Consumption  int64   `json:"consumption"`
+	Days         float64 `json:"days"`
+	Readings     int64   `json:"readings"`
+	DailyAverage float64 `json:"daily_average"`
+}
+
+// Trend is the usage of a month with the daily average of the months of
+// the moving window ending in it.
+type Trend struct {
+	Usage
+	MovingAverage float64 `json:"moving_average"`
+}
+
+// Comparison is the usage of a month next to the same month of the previous
+// year. Change is the percent change of the daily average and is nil when
+// there is nothing to compare with.
+type Comparison struct {
+	Usage
+	PreviousYear *Usage   `json:"previous_year"`
+	Change       *float64 `json:"change"`
+}
+
+// Distribution describes the daily average of every meter in a month.
+type Distribution struct {
+	Period      string       `json:"period"`
+	Meters      int64        `json:"meters"`
+	Mean        float64      `json:"mean"`
+	Percentiles []Percentile `json:"percentiles"`
+}
+
+// Percentile is the daily average that Rank percent of the meters do not
+// exceed, using the nearest rank method.
+type Percentile struct {
+	Rank         int     `json:"rank"`
+	DailyAverage float64 `json:"daily_average"`
+}
+
+func ParseGranularity(value string) (Granularity, error) {
+	switch g := Granularity(value); g {
+	case Month, Quarter, Season, Year:
+		return g, nil
+	}
+	return "", ErrInvalidGranularity
+}
+
+// MonthIndex numbers months consecutively so that month arithmetic is
+// integer arithmetic in SQL and here alike.
+func MonthIndex(period billing.Period) int {
+	return period.Year*12 + int(period.Month) - 1
+}
+
+func PeriodOf(month int) billing.Period {
+	return billing.Period{Year: month / 12, Month: time.Month(month%12 + 1)}
+}
+
+// Label names the period of the given granularity month falls in.
+func Label(month int, granularity Granularity) string {
+	year, m := month/12, month%12
+	switch granularity {
+	case Quarter:
+		return fmt.Sprintf("%d-Q%d", year, m/3+1)
+	case Season:
+		if m == 11 {
+			year++
+		}
+		return fmt.Sprintf("%d-%s", year, seasons[m])
+	case Year:
+		return fmt.Sprintf("%d", year)
+	}
+	return PeriodOf(month).String()
+}
+
+// NewUsage builds the usage of a period from its totals.
+func NewUsage(period string, consumption int64, days float64, readings int64) Usage {
+	return Usage{
+		Period:       period,
+		Consumption:  consumption,
+		Days:         round(days, 2),
+		Readings:     readings,
+		DailyAverage: DailyAverage(consumption, days),
+	}
+}
+
+// DailyAverage returns consumption per day, or zero when no time passed.
+func DailyAverage(consumption int64, days float64) float64 {
+	if days <= 0 {
+		return 0
+	}
+	return Round(float64(consumption) / days)
+}
+
+// Round rounds a daily average to the precision it is reported with.
+func Round(value float64) float64 {
+	return round(value, 3)
+}
+
+// Rollup adds up months, in ascending order, into periods of granularity.
+func Rollup(months []Monthly, granularity Granularity) []Usage {
+	usages := []Usage{}
+	var days float64
+	for _, month := range months {
+		label := Label(month.Month, granularity)
+		if len(usages) == 0 || usages[len(usages)-1].Period != label {
+			usages = append(usages, Usage{Period: label})
+			days = 0
+		}
+		usage := &usages[len(usages)-1]
+		days += month.Days
+		*usage = NewUsage(label, usage.Consumption+month.Consumption, days, usage.Readings+month.Readings)
+	}
+	return usages
+}
+
+// Compare returns the comparison of current with previous.
+func Compare(current Usage, previous *Usage) Comparison {
+	comparison := Comparison{Usage: current, PreviousYear: previous}
+	if previous != nil && previous.DailyAverage > 0 {
+		change := round((current.DailyAverage-previous.DailyAverage)/previous.DailyAverage*100, 2)
+		comparison.Change = &change
+	}
+	return comparison
+}
+
+// ParseRanks validates percentile ranks, which go from 1 to 100.
+func ParseRanks(ranks []int) ([]int, error) {
+	if len(ranks) == 0 {
+		return DefaultRanks, nil
+	}
+	for _, rank := range ranks {
+		if rank < 1 || rank > 100 {
+			return nil, ErrInvalidRank
+		}
+	}
+	return ranks, nil
+}
+
+// NearestRank returns the 1-based position, among n sorted values, of the
+// rank percentile.
+func NearestRank(rank int, n int64) int64 {
+	return (n*int64(rank) + 99) / 100
+}
+
+func round(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
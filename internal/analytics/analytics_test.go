@@ -0,0 +1,91 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/billing"
+	"github.com/stretchr/testify/assert"
+)
+
+func month(year int, m time.Month) int {
+	return MonthIndex(billing.Period{Year: year, Month: m})
+}
+
+func TestMonthIndex(t *testing.T) {
+	assert.Equal(t, month(2024, time.January)-1, month(2023, time.December))
+	assert.Equal(t, billing.Period{Year: 2024, Month: time.March}, PeriodOf(month(2024, time.March)))
+	assert.Equal(t, billing.Period{Year: 2023, Month: time.December}, PeriodOf(month(2023, time.December)))
+}
+
+func TestLabel(t *testing.T) {
+	assert.Equal(t, "2024-03", Label(month(2024, time.March), Month))
+	assert.Equal(t, "2024-Q1", Label(month(2024, time.March), Quarter))
+	assert.Equal(t, "2024-Q4", Label(month(2024, time.October), Quarter))
+	assert.Equal(t, "2024-DJF", Label(month(2023, time.December), Season))
+	assert.Equal(t, "2024-DJF", Label(month(2024, time.February), Season))
+	assert.Equal(t, "2024-MAM", Label(month(2024, time.March), Season))
+	assert.Equal(t, "2024-SON", Label(month(2024, time.November), Season))
+	assert.Equal(t, "2024", Label(month(2024, time.November), Year))
+}
+
+func TestParseGranularity(t *testing.T) {
+	granularity, err := ParseGranularity("season")
+	assert.NoError(t, err)
+	assert.Equal(t, Season, granularity)
+
+	_, err = ParseGranularity("week")
+	assert.Equal(t, ErrInvalidGranularity, err)
+}
+
+func TestRollup(t *testing.T) {
+	months := []Monthly{
+		{Month: month(2023, time.November), Consumption: 30, Days: 30, Readings: 1},
+		{Month: month(2023, time.December), Consumption: 62, Days: 31, Readings: 1},
+		{Month: month(2024, time.February), Consumption: 40, Days: 31, Readings: 2},
+		{Month: month(2024, time.March), Consumption: 29, Days: 29, Readings: 1},
+	}
+
+	assert.Equal(t, []Usage{
+		{Period: "2023-SON", Consumption: 30, Days: 30, Readings: 1, DailyAverage: 1},
+		{Period: "2024-DJF", Consumption: 102, Days: 62, Readings: 3, DailyAverage: 1.645},
+		{Period: "2024-MAM", Consumption: 29, Days: 29, Readings: 1, DailyAverage: 1},
+	}, Rollup(months, Season))
+	assert.Equal(t, []Usage{
+		{Period: "2023", Consumption: 92, Days: 61, Readings: 2, DailyAverage: 1.508},
+		{Period: "2024", Consumption: 69, Days: 60, Readings: 3, DailyAverage: 1.15},
+	}, Rollup(months, Year))
+	assert.Len(t, Rollup(months, Month), 4)
+	assert.Empty(t, Rollup(nil, Month))
+}
+
+func TestCompare(t *testing.T) {
+	current := NewUsage("2024-03", 45, 30, 1)
+	assert.Equal(t, 1.5, current.DailyAverage)
+
+	previous := NewUsage("2023-03", 30, 30, 1)
+	comparison := Compare(current, &previous)
+	assert.Equal(t, 50.0, *comparison.Change)
+	assert.Equal(t, &previous, comparison.PreviousYear)
+
+	assert.Nil(t, Compare(current, nil).Change)
+	empty := NewUsage("2023-03", 0, 0, 1)
+	assert.Nil(t, Compare(current, &empty).Change)
+}
+
+func TestRanks(t *testing.T) {
+	ranks, err := ParseRanks(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRanks, ranks)
+
+	_, err = ParseRanks([]int{50, 101})
+	assert.Equal(t, ErrInvalidRank, err)
+	_, err = ParseRanks([]int{0})
+	assert.Equal(t, ErrInvalidRank, err)
+
+	assert.Equal(t, int64(1), NearestRank(1, 10))
+	assert.Equal(t, int64(5), NearestRank(50, 10))
+	assert.Equal(t, int64(10), NearestRank(95, 10))
+	assert.Equal(t, int64(10), NearestRank(100, 10))
+	assert.Equal(t, int64(1), NearestRank(50, 1))
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+)
+
+type Metadata struct {
+	Actor     string
+	RequestID string
+	IP        string
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, metadata Metadata) context.Context {
+	return context.WithValue(ctx, contextKey{}, metadata)
+}
+
+func FromContext(ctx context.Context) Metadata {
+	metadata, _ := ctx.Value(contextKey{}).(Metadata)
+	return metadata
+}
+
+// Diff returns the JSON representation of the fields that differ between
+// before and after. A nil side is recorded as null, so creates carry only
+// the after state and deletes only the before state.
+func Diff(before, after any) (entity.JSON, entity.JSON, error) {
+	beforeFields, err := toFields(before)
+	if err != nil {
+		return "", "", err
+	}
+	afterFields, err := toFields(after)
+	if err != nil {
+		return "", "", err
+	}
+	if beforeFields != nil && afterFields != nil {
+		for key, value := range beforeFields {
+			if reflect.DeepEqual(value, afterFields[key]) {
+				delete(beforeFields, key)
+				delete(afterFields, key)
+			}
+		}
+	}
+	beforeJSON, err := toJSON(beforeFields)
+	if err != nil {
+		return "", "", err
+	}
+	afterJSON, err := toJSON(afterFields)
+	if err != nil {
+		return "", "", err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+func toFields(value any) (map[string]any, error) {
+	if value == nil || reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+func toJSON(fields map[string]any) (entity.JSON, error) {
+	if fields == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(fields)
+	return entity.JSON(data), err
+}
@@ -0,0 +1,197 @@
+// Package billing turns the confirmed readings of a meter into invoices.
+// Everything here is a pure function of its arguments so a billing run can
+// be repeated, and tested, against any measurement history.
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+)
+
+var (
+	ErrInvalidPeriod       = errors.New("invalid period")
+	ErrNoPreviousReading   = errors.New("no confirmed reading before the billing period")
+	ErrNoCurrentReading    = errors.New("no confirmed reading in the billing period")
+	ErrNegativeConsumption = errors.New("current reading is lower than the previous one")
+	ErrNoTariff            = errors.New("no tariff in effect for the billing period")
+)
+
+const periodLayout = "2006-01"
+
+// Period is a calendar month billing cycle. Its bounds are in UTC.
+type Period struct {
+	Year  int
+	Month time.Month
+}
+
+// ParsePeriod parses a YYYY-MM period.
+func ParsePeriod(value string) (Period, error) {
+	t, err := time.Parse(periodLayout, value)
+	if err != nil {
+		return Period{}, ErrInvalidPeriod
+	}
+	return PeriodOf(t), nil
+}
+
+// PeriodOf returns the period t falls in.
+func PeriodOf(t time.Time) Period {
+	t = t.UTC()
+	return Period{Year: t.Year(), Month: t.Month()}
+}
+
+// Start is the first instant of the period.
+func (p Period) Start() time.Time {
+	return time.Date(p.Year, p.Month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// End is the first instant after the period.
+func (p Period) End() time.Time {
+	return p.Start().AddDate(0, 1, 0)
+}
+
+// AddMonths returns the period n months after p, or before it when n is
+// negative.
+func (p Period) AddMonths(n int) Period {
+	return PeriodOf(p.Start().AddDate(0, n, 0))
+}
+
+func (p Period) String() string {
+	return p.Start().Format(periodLayout)
+}
+
+// Bill computes the invoice of period from the readings of a single user and
+// measurement type. The consumption is the difference between the last
+// confirmed reading before the period and the last confirmed reading within
+// it, priced with the tariff in effect when the latter was taken.
+func Bill(period Period, readings []entity.Measurement, tariffs []entity.Tariff) (*entity.Invoice, error) {
+	previous, current, err := cycleReadings(period, readings)
+	if err != nil {
+		return nil, err
+	}
+	tariff, err := tariffAt(tariffs, current.Type, current.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	consumption := current.Value - previous.Value
+	if consumption < 0 {
+		return nil, ErrNegativeConsumption
+	}
+	return entity.NewInvoice(period.String(), tariff, previous, current, Charges(tariff, consumption))
+}
+
+// Charges prices consumption with tariff: one line per tier used, the fixed
+// fee and the tax over both. The first tier is always listed so an invoice
+// without consumption still shows what a m³ would have cost.
+func Charges(tariff *entity.Tariff, consumption int) []entity.InvoiceLine {
+	var lines []entity.InvoiceLine
+	lower := 0
+	for i, tier := range tariff.Tiers {
+		upper := tier.UpTo
+		if upper == 0 {
+			upper = max(consumption, lower)
+		}
+		quantity := min(consumption, upper) - lower
+		if quantity <= 0 && i > 0 {
+			break
+		}
+		quantity = max(quantity, 0)
+		lines = append(lines, line(entity.InvoiceLineConsumption, tierDescription(lower, tier.UpTo), quantity, tier.Price))
+		lower = upper
+	}
+	if tariff.FixedFee > 0 {
+		lines = append(lines, line(entity.InvoiceLineFixedFee, "Fixed fee", 1, tariff.FixedFee))
+	}
+	if tariff.TaxRate > 0 {
+		var subtotal int64
+		for _, l := range lines {
+			subtotal += l.Amount
+		}
+		lines = append(lines, line(entity.InvoiceLineTax, fmt.Sprintf("Tax %d.%02d%%", tariff.TaxRate/100, tariff.TaxRate%100), 1, Tax(subtotal, tariff.TaxRate)))
+	}
+	return lines
+}
+
+// Tax returns rate basis points of amount, rounding half cents up.
+func Tax(amount int64, rate int) int64 {
+	return (amount*int64(rate) + 5000) / 10000
+}
+
+func line(kind, description string, quantity int, unitPrice int64) entity.InvoiceLine {
+	return entity.InvoiceLine{
+		Kind:        kind,
+		Description: description,
+		Quantity:    quantity,
+		UnitPrice:   unitPrice,
+		Amount:      int64(quantity) * unitPrice,
+	}
+}
+
+func tierDescription(lower, upTo int) string {
+	if upTo == 0 && lower == 0 {
+		return "Consumption"
+	}
+	if upTo == 0 {
+		return fmt.Sprintf("Consumption above %d m³", lower)
+	}
+	return fmt.Sprintf("Consumption %d-%d m³", lower, upTo)
+}
+
+// cycleReadings picks the confirmed readings that delimit period. Readings
+// taken at the same instant are ordered by ID so the choice never depends on
+// the order of readings.
+func cycleReadings(period Period, readings []entity.Measurement) (*entity.Measurement, *entity.Measurement, error) {
+	confirmed := make([]entity.Measurement, 0, len(readings))
+	for _, reading := range readings {
+		if reading.Confirmed {
+			confirmed = append(confirmed, reading)
+		}
+	}
+	sort.Slice(confirmed, func(i, j int) bool {
+		if !confirmed[i].CreatedAt.Equal(confirmed[j].CreatedAt) {
+			return confirmed[i].CreatedAt.Before(confirmed[j].CreatedAt)
+		}
+		return confirmed[i].ID.String() < confirmed[j].ID.String()
+	})
+
+	var previous, current *entity.Measurement
+	start, end := period.Start(), period.End()
+	for i := range confirmed {
+		switch {
+		case confirmed[i].CreatedAt.Before(start):
+			previous = &confirmed[i]
+		case confirmed[i].CreatedAt.Before(end):
+			current = &confirmed[i]
+		}
+	}
+	if current == nil {
+		return nil, nil, ErrNoCurrentReading
+	}
+	if previous == nil {
+		return nil, nil, ErrNoPreviousReading
+	}
+	return previous, current, nil
+}
+
+// tariffAt returns the tariff of measurementType in effect at the given
+// time. When tariffs overlap the one that took effect last wins.
+func tariffAt(tariffs []entity.Tariff, measurementType string, at time.Time) (*entity.Tariff, error) {
+	var found *entity.Tariff
+	for i := range tariffs {
+		tariff := &tariffs[i]
+		if tariff.Type != measurementType || !tariff.EffectiveAt(at) {
+			continue
+		}
+		if found == nil || tariff.EffectiveFrom.After(found.EffectiveFrom) ||
+			(tariff.EffectiveFrom.Equal(found.EffectiveFrom) && tariff.ID.String() > found.ID.String()) {
+			found = tariff
+		}
+	}
+	if found == nil {
+		return nil, ErrNoTariff
+	}
+	return found, nil
+}
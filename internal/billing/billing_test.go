@@ -0,0 +1,135 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+const user = "878ab991-20b0-41c3-9c78-849744e8312a"
+
+func newTariff(t *testing.T, from time.Time, to *time.Time, tiers ...entity.TariffTier) entity.Tariff {
+	tariff, err := entity.NewTariff("Residential", entity.MeasurementTypeWater, "BRL", 1500, 1250, tiers, from, to)
+	assert.NoError(t, err)
+	return *tariff
+}
+
+func reading(t *testing.T, value int, takenAt time.Time, confirmed bool) entity.Measurement {
+	m, err := entity.NewImportedMeasurement(value, "", entity.MeasurementTypeWater, user, takenAt)
+	assert.NoError(t, err)
+	m.Confirmed = confirmed
+	return *m
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
+}
+
+func TestParsePeriod(t *testing.T) {
+	period, err := ParsePeriod("2024-03")
+	assert.NoError(t, err)
+	assert.Equal(t, Period{Year: 2024, Month: time.March}, period)
+	assert.Equal(t, time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), period.Start())
+	assert.Equal(t, time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC), period.End())
+	assert.Equal(t, "2023-12", period.AddMonths(-3).String())
+
+	_, err = ParsePeriod("2024-13")
+	assert.Equal(t, ErrInvalidPeriod, err)
+	_, err = ParsePeriod("march")
+	assert.Equal(t, ErrInvalidPeriod, err)
+}
+
+func TestCharges(t *testing.T) {
+	tariff := newTariff(t, date(2024, time.January, 1), nil,
+		entity.TariffTier{UpTo: 10, Price: 400},
+		entity.TariffTier{UpTo: 20, Price: 650},
+		entity.TariffTier{Price: 900},
+	)
+
+	lines := Charges(&tariff, 25)
+	assert.Len(t, lines, 5)
+	assert.Equal(t, entity.InvoiceLine{Kind: entity.InvoiceLineConsumption, Description: "Consumption 0-10 m³", Quantity: 10, UnitPrice: 400, Amount: 4000}, lines[0])
+	assert.Equal(t, entity.InvoiceLine{Kind: entity.InvoiceLineConsumption, Description: "Consumption 10-20 m³", Quantity: 10, UnitPrice: 650, Amount: 6500}, lines[1])
+	assert.Equal(t, entity.InvoiceLine{Kind: entity.InvoiceLineConsumption, Description: "Consumption above 20 m³", Quantity: 5, UnitPrice: 900, Amount: 4500}, lines[2])
+	assert.Equal(t, entity.InvoiceLine{Kind: entity.InvoiceLineFixedFee, Description: "Fixed fee", Quantity: 1, UnitPrice: 1500, Amount: 1500}, lines[3])
+	// 12.5% of 165.00 is 20.625, rounded half up.
+	assert.Equal(t, entity.InvoiceLine{Kind: entity.InvoiceLineTax, Description: "Tax 12.50%", Quantity: 1, UnitPrice: 2063, Amount: 2063}, lines[4])
+
+	lines = Charges(&tariff, 10)
+	assert.Len(t, lines, 3)
+	assert.Equal(t, 10, lines[0].Quantity)
+
+	lines = Charges(&tariff, 0)
+	assert.Len(t, lines, 3)
+	assert.Equal(t, 0, lines[0].Quantity)
+	assert.Equal(t, int64(188), lines[2].Amount)
+}
+
+func TestTax(t *testing.T) {
+	assert.Equal(t, int64(0), Tax(0, 1250))
+	assert.Equal(t, int64(1), Tax(4, 1250))
+	assert.Equal(t, int64(0), Tax(3, 1250))
+	assert.Equal(t, int64(10000), Tax(10000, 10000))
+}
+
+func TestBill(t *testing.T) {
+	old := newTariff(t, date(2023, time.January, 1), nil, entity.TariffTier{Price: 300})
+	cutoff := date(2024, time.March, 10)
+	old.EffectiveTo = &cutoff
+	current := newTariff(t, cutoff, nil, entity.TariffTier{UpTo: 10, Price: 400}, entity.TariffTier{Price: 900})
+
+	readings := []entity.Measurement{
+		reading(t, 140, date(2024, time.March, 28), true),
+		reading(t, 100, date(2024, time.February, 27), true),
+		reading(t, 90, date(2024, time.February, 3), true),
+		reading(t, 120, date(2024, time.March, 5), true),
+		reading(t, 160, date(2024, time.March, 30), false),
+		reading(t, 200, date(2024, time.April, 2), true),
+	}
+
+	invoice, err := Bill(Period{Year: 2024, Month: time.March}, readings, []entity.Tariff{old, current})
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-03", invoice.Period)
+	assert.Equal(t, user, invoice.User)
+	assert.Equal(t, entity.MeasurementTypeWater, invoice.Type)
+	assert.Equal(t, current.ID, invoice.TariffID)
+	assert.Equal(t, readings[1].ID, invoice.PreviousReading)
+	assert.Equal(t, readings[0].ID, invoice.CurrentReading)
+	assert.Equal(t, 40, invoice.Consumption)
+	// 10 * 4.00 + 30 * 9.00 + 15.00 fixed fee, plus 12.5% tax.
+	assert.Equal(t, int64(32500), invoice.Subtotal)
+	assert.Equal(t, int64(4063), invoice.Tax)
+	assert.Equal(t, int64(36563), invoice.Total)
+	assert.Len(t, invoice.Lines, 4)
+
+	again, err := Bill(Period{Year: 2024, Month: time.March}, []entity.Measurement{readings[5], readings[3], readings[0], readings[2], readings[1]}, []entity.Tariff{current, old})
+	assert.NoError(t, err)
+	assert.Equal(t, invoice.Total, again.Total)
+	assert.Equal(t, invoice.CurrentReading, again.CurrentReading)
+
+	invoice, err = Bill(Period{Year: 2024, Month: time.February}, readings, []entity.Tariff{old, current})
+	assert.Equal(t, ErrNoPreviousReading, err)
+	assert.Nil(t, invoice)
+}
+
+func TestBillErrors(t *testing.T) {
+	tariff := newTariff(t, date(2024, time.January, 1), nil, entity.TariffTier{Price: 300})
+	period := Period{Year: 2024, Month: time.March}
+
+	_, err := Bill(period, []entity.Measurement{reading(t, 100, date(2024, time.February, 1), true)}, []entity.Tariff{tariff})
+	assert.Equal(t, ErrNoCurrentReading, err)
+
+	_, err = Bill(period, []entity.Measurement{
+		reading(t, 100, date(2024, time.February, 1), true),
+		reading(t, 90, date(2024, time.March, 1), true),
+	}, []entity.Tariff{tariff})
+	assert.Equal(t, ErrNegativeConsumption, err)
+
+	_, err = Bill(period, []entity.Measurement{
+		reading(t, 100, date(2023, time.December, 1), true),
+		reading(t, 110, date(2024, time.March, 1), true),
+	}, []entity.Tariff{newTariff(t, date(2024, time.April, 1), nil, entity.TariffTier{Price: 300})})
+	assert.Equal(t, ErrNoTariff, err)
+}
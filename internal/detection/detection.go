@@ -0,0 +1,201 @@
+// Package detection scores confirmed readings against the history of their
+// meter to catch leaks, which show as a sudden spike in consumption, and
+// tampering, which shows as consumption dropping to zero.
+//
+// Readings are compared by consumption per day so that meters read at
+// irregular intervals can be compared. The expected rate is the median of
+// the latest intervals, scaled by how the month of the reading compares to
+// the rest of the year once earlier years cover it. Deviations are measured
+// with the median absolute deviation (MAD), so past anomalies barely move
+// the baseline, and the score is the modified z-score of Iglewicz and
+// Hoaglin.
+package detection
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	entityPkg "github.com/melkzsiqueira/water-gas-measurement/pkg/entity"
+)
+
+const (
+	// HistoryYears is how far back the history of a meter is read: enough
+	// to see each month of the year a few times.
+	HistoryYears = 3
+	// HistoryLimit bounds how many readings the history holds.
+	HistoryLimit = 200
+)
+
+const (
+	// madScale makes the MAD estimate the standard deviation of normally
+	// distributed rates.
+	madScale = 1.4826
+	// minRelativeSpread keeps meters with very regular use from alerting on
+	// changes smaller than a tenth of their expected rate.
+	minRelativeSpread = 0.1
+	// minSpread keeps scores finite for meters that never ran.
+	minSpread = 0.01
+	// seasonalSamples is how many intervals ending in the same calendar
+	// month the history needs before the baseline is adjusted for season.
+	seasonalSamples = 2
+)
+
+var ErrInvalidThresholds = errors.New("invalid anomaly thresholds")
+
+// Thresholds tunes detection for a measurement type. Lookback should cover
+// a year of intervals so that the seasonal adjustment compares a month
+// with the whole year.
+type Thresholds struct {
+	// Warning and Critical are the absolute scores from which a reading
+	// raises an alert of that severity.
+	Warning  float64
+	Critical float64
+	// MinHistory is how many intervals a meter needs before its readings
+	// are scored.
+	MinHistory int
+	// Lookback is how many of the latest intervals make up the baseline.
+	Lookback int
+}
+
+func (t Thresholds) Validate() error {
+	if t.Warning <= 0 || t.Critical < t.Warning || t.MinHistory < 1 || t.Lookback < 1 {
+		return ErrInvalidThresholds
+	}
+	return nil
+}
+
+// Interval is the consumption between a reading and the previous one of
+// the same meter.
+type Interval struct {
+	Reading     entityPkg.ID
+	End         time.Time
+	Consumption int
+	Days        float64
+}
+
+func (i Interval) Rate() float64 {
+	return float64(i.Consumption) / i.Days
+}
+
+// Intervals pairs consecutive readings, which must be sorted oldest first.
+// Intervals over which the register went back, such as after a meter
+// replacement, or no time passed are left out.
+func Intervals(readings []entity.Measurement) []Interval {
+	intervals := make([]Interval, 0, len(readings))
+	for i := 1; i < len(readings); i++ {
+		previous, current := readings[i-1], readings[i]
+		days := current.CreatedAt.Sub(previous.CreatedAt).Hours() / 24
+		if current.Value < previous.Value || days <= 0 {
+			continue
+		}
+		intervals = append(intervals, Interval{
+			Reading:     current.ID,
+			End:         current.CreatedAt,
+			Consumption: current.Value - previous.Value,
+			Days:        days,
+		})
+	}
+	return intervals
+}
+
+// Detect scores reading against the confirmed readings of the same meter
+// taken before it and returns the alert it raises. The alert is nil when
+// the consumption is within thresholds or the meter has too little history.
+// Other readings in history, reading itself included, are ignored.
+func Detect(reading *entity.Measurement, history []entity.Measurement, thresholds Thresholds) (*entity.Alert, error) {
+	readings := make([]entity.Measurement, 0, len(history)+1)
+	for _, m := range history {
+		if m.Confirmed && m.ID != reading.ID && m.User == reading.User && m.Type == reading.Type && m.CreatedAt.Before(reading.CreatedAt) {
+			readings = append(readings, m)
+		}
+	}
+	sort.Slice(readings, func(i, j int) bool {
+		if !readings[i].CreatedAt.Equal(readings[j].CreatedAt) {
+			return readings[i].CreatedAt.Before(readings[j].CreatedAt)
+		}
+		return readings[i].ID.String() < readings[j].ID.String()
+	})
+	readings = append(readings, *reading)
+
+	intervals := Intervals(readings)
+	if len(intervals) == 0 || intervals[len(intervals)-1].Reading != reading.ID {
+		return nil, nil
+	}
+	current, past := intervals[len(intervals)-1], intervals[:len(intervals)-1]
+	if len(past) < thresholds.MinHistory {
+		return nil, nil
+	}
+
+	expected, spread := baseline(past, current.End.UTC().Month(), thresholds.Lookback)
+	rate := current.Rate()
+	score := (rate - expected) / spread
+	var kind string
+	switch {
+	case current.Consumption == 0 && expected > 0:
+		kind = entity.AlertKindZero
+	case score >= thresholds.Warning:
+		kind = entity.AlertKindSpike
+	case score <= -thresholds.Warning:
+		kind = entity.AlertKindDrop
+	default:
+		return nil, nil
+	}
+	severity := entity.AlertSeverityWarning
+	if math.Abs(score) >= thresholds.Critical {
+		severity = entity.AlertSeverityCritical
+	}
+	return entity.NewAlert(reading, kind, severity, current.Consumption, round(rate, 3), round(expected, 3), round(score, 2))
+}
+
+// baseline returns the rate expected for an interval ending in month and
+// the spread of rates around it.
+func baseline(past []Interval, month time.Month, lookback int) (float64, float64) {
+	rates := make([]float64, len(past))
+	var sameMonth []float64
+	for i, interval := range past {
+		rates[i] = interval.Rate()
+		if interval.End.UTC().Month() == month {
+			sameMonth = append(sameMonth, rates[i])
+		}
+	}
+	recent := rates[max(0, len(rates)-lookback):]
+	expected := median(recent)
+	spread := madScale * mad(recent, expected)
+
+	if overall := median(rates); len(sameMonth) >= seasonalSamples && overall > 0 {
+		factor := median(sameMonth) / overall
+		expected *= factor
+		spread *= factor
+	}
+	return expected, max(spread, expected*minRelativeSpread, minSpread)
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// mad returns the median absolute deviation of values from center.
+func mad(values []float64, center float64) float64 {
+	deviations := make([]float64, len(values))
+	for i, value := range values {
+		deviations[i] = math.Abs(value - center)
+	}
+	return median(deviations)
+}
+
+func round(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,162 @@
+package detection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+const user = "878ab991-20b0-41c3-9c78-849744e8312a"
+
+var thresholds = Thresholds{Warning: 3.5, Critical: 6, MinHistory: 6, Lookback: 12}
+
+// readings returns monthly readings taken on the first day of each month
+// from January 2023, one per consumption plus the initial one.
+func readings(t *testing.T, consumptions ...int) []entity.Measurement {
+	value := 1000
+	m, err := entity.NewImportedMeasurement(value, "", entity.MeasurementTypeWater, user, date(0))
+	assert.NoError(t, err)
+	result := []entity.Measurement{*m}
+	for i, consumption := range consumptions {
+		value += consumption
+		m, err := entity.NewImportedMeasurement(value, "", entity.MeasurementTypeWater, user, date(i+1))
+		assert.NoError(t, err)
+		result = append(result, *m)
+	}
+	return result
+}
+
+func date(months int) time.Time {
+	return time.Date(2023, time.January+time.Month(months), 1, 12, 0, 0, 0, time.UTC)
+}
+
+// steady is a year of use around one cubic meter a day.
+var steady = []int{31, 29, 31, 30, 31, 31, 30, 31, 29, 31, 30, 31}
+
+func detect(t *testing.T, history []entity.Measurement) (*entity.Alert, error) {
+	last := history[len(history)-1]
+	return Detect(&last, history, thresholds)
+}
+
+func TestThresholdsValidate(t *testing.T) {
+	assert.NoError(t, thresholds.Validate())
+	assert.Equal(t, ErrInvalidThresholds, Thresholds{Warning: 6, Critical: 3.5, MinHistory: 6, Lookback: 12}.Validate())
+	assert.Equal(t, ErrInvalidThresholds, Thresholds{Warning: 3.5, Critical: 6, Lookback: 12}.Validate())
+}
+
+func TestIntervals(t *testing.T) {
+	history := readings(t, 31, 28)
+	replaced, err := entity.NewImportedMeasurement(5, "", entity.MeasurementTypeWater, user, date(3))
+	assert.NoError(t, err)
+	history = append(history, *replaced)
+
+	intervals := Intervals(history)
+	assert.Len(t, intervals, 2)
+	assert.Equal(t, history[1].ID, intervals[0].Reading)
+	assert.Equal(t, 31.0, intervals[0].Days)
+	assert.Equal(t, 1.0, intervals[0].Rate())
+	assert.Equal(t, 28, intervals[1].Consumption)
+}
+
+func TestDetectNormal(t *testing.T) {
+	alert, err := detect(t, readings(t, append(steady, 32)...))
+	assert.NoError(t, err)
+	assert.Nil(t, alert)
+}
+
+func TestDetectSpike(t *testing.T) {
+	history := readings(t, append(steady, 93)...)
+	alert, err := detect(t, history)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.AlertKindSpike, alert.Kind)
+	assert.Equal(t, entity.AlertSeverityCritical, alert.Severity)
+	assert.Equal(t, history[len(history)-1].ID, alert.MeasurementID)
+	assert.Equal(t, 93, alert.Consumption)
+	assert.Equal(t, 3.0, alert.DailyAverage)
+	assert.Equal(t, 1.0, alert.Expected)
+	assert.Equal(t, 20.0, alert.Score)
+
+	alert, err = detect(t, readings(t, append(steady, 45)...))
+	assert.NoError(t, err)
+	assert.Equal(t, entity.AlertKindSpike, alert.Kind)
+	assert.Equal(t, entity.AlertSeverityWarning, alert.Severity)
+}
+
+func TestDetectZero(t *testing.T) {
+	alert, err := detect(t, readings(t, append(steady, 0)...))
+	assert.NoError(t, err)
+	assert.Equal(t, entity.AlertKindZero, alert.Kind)
+	assert.Equal(t, entity.AlertSeverityCritical, alert.Severity)
+	assert.Equal(t, -10.0, alert.Score)
+}
+
+func TestDetectDrop(t *testing.T) {
+	alert, err := detect(t, readings(t, append(steady, 10)...))
+	assert.NoError(t, err)
+	assert.Equal(t, entity.AlertKindDrop, alert.Kind)
+	assert.Equal(t, entity.AlertSeverityCritical, alert.Severity)
+}
+
+func TestDetectIgnoresPastAnomalies(t *testing.T) {
+	history := append([]int{}, steady...)
+	history[5] = 300
+	alert, err := detect(t, readings(t, append(history, 31)...))
+	assert.NoError(t, err)
+	assert.Nil(t, alert)
+}
+
+func TestDetectSeasonality(t *testing.T) {
+	// Gas used for heating: five times as much from June to August.
+	var year []int
+	for month := time.January; month <= time.December; month++ {
+		days := time.Date(2023, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		if month >= time.June && month <= time.August {
+			days *= 5
+		}
+		year = append(year, days)
+	}
+	twoYears := append(append([]int{}, year...), year...)
+
+	// The readings of July 1st close June intervals; the history already
+	// holds two of them.
+	alert, err := detect(t, readings(t, append(twoYears, year[:6]...)...))
+	assert.NoError(t, err)
+	assert.Nil(t, alert)
+
+	alert, err = detect(t, readings(t, append(year, year[:6]...)...))
+	assert.NoError(t, err)
+	assert.Equal(t, entity.AlertKindSpike, alert.Kind)
+}
+
+func TestDetectNeedsHistory(t *testing.T) {
+	alert, err := detect(t, readings(t, 31, 28, 31, 30, 31, 500))
+	assert.NoError(t, err)
+	assert.Nil(t, alert)
+}
+
+func TestDetectFiltersHistory(t *testing.T) {
+	history := readings(t, append(steady, 93)...)
+	last := history[len(history)-1]
+
+	// Unconfirmed readings and readings of another meter do not count.
+	unconfirmed, err := entity.NewMeasurement(last.Value-60, "image", entity.MeasurementTypeWater, user)
+	assert.NoError(t, err)
+	unconfirmed.CreatedAt = date(12).AddDate(0, 0, 20)
+	other, err := entity.NewImportedMeasurement(100, "", entity.MeasurementTypeGas, user, date(12).AddDate(0, 0, 20))
+	assert.NoError(t, err)
+	shuffled := append([]entity.Measurement{last, *unconfirmed, *other}, history[:len(history)-1]...)
+
+	alert, err := Detect(&last, shuffled, thresholds)
+	assert.NoError(t, err)
+	assert.Equal(t, 93, alert.Consumption)
+
+	// A reading lower than the previous one, such as after a meter
+	// replacement, is not scored.
+	replaced, err := entity.NewImportedMeasurement(5, "", entity.MeasurementTypeWater, user, date(14))
+	assert.NoError(t, err)
+	alert, err = Detect(replaced, history, thresholds)
+	assert.NoError(t, err)
+	assert.Nil(t, alert)
+}
@@ -1,27 +1,79 @@
 package dto
 
+import "time"
+
 type CreateMeasurementImageInput struct {
-	Mime string `json:"mime"`
-	Data string `json:"data"`
+	Mime string `json:"mime" validate:"required,oneof=image/png image/jpeg image/webp"`
+	Data string `json:"data" validate:"required,base64"`
 }
 
 type CreateMeasurementInput struct {
-	Value     int                         `json:"value"`
-	Image     CreateMeasurementImageInput `json:"image"`
-	Type      string                      `json:"type"`
+	Value     int                         `json:"value" validate:"gte=0"`
+	Image     CreateMeasurementImageInput `json:"image" validate:"required"`
+	Type      string                      `json:"type" validate:"required,oneof=1 2"`
 	Confirmed bool                        `json:"confirmed"`
-	User      string                      `json:"user"`
+	User      string                      `json:"user" validate:"required,uuid"`
+}
+
+type UpdateMeasurementInput struct {
+	Value     int    `json:"value" validate:"required,gt=0"`
+	Type      string `json:"type" validate:"required,oneof=1 2"`
+	Confirmed bool   `json:"confirmed"`
+}
+
+type PatchMeasurementInput struct {
+	Value     *int    `json:"value,omitempty"`
+	Type      *string `json:"type,omitempty"`
+	Confirmed *bool   `json:"confirmed,omitempty"`
+}
+
+// ImportMeasurementRow is one historical reading of a bulk import. Date is
+// either an RFC 3339 timestamp or a YYYY-MM-DD date. Image, when present,
+// must be an https URL of a file already in the measurement storage.
+type ImportMeasurementRow struct {
+	User  string `json:"user" validate:"required,uuid"`
+	Type  string `json:"type" validate:"required,oneof=1 2"`
+	Value int    `json:"value" validate:"gt=0"`
+	Date  string `json:"date" validate:"required"`
+	Image string `json:"image,omitempty" validate:"omitempty,url,startswith=https://,max=2048"`
+}
+
+// TariffTierInput prices the consumption up to UpTo m³. The last tier has
+// no upper bound and leaves UpTo out.
+type TariffTierInput struct {
+	UpTo  int   `json:"up_to,omitempty" validate:"gte=0"`
+	Price int64 `json:"price" validate:"gte=0"`
+}
+
+// CreateTariffInput amounts are in cents and TaxRate is in basis points.
+type CreateTariffInput struct {
+	Name          string            `json:"name" validate:"required,max=255"`
+	Type          string            `json:"type" validate:"required,oneof=1 2"`
+	Currency      string            `json:"currency" validate:"required,len=3,uppercase"`
+	FixedFee      int64             `json:"fixed_fee" validate:"gte=0"`
+	TaxRate       int               `json:"tax_rate" validate:"gte=0,lte=10000"`
+	Tiers         []TariffTierInput `json:"tiers" validate:"required,min=1,max=20,dive"`
+	EffectiveFrom time.Time         `json:"effective_from" validate:"required"`
+	EffectiveTo   *time.Time        `json:"effective_to,omitempty"`
+}
+
+// CreateInvoiceInput bills the consumption of User in Period, a YYYY-MM
+// month.
+type CreateInvoiceInput struct {
+	User   string `json:"user" validate:"required,uuid"`
+	Type   string `json:"type" validate:"required,oneof=1 2"`
+	Period string `json:"period" validate:"required"`
 }
 
 type CreateUserInput struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,max=255"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=6,max=72"`
 }
 
 type GetTokenInput struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,max=72"`
 }
 
 type GetTokenOutput struct {
@@ -29,10 +81,32 @@ type GetTokenOutput struct {
 }
 
 type ProcessImageRequest struct {
-	Mime string `json:"mime"`
-	Data string `json:"data"`
+	Mime          string `json:"mime"`
+	Data          string `json:"data"`
+	PromptVersion string `json:"prompt_version"`
+}
+
+type ProcessImageUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CandidatesTokens int `json:"candidates_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
+const (
+	MeterTypeWater   = "water"
+	MeterTypeGas     = "gas"
+	MeterTypeUnknown = "unknown"
+)
+
 type ProcessImageResponse struct {
-	Value string `json:"value"`
+	Value         string            `json:"value"`
+	Unit          string            `json:"unit"`
+	MeterSerial   string            `json:"meter_serial"`
+	DigitCount    int               `json:"digit_count"`
+	Confidence    float64           `json:"confidence"`
+	IsMeter       bool              `json:"is_meter"`
+	MeterType     string            `json:"meter_type"`
+	Legible       bool              `json:"legible"`
+	PromptVersion string            `json:"prompt_version"`
+	Usage         ProcessImageUsage `json:"usage"`
 }
@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/entity"
+)
+
+const (
+	AlertKindSpike = "spike"
+	AlertKindDrop  = "drop"
+	AlertKindZero  = "zero_consumption"
+)
+
+const (
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// Alert flags a confirmed reading whose consumption since the previous
+// reading departs from the history of the meter. DailyAverage is the
+// consumption per day observed, Expected the one predicted from history and
+// Score how many robust standard deviations they are apart, negative when
+// the meter ran slower than expected. A reading raises at most one alert.
+type Alert struct {
+	ID            entity.ID `json:"id"`
+	User          string    `json:"user" gorm:"index"`
+	Type          string    `json:"type"`
+	MeasurementID entity.ID `json:"measurement_id" gorm:"uniqueIndex"`
+	Kind          string    `json:"kind"`
+	Severity      string    `json:"severity"`
+	Consumption   int       `json:"consumption"`
+	DailyAverage  float64   `json:"daily_average"`
+	Expected      float64   `json:"expected"`
+	Score         float64   `json:"score"`
+	ReadingAt     time.Time `json:"reading_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+var (
+	ErrInvalidAlertKind = errors.New("invalid alert kind")
+	ErrInvalidSeverity  = errors.New("invalid severity")
+)
+
+func NewAlert(measurement *Measurement, kind, severity string, consumption int, dailyAverage, expected, score float64) (*Alert, error) {
+	alert := &Alert{
+		ID:            entity.NewID(),
+		User:          measurement.User,
+		Type:          measurement.Type,
+		MeasurementID: measurement.ID,
+		Kind:          kind,
+		Severity:      severity,
+		Consumption:   consumption,
+		DailyAverage:  dailyAverage,
+		Expected:      expected,
+		Score:         score,
+		ReadingAt:     measurement.CreatedAt,
+		CreatedAt:     time.Now(),
+	}
+	err := alert.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+func (a *Alert) Validate() error {
+	if a.User == "" {
+		return ErrUserIsRequired
+	}
+	if _, err := entity.ParseID(a.User); err != nil {
+		return ErrInvalidUser
+	}
+	if a.Type == "" {
+		return ErrTypeIsRequired
+	}
+	if a.Type != MeasurementTypeWater && a.Type != MeasurementTypeGas {
+		return ErrInvalidType
+	}
+	if a.Kind != AlertKindSpike && a.Kind != AlertKindDrop && a.Kind != AlertKindZero {
+		return ErrInvalidAlertKind
+	}
+	if a.Severity != AlertSeverityWarning && a.Severity != AlertSeverityCritical {
+		return ErrInvalidSeverity
+	}
+	if a.Consumption < 0 {
+		return ErrInvalidConsumption
+	}
+	return nil
+}
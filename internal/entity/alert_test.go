@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAlert(t *testing.T) {
+	takenAt := time.Date(2024, time.March, 28, 0, 0, 0, 0, time.UTC)
+	reading, err := NewImportedMeasurement(180, "", "1", "878ab991-20b0-41c3-9c78-849744e8312a", takenAt)
+	assert.Nil(t, err)
+
+	alert, err := NewAlert(reading, AlertKindSpike, AlertSeverityCritical, 80, 2.667, 0.4, 9.5)
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, alert.ID)
+	assert.Equal(t, reading.ID, alert.MeasurementID)
+	assert.Equal(t, reading.User, alert.User)
+	assert.Equal(t, "1", alert.Type)
+	assert.Equal(t, takenAt, alert.ReadingAt)
+	assert.Equal(t, 80, alert.Consumption)
+}
+
+func TestAlertValidate(t *testing.T) {
+	reading, err := NewImportedMeasurement(180, "", "1", "878ab991-20b0-41c3-9c78-849744e8312a", time.Now())
+	assert.Nil(t, err)
+
+	_, err = NewAlert(reading, "leak", AlertSeverityWarning, 80, 2, 1, 4)
+	assert.Equal(t, ErrInvalidAlertKind, err)
+
+	_, err = NewAlert(reading, AlertKindDrop, "info", 80, 2, 1, 4)
+	assert.Equal(t, ErrInvalidSeverity, err)
+
+	_, err = NewAlert(reading, AlertKindDrop, AlertSeverityWarning, -1, 2, 1, 4)
+	assert.Equal(t, ErrInvalidConsumption, err)
+
+	reading.User = "user"
+	_, err = NewAlert(reading, AlertKindZero, AlertSeverityWarning, 0, 0, 1, -4)
+	assert.Equal(t, ErrInvalidUser, err)
+}
@@ -0,0 +1,104 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/entity"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionConfirm = "confirm"
+	AuditActionDelete  = "delete"
+)
+
+type JSON string
+
+type AuditEvent struct {
+	ID        entity.ID `json:"id"`
+	Actor     string    `json:"actor" gorm:"index"`
+	Action    string    `json:"action" gorm:"index"`
+	Entity    string    `json:"entity" gorm:"index:idx_audit_events_entity"`
+	EntityID  string    `json:"entity_id" gorm:"index:idx_audit_events_entity"`
+	Before    JSON      `json:"before" gorm:"type:text" swaggertype:"object"`
+	After     JSON      `json:"after" gorm:"type:text" swaggertype:"object"`
+	RequestID string    `json:"request_id"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+var (
+	ErrActionIsRequired      = errors.New("action is required")
+	ErrInvalidAction         = errors.New("invalid action")
+	ErrEntityIsRequired      = errors.New("entity is required")
+	ErrEntityIDIsRequired    = errors.New("entity id is required")
+	ErrAuditEventIsImmutable = errors.New("audit event is immutable")
+)
+
+func NewAuditEvent(actor, action, entityName, entityID string, before, after JSON, requestID, ip string) (*AuditEvent, error) {
+	event := &AuditEvent{
+		ID:        entity.NewID(),
+		Actor:     actor,
+		Action:    action,
+		Entity:    entityName,
+		EntityID:  entityID,
+		Before:    before,
+		After:     after,
+		RequestID: requestID,
+		IP:        ip,
+		CreatedAt: time.Now(),
+	}
+
+	err := event.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (e *AuditEvent) Validate() error {
+	if e.Action == "" {
+		return ErrActionIsRequired
+	}
+
+	if e.Action != AuditActionCreate && e.Action != AuditActionUpdate && e.Action != AuditActionConfirm && e.Action != AuditActionDelete {
+		return ErrInvalidAction
+	}
+
+	if e.Entity == "" {
+		return ErrEntityIsRequired
+	}
+
+	if e.EntityID == "" {
+		return ErrEntityIDIsRequired
+	}
+
+	return nil
+}
+
+func (e *AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditEventIsImmutable
+}
+
+func (e *AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditEventIsImmutable
+}
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+
+	return []byte(j), nil
+}
+
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	*j = JSON(data)
+
+	return nil
+}
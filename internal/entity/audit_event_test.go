@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAuditEvent(t *testing.T) {
+	e, err := NewAuditEvent("878ab991-20b0-41c3-9c78-849744e8312a", AuditActionCreate, "measurement", "3c20c52e-8db9-444a-b6b0-f56dd27b0400", "", `{"value":19}`, "req-1", "127.0.0.1")
+
+	assert.Nil(t, err)
+	assert.NotNil(t, e)
+	assert.NotEmpty(t, e.ID)
+	assert.NotEmpty(t, e.CreatedAt)
+	assert.Equal(t, AuditActionCreate, e.Action)
+	assert.Equal(t, "measurement", e.Entity)
+	assert.Equal(t, JSON(`{"value":19}`), e.After)
+}
+
+func TestAuditEventWhenActionIsRequired(t *testing.T) {
+	e, err := NewAuditEvent("", "", "measurement", "3c20c52e-8db9-444a-b6b0-f56dd27b0400", "", "", "", "")
+
+	assert.Nil(t, e)
+	assert.Equal(t, ErrActionIsRequired, err)
+}
+
+func TestAuditEventWhenInvalidAction(t *testing.T) {
+	e, err := NewAuditEvent("", "invalid", "measurement", "3c20c52e-8db9-444a-b6b0-f56dd27b0400", "", "", "", "")
+
+	assert.Nil(t, e)
+	assert.Equal(t, ErrInvalidAction, err)
+}
+
+func TestAuditEventWhenEntityIsRequired(t *testing.T) {
+	e, err := NewAuditEvent("", AuditActionCreate, "", "3c20c52e-8db9-444a-b6b0-f56dd27b0400", "", "", "", "")
+
+	assert.Nil(t, e)
+	assert.Equal(t, ErrEntityIsRequired, err)
+}
+
+func TestAuditEventWhenEntityIDIsRequired(t *testing.T) {
+	e, err := NewAuditEvent("", AuditActionCreate, "measurement", "", "", "", "", "")
+
+	assert.Nil(t, e)
+	assert.Equal(t, ErrEntityIDIsRequired, err)
+}
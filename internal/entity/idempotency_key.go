@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyKey records the first response to a request carrying an
+// Idempotency-Key header so retries of the same request can be answered
+// without running it again. ResponseStatus is zero while the first request
+// is still in flight, and LockedUntil bounds how long that request may hold
+// the key: a reservation whose lease ran out belongs to a request that died
+// before completing it.
+type IdempotencyKey struct {
+	User           string    `json:"user" gorm:"primaryKey"`
+	Key            string    `json:"key" gorm:"primaryKey"`
+	RequestHash    string    `json:"request_hash"`
+	ResponseStatus int       `json:"response_status"`
+	ResponseHeader JSON      `json:"response_header" gorm:"type:text" swaggertype:"object"`
+	ResponseBody   string    `json:"response_body" gorm:"type:text"`
+	LockedUntil    time.Time `json:"locked_until"`
+	ExpiresAt      time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+var ErrInvalidKey = errors.New("invalid key")
+
+func NewIdempotencyKey(user, key, requestHash string, ttl, lease time.Duration) (*IdempotencyKey, error) {
+	now := time.Now()
+	idempotencyKey := &IdempotencyKey{
+		User:        user,
+		Key:         key,
+		RequestHash: requestHash,
+		LockedUntil: now.Add(lease),
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+	}
+
+	err := idempotencyKey.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return idempotencyKey, nil
+}
+
+func (k *IdempotencyKey) Validate() error {
+	if k.User == "" {
+		return ErrUserIsRequired
+	}
+
+	if k.Key == "" {
+		return ErrKeyIsRequired
+	}
+
+	if len(k.Key) > maxIdempotencyKeyLength {
+		return ErrInvalidKey
+	}
+
+	return nil
+}
+
+func (k *IdempotencyKey) Completed() bool {
+	return k.ResponseStatus != 0
+}
+
+// Complete stores the response that later retries are answered with.
+func (k *IdempotencyKey) Complete(status int, header JSON, body string) {
+	k.ResponseStatus = status
+	k.ResponseHeader = header
+	k.ResponseBody = body
+}
@@ -0,0 +1,45 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewIdempotencyKey(t *testing.T) {
+	k, err := NewIdempotencyKey("878ab991-20b0-41c3-9c78-849744e8312a", "retry-1", "hash", time.Hour, time.Minute)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, k)
+	assert.Equal(t, "retry-1", k.Key)
+	assert.Equal(t, time.Hour, k.ExpiresAt.Sub(k.CreatedAt))
+	assert.Equal(t, time.Minute, k.LockedUntil.Sub(k.CreatedAt))
+	assert.False(t, k.Completed())
+
+	k.Complete(201, `{"Content-Type":["application/json"]}`, `{"value":19}`)
+	assert.True(t, k.Completed())
+	assert.Equal(t, 201, k.ResponseStatus)
+}
+
+func TestIdempotencyKeyWhenUserIsRequired(t *testing.T) {
+	k, err := NewIdempotencyKey("", "retry-1", "hash", time.Hour, time.Minute)
+
+	assert.Nil(t, k)
+	assert.Equal(t, ErrUserIsRequired, err)
+}
+
+func TestIdempotencyKeyWhenKeyIsRequired(t *testing.T) {
+	k, err := NewIdempotencyKey("878ab991-20b0-41c3-9c78-849744e8312a", "", "hash", time.Hour, time.Minute)
+
+	assert.Nil(t, k)
+	assert.Equal(t, ErrKeyIsRequired, err)
+}
+
+func TestIdempotencyKeyWhenKeyIsTooLong(t *testing.T) {
+	k, err := NewIdempotencyKey("878ab991-20b0-41c3-9c78-849744e8312a", strings.Repeat("k", 256), "hash", time.Hour, time.Minute)
+
+	assert.Nil(t, k)
+	assert.Equal(t, ErrInvalidKey, err)
+}
@@ -0,0 +1,139 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/entity"
+)
+
+const (
+	InvoiceLineConsumption = "consumption"
+	InvoiceLineFixedFee    = "fixed_fee"
+	InvoiceLineTax         = "tax"
+)
+
+// Invoice bills the consumption of one user and measurement type between
+// the last confirmed reading before a billing period and the last confirmed
+// reading within it. Period is the billing month as YYYY-MM and amounts are
+// in cents of Currency.
+type Invoice struct {
+	ID              entity.ID     `json:"id"`
+	User            string        `json:"user" gorm:"uniqueIndex:idx_invoices_cycle"`
+	Type            string        `json:"type" gorm:"uniqueIndex:idx_invoices_cycle"`
+	Period          string        `json:"period" gorm:"uniqueIndex:idx_invoices_cycle"`
+	TariffID        entity.ID     `json:"tariff_id"`
+	Currency        string        `json:"currency"`
+	PreviousReading entity.ID     `json:"previous_reading"`
+	PreviousValue   int           `json:"previous_value"`
+	CurrentReading  entity.ID     `json:"current_reading"`
+	CurrentValue    int           `json:"current_value"`
+	Consumption     int           `json:"consumption"`
+	Subtotal        int64         `json:"subtotal"`
+	Tax             int64         `json:"tax"`
+	Total           int64         `json:"total"`
+	Lines           []InvoiceLine `json:"lines" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt       time.Time     `json:"created_at"`
+}
+
+// InvoiceLine is one charge of an invoice. Amount is always Quantity times
+// UnitPrice.
+type InvoiceLine struct {
+	ID          entity.ID `json:"-"`
+	InvoiceID   entity.ID `json:"-" gorm:"index"`
+	Position    int       `json:"-"`
+	Kind        string    `json:"kind"`
+	Description string    `json:"description"`
+	Quantity    int       `json:"quantity"`
+	UnitPrice   int64     `json:"unit_price"`
+	Amount      int64     `json:"amount"`
+}
+
+var (
+	ErrPeriodIsRequired    = errors.New("period is required")
+	ErrLinesAreRequired    = errors.New("lines are required")
+	ErrInvalidInvoiceLine  = errors.New("invalid invoice line")
+	ErrInvalidConsumption  = errors.New("invalid consumption")
+	ErrReadingsOutOfOrder  = errors.New("previous reading must be older than the current one")
+	ErrReadingTypeMismatch = errors.New("readings must belong to the same user and type")
+)
+
+// NewInvoice builds the invoice of a billing period from the readings that
+// delimit it and the charges computed for its consumption.
+func NewInvoice(period string, tariff *Tariff, previous, current *Measurement, lines []InvoiceLine) (*Invoice, error) {
+	invoice := &Invoice{
+		ID:              entity.NewID(),
+		User:            current.User,
+		Type:            current.Type,
+		Period:          period,
+		TariffID:        tariff.ID,
+		Currency:        tariff.Currency,
+		PreviousReading: previous.ID,
+		PreviousValue:   previous.Value,
+		CurrentReading:  current.ID,
+		CurrentValue:    current.Value,
+		Consumption:     current.Value - previous.Value,
+		Lines:           lines,
+		CreatedAt:       time.Now(),
+	}
+	for i := range invoice.Lines {
+		invoice.Lines[i].ID = entity.NewID()
+		invoice.Lines[i].InvoiceID = invoice.ID
+		invoice.Lines[i].Position = i
+		if invoice.Lines[i].Kind == InvoiceLineTax {
+			invoice.Tax += invoice.Lines[i].Amount
+		} else {
+			invoice.Subtotal += invoice.Lines[i].Amount
+		}
+	}
+	invoice.Total = invoice.Subtotal + invoice.Tax
+
+	if previous.User != current.User || previous.Type != current.Type {
+		return nil, ErrReadingTypeMismatch
+	}
+
+	if !previous.CreatedAt.Before(current.CreatedAt) {
+		return nil, ErrReadingsOutOfOrder
+	}
+
+	err := invoice.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+func (i *Invoice) Validate() error {
+	if i.User == "" {
+		return ErrUserIsRequired
+	}
+
+	if i.Type != MeasurementTypeWater && i.Type != MeasurementTypeGas {
+		return ErrInvalidType
+	}
+
+	if i.Period == "" {
+		return ErrPeriodIsRequired
+	}
+
+	if i.Consumption < 0 {
+		return ErrInvalidConsumption
+	}
+
+	if len(i.Lines) == 0 {
+		return ErrLinesAreRequired
+	}
+
+	for _, line := range i.Lines {
+		if line.Kind != InvoiceLineConsumption && line.Kind != InvoiceLineFixedFee && line.Kind != InvoiceLineTax {
+			return ErrInvalidInvoiceLine
+		}
+		if line.Quantity < 0 || line.Amount != int64(line.Quantity)*line.UnitPrice {
+			return ErrInvalidInvoiceLine
+		}
+	}
+
+	return nil
+}
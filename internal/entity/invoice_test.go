@@ -0,0 +1,72 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func invoiceReadings(t *testing.T) (*Measurement, *Measurement) {
+	user := "878ab991-20b0-41c3-9c78-849744e8312a"
+	previous, err := NewImportedMeasurement(100, "", "1", user, time.Date(2024, time.February, 27, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	current, err := NewImportedMeasurement(112, "", "1", user, time.Date(2024, time.March, 28, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	return previous, current
+}
+
+func TestNewInvoice(t *testing.T) {
+	tariff, err := NewTariff("Residential", "1", "BRL", 1500, 1000, tiers(), tariffStart, nil)
+	assert.Nil(t, err)
+	previous, current := invoiceReadings(t)
+	lines := []InvoiceLine{
+		{Kind: InvoiceLineConsumption, Description: "Consumption 0-10 m³", Quantity: 10, UnitPrice: 400, Amount: 4000},
+		{Kind: InvoiceLineConsumption, Description: "Consumption 10-20 m³", Quantity: 2, UnitPrice: 650, Amount: 1300},
+		{Kind: InvoiceLineFixedFee, Description: "Fixed fee", Quantity: 1, UnitPrice: 1500, Amount: 1500},
+		{Kind: InvoiceLineTax, Description: "Tax 10.00%", Quantity: 1, UnitPrice: 680, Amount: 680},
+	}
+
+	invoice, err := NewInvoice("2024-03", tariff, previous, current, lines)
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, invoice.ID)
+	assert.Equal(t, current.User, invoice.User)
+	assert.Equal(t, "BRL", invoice.Currency)
+	assert.Equal(t, 12, invoice.Consumption)
+	assert.Equal(t, int64(6800), invoice.Subtotal)
+	assert.Equal(t, int64(680), invoice.Tax)
+	assert.Equal(t, int64(7480), invoice.Total)
+	assert.Equal(t, invoice.ID, invoice.Lines[3].InvoiceID)
+	assert.Equal(t, 3, invoice.Lines[3].Position)
+}
+
+func TestInvoiceWhenInvalid(t *testing.T) {
+	tariff, err := NewTariff("Residential", "1", "BRL", 0, 0, tiers(), tariffStart, nil)
+	assert.Nil(t, err)
+	previous, current := invoiceReadings(t)
+	line := InvoiceLine{Kind: InvoiceLineConsumption, Quantity: 12, UnitPrice: 400, Amount: 4800}
+
+	invoice, err := NewInvoice("2024-03", tariff, current, previous, []InvoiceLine{line})
+	assert.Nil(t, invoice)
+	assert.Equal(t, ErrReadingsOutOfOrder, err)
+
+	invoice, err = NewInvoice("", tariff, previous, current, []InvoiceLine{line})
+	assert.Nil(t, invoice)
+	assert.Equal(t, ErrPeriodIsRequired, err)
+
+	invoice, err = NewInvoice("2024-03", tariff, previous, current, nil)
+	assert.Nil(t, invoice)
+	assert.Equal(t, ErrLinesAreRequired, err)
+
+	line.Amount = 1
+	invoice, err = NewInvoice("2024-03", tariff, previous, current, []InvoiceLine{line})
+	assert.Nil(t, invoice)
+	assert.Equal(t, ErrInvalidInvoiceLine, err)
+
+	other := *previous
+	other.Type = "2"
+	invoice, err = NewInvoice("2024-03", tariff, &other, current, []InvoiceLine{line})
+	assert.Nil(t, invoice)
+	assert.Equal(t, ErrReadingTypeMismatch, err)
+}
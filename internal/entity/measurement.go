@@ -8,23 +8,57 @@ import (
 )
 
 type Measurement struct {
-	ID        entity.ID `json:"id"`
-	Value     int       `json:"value"`
-	Image     string    `json:"image"`
-	Type      string    `json:"type"`
-	Confirmed bool      `json:"confirmed"`
-	User      string    `json:"user"`
-	CreatedAt time.Time `json:"created_at"`
+	ID             entity.ID `json:"id"`
+	Value          int       `json:"value"`
+	OCRValue       int       `json:"ocr_value"`
+	Image          string    `json:"image"`
+	OriginalImage  string    `json:"original_image,omitempty"`
+	Thumbnail      string    `json:"thumbnail,omitempty"`
+	MediumImage    string    `json:"medium_image,omitempty"`
+	ImageSHA256    string    `json:"image_sha256" gorm:"index"`
+	ImagePHash     string    `json:"image_phash"`
+	SuspectedFraud bool      `json:"suspected_fraud"`
+	DuplicateOf    string    `json:"duplicate_of,omitempty"`
+	Imported       bool      `json:"imported"`
+	Type           string    `json:"type" gorm:"index:idx_measurements_meter,priority:2"`
+	Confirmed      bool      `json:"confirmed"`
+	User           string    `json:"user" gorm:"index:idx_measurements_meter,priority:1"`
+	Version        int       `json:"version" gorm:"not null;default:1"`
+	PromptVersion  string    `json:"prompt_version"`
+	CreatedAt      time.Time `json:"created_at" gorm:"index:idx_measurements_meter,priority:3"`
 }
 
+// MeasurementWithConsumption is a measurement together with the previous
+// reading of the same user and type. Both are nil for the first reading.
+type MeasurementWithConsumption struct {
+	Measurement   `gorm:"embedded"`
+	PreviousValue *int `json:"previous_value"`
+	Consumption   *int `json:"consumption"`
+}
+
+const (
+	MeasurementTypeWater = "1"
+	MeasurementTypeGas   = "2"
+)
+
+// Image renditions. The original rendition is the normalized full size
+// image, never the raw upload kept in OriginalImage.
+const (
+	RenditionThumb    = "thumb"
+	RenditionMedium   = "medium"
+	RenditionOriginal = "original"
+)
+
 var (
-	ErrValueIsRequired = errors.New("value is required")
-	ErrInvalidValue    = errors.New("invalid value")
-	ErrImageIsRequired = errors.New("image is required")
-	ErrTypeIsRequired  = errors.New("type is required")
-	ErrInvalidType     = errors.New("invalid type")
-	ErrUserIsRequired  = errors.New("user is required")
-	ErrInvalidUser     = errors.New("invalid user")
+	ErrValueIsRequired  = errors.New("value is required")
+	ErrInvalidValue     = errors.New("invalid value")
+	ErrImageIsRequired  = errors.New("image is required")
+	ErrTypeIsRequired   = errors.New("type is required")
+	ErrInvalidType      = errors.New("invalid type")
+	ErrUserIsRequired   = errors.New("user is required")
+	ErrInvalidUser      = errors.New("invalid user")
+	ErrInvalidRendition = errors.New("invalid rendition")
+	ErrInvalidDate      = errors.New("invalid date")
 )
 
 func NewMeasurement(value int, image string, measurementType string, user string) (*Measurement, error) {
@@ -35,6 +69,7 @@ func NewMeasurement(value int, image string, measurementType string, user string
 		Type:      measurementType,
 		Confirmed: false,
 		User:      user,
+		Version:   1,
 		CreatedAt: time.Now(),
 	}
 
@@ -47,6 +82,31 @@ func NewMeasurement(value int, image string, measurementType string, user string
 	return measurement, nil
 }
 
+// NewImportedMeasurement builds a historical reading loaded from another
+// system. It keeps the date it was taken on, may have no image and counts as
+// confirmed since it was already billed.
+func NewImportedMeasurement(value int, image string, measurementType string, user string, takenAt time.Time) (*Measurement, error) {
+	measurement := &Measurement{
+		ID:        entity.NewID(),
+		Value:     value,
+		Image:     image,
+		Type:      measurementType,
+		Confirmed: true,
+		Imported:  true,
+		User:      user,
+		Version:   1,
+		CreatedAt: takenAt,
+	}
+
+	err := measurement.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return measurement, nil
+}
+
 func (m *Measurement) Validate() error {
 	if m.Value == 0 {
 		return ErrValueIsRequired
@@ -56,7 +116,7 @@ func (m *Measurement) Validate() error {
 		return ErrInvalidValue
 	}
 
-	if m.Image == "" {
+	if m.Image == "" && !m.Imported {
 		return ErrImageIsRequired
 	}
 
@@ -64,7 +124,7 @@ func (m *Measurement) Validate() error {
 		return ErrTypeIsRequired
 	}
 
-	if m.Type != "1" && m.Type != "2" {
+	if m.Type != MeasurementTypeWater && m.Type != MeasurementTypeGas {
 		return ErrInvalidType
 	}
 
@@ -76,5 +136,51 @@ func (m *Measurement) Validate() error {
 		return ErrInvalidUser
 	}
 
+	if m.Imported && (m.CreatedAt.IsZero() || m.CreatedAt.After(time.Now())) {
+		return ErrInvalidDate
+	}
+
+	return nil
+}
+
+// Rendition returns the URL of the given rendition, or an empty string when
+// it has not been generated yet.
+func (m *Measurement) Rendition(size string) (string, error) {
+	switch size {
+	case RenditionThumb:
+		return m.Thumbnail, nil
+	case RenditionMedium:
+		return m.MediumImage, nil
+	case RenditionOriginal:
+		return m.Image, nil
+	default:
+		return "", ErrInvalidRendition
+	}
+}
+
+func (m *Measurement) SetRendition(size, url string) error {
+	switch size {
+	case RenditionThumb:
+		m.Thumbnail = url
+	case RenditionMedium:
+		m.MediumImage = url
+	default:
+		return ErrInvalidRendition
+	}
 	return nil
 }
+
+// ReuseImage points m at the stored image and renditions of other, which
+// holds the same image bytes.
+func (m *Measurement) ReuseImage(other *Measurement) {
+	m.Image = other.Image
+	m.OriginalImage = other.OriginalImage
+	m.Thumbnail = other.Thumbnail
+	m.MediumImage = other.MediumImage
+}
+
+// FlagDuplicateOf marks m as a possible re-submission of another reading.
+func (m *Measurement) FlagDuplicateOf(id entity.ID) {
+	m.SuspectedFraud = true
+	m.DuplicateOf = id.String()
+}
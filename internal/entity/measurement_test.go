@@ -2,6 +2,7 @@ package entity
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -16,6 +17,7 @@ func TestNewMeasurement(t *testing.T) {
 	assert.NotEmpty(t, m.ID)
 	assert.NotEmpty(t, m.CreatedAt)
 	assert.False(t, m.Confirmed)
+	assert.Equal(t, 1, m.Version)
 	assert.Equal(t, 19, m.Value)
 	assert.Equal(t, m.Image, image)
 	assert.Equal(t, m.Type, "1")
@@ -70,3 +72,84 @@ func TestMeasurementWhenInvalidUser(t *testing.T) {
 	assert.Nil(t, m)
 	assert.Equal(t, ErrInvalidUser, err)
 }
+
+func TestNewImportedMeasurement(t *testing.T) {
+	takenAt := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	m, err := NewImportedMeasurement(19, "", "1", "878ab991-20b0-41c3-9c78-849744e8312a", takenAt)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, m)
+	assert.True(t, m.Imported)
+	assert.True(t, m.Confirmed)
+	assert.Equal(t, takenAt, m.CreatedAt)
+	assert.Empty(t, m.Image)
+}
+
+func TestImportedMeasurementWhenInvalidDate(t *testing.T) {
+	m, err := NewImportedMeasurement(19, "", "1", "878ab991-20b0-41c3-9c78-849744e8312a", time.Time{})
+
+	assert.Nil(t, m)
+	assert.Equal(t, ErrInvalidDate, err)
+
+	m, err = NewImportedMeasurement(19, "", "1", "878ab991-20b0-41c3-9c78-849744e8312a", time.Now().Add(time.Hour))
+
+	assert.Nil(t, m)
+	assert.Equal(t, ErrInvalidDate, err)
+}
+
+func TestMeasurementRendition(t *testing.T) {
+	m, err := NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.Nil(t, err)
+
+	url, err := m.Rendition(RenditionThumb)
+	assert.Nil(t, err)
+	assert.Empty(t, url)
+
+	assert.Nil(t, m.SetRendition(RenditionThumb, "https://example.com/thumb.jpg"))
+	assert.Nil(t, m.SetRendition(RenditionMedium, "https://example.com/medium.jpg"))
+
+	url, err = m.Rendition(RenditionThumb)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://example.com/thumb.jpg", url)
+	url, err = m.Rendition(RenditionMedium)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://example.com/medium.jpg", url)
+	url, err = m.Rendition(RenditionOriginal)
+	assert.Nil(t, err)
+	assert.Equal(t, image, url)
+}
+
+func TestMeasurementWhenInvalidRendition(t *testing.T) {
+	m, err := NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.Nil(t, err)
+
+	_, err = m.Rendition("large")
+	assert.Equal(t, ErrInvalidRendition, err)
+	assert.Equal(t, ErrInvalidRendition, m.SetRendition(RenditionOriginal, "https://example.com/a.jpg"))
+}
+
+func TestMeasurementReuseImage(t *testing.T) {
+	first, err := NewMeasurement(19, "https://example.com/first.jpg", "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.Nil(t, err)
+	first.Thumbnail = "https://example.com/first_thumb.jpg"
+	second, err := NewMeasurement(20, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.Nil(t, err)
+
+	second.ReuseImage(first)
+
+	assert.Equal(t, first.Image, second.Image)
+	assert.Equal(t, first.Thumbnail, second.Thumbnail)
+	assert.False(t, second.SuspectedFraud)
+}
+
+func TestMeasurementFlagDuplicateOf(t *testing.T) {
+	first, err := NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.Nil(t, err)
+	second, err := NewMeasurement(20, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.Nil(t, err)
+
+	second.FlagDuplicateOf(first.ID)
+
+	assert.True(t, second.SuspectedFraud)
+	assert.Equal(t, first.ID.String(), second.DuplicateOf)
+}
@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+// OCRCacheEntry stores a provider response so the same image is not sent
+// to the OCR provider twice. It is shared by every replica.
+type OCRCacheEntry struct {
+	Key       string    `json:"key" gorm:"primaryKey"`
+	Response  JSON      `json:"response" gorm:"type:text" swaggertype:"object"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+var (
+	ErrKeyIsRequired      = errors.New("key is required")
+	ErrResponseIsRequired = errors.New("response is required")
+)
+
+func NewOCRCacheEntry(key string, response JSON, ttl time.Duration) (*OCRCacheEntry, error) {
+	now := time.Now()
+	entry := &OCRCacheEntry{
+		Key:       key,
+		Response:  response,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	err := entry.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (e *OCRCacheEntry) Validate() error {
+	if e.Key == "" {
+		return ErrKeyIsRequired
+	}
+
+	if e.Response == "" {
+		return ErrResponseIsRequired
+	}
+
+	return nil
+}
+
+func (e *OCRCacheEntry) Expired(now time.Time) bool {
+	return !now.Before(e.ExpiresAt)
+}
@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOCRCacheEntry(t *testing.T) {
+	e, err := NewOCRCacheEntry("key", `{"value":"19"}`, time.Hour)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, e)
+	assert.Equal(t, "key", e.Key)
+	assert.Equal(t, time.Hour, e.ExpiresAt.Sub(e.CreatedAt))
+	assert.False(t, e.Expired(e.CreatedAt))
+	assert.True(t, e.Expired(e.ExpiresAt))
+}
+
+func TestOCRCacheEntryWhenKeyIsRequired(t *testing.T) {
+	e, err := NewOCRCacheEntry("", `{"value":"19"}`, time.Hour)
+
+	assert.Nil(t, e)
+	assert.Equal(t, ErrKeyIsRequired, err)
+}
+
+func TestOCRCacheEntryWhenResponseIsRequired(t *testing.T) {
+	e, err := NewOCRCacheEntry("key", "", time.Hour)
+
+	assert.Nil(t, e)
+	assert.Equal(t, ErrResponseIsRequired, err)
+}
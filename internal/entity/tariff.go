@@ -0,0 +1,140 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/entity"
+)
+
+// Tariff prices the consumption of one measurement type. Amounts are in
+// cents of Currency, TaxRate is in basis points (1250 is 12.5%) and applies
+// to the consumption charges plus the fixed fee. A tariff is in effect from
+// EffectiveFrom until EffectiveTo, or indefinitely when EffectiveTo is nil.
+type Tariff struct {
+	ID            entity.ID    `json:"id"`
+	Name          string       `json:"name"`
+	Type          string       `json:"type" gorm:"index:idx_tariffs_effective"`
+	Currency      string       `json:"currency"`
+	FixedFee      int64        `json:"fixed_fee"`
+	TaxRate       int          `json:"tax_rate"`
+	Tiers         []TariffTier `json:"tiers" gorm:"constraint:OnDelete:CASCADE"`
+	EffectiveFrom time.Time    `json:"effective_from" gorm:"index:idx_tariffs_effective"`
+	EffectiveTo   *time.Time   `json:"effective_to,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// TariffTier is the price per m³ of the consumption up to UpTo m³. Tiers are
+// ordered by UpTo and the last one has no upper bound (UpTo is zero).
+type TariffTier struct {
+	ID       entity.ID `json:"-"`
+	TariffID entity.ID `json:"-" gorm:"index"`
+	Position int       `json:"-"`
+	UpTo     int       `json:"up_to"`
+	Price    int64     `json:"price"`
+}
+
+const maxTaxRate = 10000
+
+var (
+	ErrInvalidCurrency     = errors.New("invalid currency")
+	ErrInvalidFixedFee     = errors.New("invalid fixed fee")
+	ErrInvalidTaxRate      = errors.New("invalid tax rate")
+	ErrTiersAreRequired    = errors.New("tiers are required")
+	ErrInvalidTier         = errors.New("invalid tier")
+	ErrInvalidEffectiveTo  = errors.New("effective to must be after effective from")
+	ErrEffectiveIsRequired = errors.New("effective from is required")
+)
+
+func NewTariff(name, measurementType, currency string, fixedFee int64, taxRate int, tiers []TariffTier, effectiveFrom time.Time, effectiveTo *time.Time) (*Tariff, error) {
+	tariff := &Tariff{
+		ID:            entity.NewID(),
+		Name:          name,
+		Type:          measurementType,
+		Currency:      currency,
+		FixedFee:      fixedFee,
+		TaxRate:       taxRate,
+		Tiers:         tiers,
+		EffectiveFrom: effectiveFrom,
+		EffectiveTo:   effectiveTo,
+		CreatedAt:     time.Now(),
+	}
+	for i := range tariff.Tiers {
+		tariff.Tiers[i].ID = entity.NewID()
+		tariff.Tiers[i].TariffID = tariff.ID
+		tariff.Tiers[i].Position = i
+	}
+
+	err := tariff.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tariff, nil
+}
+
+func (t *Tariff) Validate() error {
+	if t.Name == "" {
+		return ErrNameIsRequired
+	}
+
+	if t.Type == "" {
+		return ErrTypeIsRequired
+	}
+
+	if t.Type != MeasurementTypeWater && t.Type != MeasurementTypeGas {
+		return ErrInvalidType
+	}
+
+	if len(t.Currency) != 3 {
+		return ErrInvalidCurrency
+	}
+
+	if t.FixedFee < 0 {
+		return ErrInvalidFixedFee
+	}
+
+	if t.TaxRate < 0 || t.TaxRate > maxTaxRate {
+		return ErrInvalidTaxRate
+	}
+
+	if len(t.Tiers) == 0 {
+		return ErrTiersAreRequired
+	}
+
+	last := 0
+	for i, tier := range t.Tiers {
+		if tier.Price < 0 {
+			return ErrInvalidTier
+		}
+		if i == len(t.Tiers)-1 {
+			if tier.UpTo != 0 {
+				return ErrInvalidTier
+			}
+			break
+		}
+		if tier.UpTo <= last {
+			return ErrInvalidTier
+		}
+		last = tier.UpTo
+	}
+
+	if t.EffectiveFrom.IsZero() {
+		return ErrEffectiveIsRequired
+	}
+
+	if t.EffectiveTo != nil && !t.EffectiveTo.After(t.EffectiveFrom) {
+		return ErrInvalidEffectiveTo
+	}
+
+	return nil
+}
+
+// EffectiveAt tells whether the tariff is in effect at the given time.
+func (t *Tariff) EffectiveAt(at time.Time) bool {
+	if at.Before(t.EffectiveFrom) {
+		return false
+	}
+	return t.EffectiveTo == nil || at.Before(*t.EffectiveTo)
+}
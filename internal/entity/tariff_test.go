@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var tariffStart = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func tiers() []TariffTier {
+	return []TariffTier{{UpTo: 10, Price: 400}, {UpTo: 20, Price: 650}, {Price: 900}}
+}
+
+func TestNewTariff(t *testing.T) {
+	tariff, err := NewTariff("Residential", "1", "BRL", 1500, 1250, tiers(), tariffStart, nil)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, tariff)
+	assert.NotEmpty(t, tariff.ID)
+	assert.Len(t, tariff.Tiers, 3)
+	assert.Equal(t, tariff.ID, tariff.Tiers[2].TariffID)
+	assert.Equal(t, 2, tariff.Tiers[2].Position)
+	assert.True(t, tariff.EffectiveAt(tariffStart))
+	assert.False(t, tariff.EffectiveAt(tariffStart.Add(-time.Second)))
+}
+
+func TestTariffEffectiveTo(t *testing.T) {
+	end := tariffStart.AddDate(0, 6, 0)
+	tariff, err := NewTariff("Residential", "1", "BRL", 0, 0, tiers(), tariffStart, &end)
+
+	assert.Nil(t, err)
+	assert.True(t, tariff.EffectiveAt(end.Add(-time.Second)))
+	assert.False(t, tariff.EffectiveAt(end))
+
+	tariff, err = NewTariff("Residential", "1", "BRL", 0, 0, tiers(), tariffStart, &tariffStart)
+	assert.Nil(t, tariff)
+	assert.Equal(t, ErrInvalidEffectiveTo, err)
+}
+
+func TestTariffWhenInvalid(t *testing.T) {
+	cases := []struct {
+		name     string
+		tariff   func() (*Tariff, error)
+		expected error
+	}{
+		{"name", func() (*Tariff, error) { return NewTariff("", "1", "BRL", 0, 0, tiers(), tariffStart, nil) }, ErrNameIsRequired},
+		{"type", func() (*Tariff, error) { return NewTariff("R", "3", "BRL", 0, 0, tiers(), tariffStart, nil) }, ErrInvalidType},
+		{"currency", func() (*Tariff, error) { return NewTariff("R", "1", "R$", 0, 0, tiers(), tariffStart, nil) }, ErrInvalidCurrency},
+		{"fixed fee", func() (*Tariff, error) { return NewTariff("R", "1", "BRL", -1, 0, tiers(), tariffStart, nil) }, ErrInvalidFixedFee},
+		{"tax rate", func() (*Tariff, error) { return NewTariff("R", "1", "BRL", 0, 10001, tiers(), tariffStart, nil) }, ErrInvalidTaxRate},
+		{"no tiers", func() (*Tariff, error) { return NewTariff("R", "1", "BRL", 0, 0, nil, tariffStart, nil) }, ErrTiersAreRequired},
+		{"bounded last tier", func() (*Tariff, error) {
+			return NewTariff("R", "1", "BRL", 0, 0, []TariffTier{{UpTo: 10, Price: 1}}, tariffStart, nil)
+		}, ErrInvalidTier},
+		{"unordered tiers", func() (*Tariff, error) {
+			return NewTariff("R", "1", "BRL", 0, 0, []TariffTier{{UpTo: 10, Price: 1}, {UpTo: 5, Price: 1}, {Price: 1}}, tariffStart, nil)
+		}, ErrInvalidTier},
+		{"negative price", func() (*Tariff, error) {
+			return NewTariff("R", "1", "BRL", 0, 0, []TariffTier{{Price: -1}}, tariffStart, nil)
+		}, ErrInvalidTier},
+		{"effective from", func() (*Tariff, error) { return NewTariff("R", "1", "BRL", 0, 0, tiers(), time.Time{}, nil) }, ErrEffectiveIsRequired},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tariff, err := c.tariff()
+
+			assert.Nil(t, tariff)
+			assert.Equal(t, c.expected, err)
+		})
+	}
+}
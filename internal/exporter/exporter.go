@@ -0,0 +1,149 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/xlsx"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatXLSX   = "xlsx"
+
+	sheetName = "Measurements"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+var columns = []string{"id", "user", "type", "value", "previous_value", "consumption", "confirmed", "imported", "suspected_fraud", "created_at", "image"}
+
+// Writer encodes measurements one at a time. Close must be called to flush
+// the last rows and, for XLSX, to finish the workbook.
+type Writer interface {
+	Write(measurement *entity.MeasurementWithConsumption) error
+	Close() error
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+type csvWriter struct {
+	csv *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	c := csv.NewWriter(w)
+	if err := c.Write(columns); err != nil {
+		return nil, err
+	}
+	return &csvWriter{csv: c}, nil
+}
+
+func (c *csvWriter) Write(m *entity.MeasurementWithConsumption) error {
+	return c.csv.Write([]string{
+		m.ID.String(),
+		m.User,
+		m.Type,
+		strconv.Itoa(m.Value),
+		optional(m.PreviousValue),
+		optional(m.Consumption),
+		strconv.FormatBool(m.Confirmed),
+		strconv.FormatBool(m.Imported),
+		strconv.FormatBool(m.SuspectedFraud),
+		m.CreatedAt.UTC().Format(time.RFC3339),
+		m.Image,
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(m *entity.MeasurementWithConsumption) error {
+	return n.encoder.Encode(m)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+type xlsxWriter struct {
+	xlsx *xlsx.Writer
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	x, err := xlsx.NewWriter(w, sheetName)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]any, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	if err := x.WriteRow(header...); err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{xlsx: x}, nil
+}
+
+func (x *xlsxWriter) Write(m *entity.MeasurementWithConsumption) error {
+	return x.xlsx.WriteRow(
+		m.ID.String(),
+		m.User,
+		m.Type,
+		m.Value,
+		m.PreviousValue,
+		m.Consumption,
+		m.Confirmed,
+		m.Imported,
+		m.SuspectedFraud,
+		m.CreatedAt.UTC(),
+		m.Image,
+	)
+}
+
+func (x *xlsxWriter) Close() error {
+	return x.xlsx.Close()
+}
+
+func optional(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
@@ -0,0 +1,66 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func measurements(t *testing.T) []*entity.MeasurementWithConsumption {
+	takenAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	first, err := entity.NewImportedMeasurement(100, "", "1", "878ab991-20b0-41c3-9c78-849744e8312a", takenAt)
+	assert.NoError(t, err)
+	second, err := entity.NewImportedMeasurement(112, "", "1", "878ab991-20b0-41c3-9c78-849744e8312a", takenAt.AddDate(0, 1, 0))
+	assert.NoError(t, err)
+	previous, consumption := 100, 12
+	return []*entity.MeasurementWithConsumption{
+		{Measurement: *first},
+		{Measurement: *second, PreviousValue: &previous, Consumption: &consumption},
+	}
+}
+
+func export(t *testing.T, format string) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	assert.NoError(t, err)
+	for _, m := range measurements(t) {
+		assert.NoError(t, w.Write(m))
+	}
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestCSVWriter(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(export(t, FormatCSV))).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, columns, records[0])
+	assert.Equal(t, "", records[1][5])
+	assert.Equal(t, "100", records[2][4])
+	assert.Equal(t, "12", records[2][5])
+	assert.Equal(t, "2024-03-01T00:00:00Z", records[2][9])
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	lines := bytes.Split(bytes.TrimSpace(export(t, FormatNDJSON)), []byte("\n"))
+	assert.Len(t, lines, 2)
+	var row map[string]any
+	assert.NoError(t, json.Unmarshal(lines[1], &row))
+	assert.Equal(t, float64(112), row["value"])
+	assert.Equal(t, float64(12), row["consumption"])
+}
+
+func TestXLSXWriter(t *testing.T) {
+	data := export(t, FormatXLSX)
+	assert.Equal(t, []byte("PK"), data[:2])
+}
+
+func TestNewWriterWithUnsupportedFormat(t *testing.T) {
+	_, err := NewWriter("pdf", &bytes.Buffer{})
+	assert.Equal(t, ErrUnsupportedFormat, err)
+}
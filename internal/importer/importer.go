@@ -0,0 +1,183 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+
+	maxLineSize = 1 << 20
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported import format")
+	ErrMissingColumn     = errors.New("missing column")
+	ErrEmptyImport       = errors.New("import has no rows")
+)
+
+var requiredColumns = []string{"user", "type", "value", "date"}
+
+// RowError reports a row that could not be decoded. Reading can go on with
+// the next row.
+type RowError struct {
+	Field string
+	Err   error
+}
+
+func (e *RowError) Error() string {
+	if e.Field == "" {
+		return e.Err.Error()
+	}
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Reader decodes import rows one at a time so large files are never held in
+// memory. Read returns io.EOF after the last row, a *RowError for a row that
+// cannot be decoded and any other error when the input cannot be read any
+// further.
+type Reader interface {
+	Read() (dto.ImportMeasurementRow, error)
+}
+
+// Format returns the import format for a Content-Type header.
+func Format(contentType string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", ErrUnsupportedFormat
+	}
+	switch mediaType {
+	case "text/csv":
+		return FormatCSV, nil
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return FormatNDJSON, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVReader(r)
+	case FormatNDJSON:
+		return NewNDJSONReader(r), nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// CSVReader reads rows from CSV with a header line. Columns are matched by
+// name, in any order and case; unknown columns are ignored.
+type CSVReader struct {
+	csv     *csv.Reader
+	columns map[string]int
+}
+
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrEmptyImport
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, name)
+		}
+	}
+	return &CSVReader{csv: reader, columns: columns}, nil
+}
+
+func (c *CSVReader) Read() (dto.ImportMeasurementRow, error) {
+	var row dto.ImportMeasurementRow
+	record, err := c.csv.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return row, &RowError{Err: parseErr.Err}
+		}
+		return row, err
+	}
+	row.User = c.field(record, "user")
+	row.Type = c.field(record, "type")
+	row.Date = c.field(record, "date")
+	row.Image = c.field(record, "image")
+	if value := c.field(record, "value"); value != "" {
+		row.Value, err = strconv.Atoi(value)
+		if err != nil {
+			return row, &RowError{Field: "value", Err: errors.New("must be an integer")}
+		}
+	}
+	return row, nil
+}
+
+func (c *CSVReader) field(record []string, name string) string {
+	i, ok := c.columns[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// NDJSONReader reads one JSON object per line. Blank lines are skipped.
+type NDJSONReader struct {
+	scanner *bufio.Scanner
+}
+
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &NDJSONReader{scanner: scanner}
+}
+
+func (n *NDJSONReader) Read() (dto.ImportMeasurementRow, error) {
+	var row dto.ImportMeasurementRow
+	for n.scanner.Scan() {
+		line := bytes.TrimSpace(n.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				return row, &RowError{Field: typeErr.Field, Err: errors.New("must be a " + typeErr.Type.String())}
+			}
+			return row, &RowError{Err: err}
+		}
+		return row, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return row, err
+	}
+	return row, io.EOF
+}
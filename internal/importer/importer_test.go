@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
+	"github.com/stretchr/testify/assert"
+)
+
+const user = "878ab991-20b0-41c3-9c78-849744e8312a"
+
+func TestFormat(t *testing.T) {
+	format, err := Format("text/csv; charset=utf-8")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatCSV, format)
+
+	format, err = Format("application/x-ndjson")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatNDJSON, format)
+
+	_, err = Format("application/json")
+	assert.Equal(t, ErrUnsupportedFormat, err)
+}
+
+func TestCSVReader(t *testing.T) {
+	input := "\ufeffDate,Value,Type,User,Image,Notes\n" +
+		"2021-03-01," + "19,1," + user + ",,moved in\n" +
+		"2021-04-01,abc,1," + user + ",,\n" +
+		"2021-05-01,21\n" +
+		"2021-06-01,22,2," + user + ",https://example.com/a.jpg,\n"
+	reader, err := NewCSVReader(strings.NewReader(input))
+	assert.NoError(t, err)
+
+	row, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, dto.ImportMeasurementRow{User: user, Type: "1", Value: 19, Date: "2021-03-01"}, row)
+
+	_, err = reader.Read()
+	var rowErr *RowError
+	assert.True(t, errors.As(err, &rowErr))
+	assert.Equal(t, "value", rowErr.Field)
+
+	_, err = reader.Read()
+	assert.True(t, errors.As(err, &rowErr))
+
+	row, err = reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/a.jpg", row.Image)
+
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestCSVReaderWithMissingColumn(t *testing.T) {
+	_, err := NewCSVReader(strings.NewReader("user,type,value\n"))
+	assert.ErrorIs(t, err, ErrMissingColumn)
+
+	_, err = NewCSVReader(strings.NewReader(""))
+	assert.Equal(t, ErrEmptyImport, err)
+}
+
+func TestNDJSONReader(t *testing.T) {
+	input := `{"user":"` + user + `","type":"2","value":19,"date":"2021-03-01T10:00:00Z"}` + "\n\n" +
+		`{"user":"` + user + `","type":"2","value":"19","date":"2021-04-01"}` + "\n" +
+		`{"meter":"1"}` + "\n"
+	reader := NewNDJSONReader(strings.NewReader(input))
+
+	row, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, dto.ImportMeasurementRow{User: user, Type: "2", Value: 19, Date: "2021-03-01T10:00:00Z"}, row)
+
+	_, err = reader.Read()
+	var rowErr *RowError
+	assert.True(t, errors.As(err, &rowErr))
+	assert.Equal(t, "value", rowErr.Field)
+
+	_, err = reader.Read()
+	assert.True(t, errors.As(err, &rowErr))
+
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const alertEntity = "alert"
+
+// AlertFilter narrows alert queries. Zero fields do not filter; From and To
+// bound the time of the reading that raised the alert.
+type AlertFilter struct {
+	User     string
+	Type     string
+	Kind     string
+	Severity string
+	From     time.Time
+	To       time.Time
+}
+
+type Alert struct {
+	DB *gorm.DB
+}
+
+func NewAlert(db *gorm.DB) *Alert {
+	return &Alert{
+		DB: db,
+	}
+}
+
+// Create stores alert. A reading raises at most one alert, so a second one
+// for the same measurement fails with ErrAlreadyExists.
+func (a *Alert) Create(alert *entity.Alert, ctx context.Context) error {
+	return a.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(alert)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyExists
+		}
+		return recordAuditEvent(tx, entity.AuditActionCreate, alertEntity, alert.ID.String(), nil, alert, ctx)
+	})
+}
+
+func (a *Alert) FindAll(filter AlertFilter, page, limit int, sort string, ctx context.Context) ([]entity.Alert, error) {
+	var alerts []entity.Alert
+	if sort != "asc" && sort != "desc" {
+		sort = "desc"
+	}
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 10
+	}
+	query := a.DB.WithContext(ctx).Model(&entity.Alert{})
+	if filter.User != "" {
+		query = query.Where(clause.Eq{Column: clause.Column{Name: "user"}, Value: filter.User})
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.Severity != "" {
+		query = query.Where("severity = ?", filter.Severity)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("reading_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("reading_at <= ?", filter.To)
+	}
+	err := query.Order("reading_at " + sort).Order("id").Offset((page - 1) * limit).Limit(limit).Find(&alerts).Error
+	return alerts, err
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	entityPkg "github.com/melkzsiqueira/water-gas-measurement/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAlert(t *testing.T, user, measurementType, kind, severity string, takenAt time.Time) *entity.Alert {
+	reading, err := entity.NewImportedMeasurement(100, "", measurementType, user, takenAt)
+	assert.NoError(t, err)
+	alert, err := entity.NewAlert(reading, kind, severity, 90, 3, 1, 20)
+	assert.NoError(t, err)
+	return alert
+}
+
+func TestCreateAlert(t *testing.T) {
+	alertDB := NewAlert(newTestDB(t, &entity.Alert{}, &entity.AuditEvent{}))
+	alert := newTestAlert(t, "878ab991-20b0-41c3-9c78-849744e8312a", "1", entity.AlertKindSpike, entity.AlertSeverityCritical, time.Now())
+	assert.NoError(t, alertDB.Create(alert, context.Background()))
+
+	again := *alert
+	again.ID = entityPkg.NewID()
+	assert.Equal(t, ErrAlreadyExists, alertDB.Create(&again, context.Background()))
+}
+
+func TestFindAllAlerts(t *testing.T) {
+	alertDB := NewAlert(newTestDB(t, &entity.Alert{}, &entity.AuditEvent{}))
+	user := "878ab991-20b0-41c3-9c78-849744e8312a"
+	start := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	alerts := []*entity.Alert{
+		newTestAlert(t, user, "1", entity.AlertKindSpike, entity.AlertSeverityCritical, start),
+		newTestAlert(t, user, "1", entity.AlertKindZero, entity.AlertSeverityWarning, start.AddDate(0, 1, 0)),
+		newTestAlert(t, user, "2", entity.AlertKindDrop, entity.AlertSeverityWarning, start.AddDate(0, 2, 0)),
+		newTestAlert(t, "3c20c52e-8db9-444a-b6b0-f56dd27b0400", "1", entity.AlertKindSpike, entity.AlertSeverityWarning, start.AddDate(0, 3, 0)),
+	}
+	for _, alert := range alerts {
+		assert.NoError(t, alertDB.Create(alert, context.Background()))
+	}
+
+	found, err := alertDB.FindAll(AlertFilter{}, 1, 10, "", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, found, 4)
+	assert.Equal(t, alerts[3].ID, found[0].ID)
+
+	found, err = alertDB.FindAll(AlertFilter{User: user, Type: "1"}, 1, 10, "asc", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, found, 2)
+	assert.Equal(t, alerts[0].ID, found[0].ID)
+
+	found, err = alertDB.FindAll(AlertFilter{Severity: entity.AlertSeverityWarning, Kind: entity.AlertKindSpike}, 1, 10, "", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, alerts[3].ID, found[0].ID)
+
+	found, err = alertDB.FindAll(AlertFilter{From: start.AddDate(0, 1, 0), To: start.AddDate(0, 2, 0)}, 1, 10, "asc", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, found, 2)
+	assert.Equal(t, alerts[1].ID, found[0].ID)
+}
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/analytics"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"gorm.io/gorm"
+)
+
+// Date arithmetic is the only part of the analytics queries that differs
+// between PostgreSQL and SQLite. Months are numbered as analytics.MonthIndex
+// does, in UTC, and days are fractional.
+const (
+	postgresMonth = "(EXTRACT(YEAR FROM created_at AT TIME ZONE 'UTC') * 12 + EXTRACT(MONTH FROM created_at AT TIME ZONE 'UTC') - 1)::integer"
+	postgresDays  = "EXTRACT(EPOCH FROM created_at - previous_at)::float8 / 86400"
+	sqliteMonth   = "(CAST(strftime('%Y', created_at) AS INTEGER) * 12 + CAST(strftime('%m', created_at) AS INTEGER) - 1)"
+	sqliteDays    = "(julianday(created_at) - julianday(previous_at))"
+)
+
+// AnalyticsFilter selects the months, as month indexes, aggregated for the
+// meters of a measurement type. An empty User aggregates every user.
+type AnalyticsFilter struct {
+	User string
+	Type string
+	From int
+	To   int
+}
+
+type Analytics struct {
+	DB *gorm.DB
+}
+
+func NewAnalytics(db *gorm.DB) *Analytics {
+	return &Analytics{
+		DB: db,
+	}
+}
+
+type trendRow struct {
+	analytics.Monthly
+	WindowConsumption int64
+	WindowDays        float64
+}
+
+type comparisonRow struct {
+	analytics.Monthly
+	PreviousConsumption *int64
+	PreviousDays        *float64
+	PreviousReadings    *int64
+}
+
+type rankRow struct {
+	DailyAverage float64
+	Position     int64
+	Total        int64
+	Mean         float64
+}
+
+// Consumption returns the months of filter that have consumption, oldest
+// first.
+func (a *Analytics) Consumption(filter AnalyticsFilter, ctx context.Context) ([]analytics.Monthly, error) {
+	var months []analytics.Monthly
+	err := a.monthly(filter.User, filter.Type, filter.From, filter.To, ctx).
+		Order("month").
+		Scan(&months).Error
+	return months, err
+}
+
+// MovingAverage returns the months of filter with the daily average of the
+// window months ending in each of them. The window spans calendar months,
+// so months without readings shorten it instead of reaching further back.
+func (a *Analytics) MovingAverage(filter AnalyticsFilter, window int, ctx context.Context) ([]analytics.Trend, error) {
+	frame := fmt.Sprintf("OVER (ORDER BY month RANGE BETWEEN %d PRECEDING AND CURRENT ROW)", window-1)
+	monthly := a.monthly(filter.User, filter.Type, filter.From-window+1, filter.To, ctx)
+	windows := a.DB.WithContext(ctx).
+		Table("(?) AS monthly", monthly).
+		Select("*, SUM(consumption) " + frame + " AS window_consumption, SUM(days) " + frame + " AS window_days")
+
+	var rows []trendRow
+	err := a.DB.WithContext(ctx).
+		Table("(?) AS windows", windows).
+		Where("month >= ?", filter.From).
+		Order("month").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	trends := make([]analytics.Trend, 0, len(rows))
+	for _, row := range rows {
+		trends = append(trends, analytics.Trend{
+			Usage:         usage(row.Monthly),
+			MovingAverage: analytics.DailyAverage(row.WindowConsumption, row.WindowDays),
+		})
+	}
+	return trends, nil
+}
+
+// YearOverYear returns the months of filter next to the same months of the
+// year before.
+func (a *Analytics) YearOverYear(filter AnalyticsFilter, ctx context.Context) ([]analytics.Comparison, error) {
+	monthly := a.monthly(filter.User, filter.Type, filter.From-12, filter.To, ctx)
+
+	var rows []comparisonRow
+	err := a.DB.WithContext(ctx).
+		Table("(?) AS this_year", monthly).
+		Select("this_year.*, last_year.consumption AS previous_consumption, last_year.days AS previous_days, last_year.readings AS previous_readings").
+		Joins("LEFT JOIN (?) AS last_year ON last_year.month = this_year.month - 12", monthly).
+		Where("this_year.month >= ?", filter.From).
+		Order("this_year.month").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	comparisons := make([]analytics.Comparison, 0, len(rows))
+	for _, row := range rows {
+		var previous *analytics.Usage
+		if row.PreviousConsumption != nil {
+			last := usage(analytics.Monthly{
+				Month:       row.Month - 12,
+				Consumption: *row.PreviousConsumption,
+				Days:        *row.PreviousDays,
+				Readings:    *row.PreviousReadings,
+			})
+			previous = &last
+		}
+		comparisons = append(comparisons, analytics.Compare(usage(row.Monthly), previous))
+	}
+	return comparisons, nil
+}
+
+// Percentiles ranks the daily average of every meter of measurementType in
+// month. Only the rows at the requested ranks are read back.
+func (a *Analytics) Percentiles(measurementType string, month int, ranks []int, ctx context.Context) (*analytics.Distribution, error) {
+	db := a.DB.WithContext(ctx)
+	meters := db.Table("(?) AS intervals", a.intervals("", measurementType, month, month, ctx)).
+		Select(`"user", SUM(consumption) * 1.0 / SUM(days) AS daily_average`).
+		Where("month = ?", month).
+		Group("user").
+		Having("SUM(days) > 0")
+	ranked := db.Table("(?) AS meters", meters).
+		Select("daily_average, ROW_NUMBER() OVER (ORDER BY daily_average) AS position, COUNT(*) OVER () AS total, AVG(daily_average) OVER () AS mean")
+
+	// The nearest rank of each percentile depends on the number of meters,
+	// which every ranked row carries.
+	positions := make([]string, len(ranks))
+	for i, rank := range ranks {
+		positions[i] = fmt.Sprintf("(total * %d + 99) / 100", rank)
+	}
+	var rows []rankRow
+	err := db.Table("(?) AS ranked", ranked).
+		Where("position IN (" + strings.Join(positions, ", ") + ")").
+		Order("position").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	distribution := &analytics.Distribution{
+		Period:      analytics.Label(month, analytics.Month),
+		Percentiles: []analytics.Percentile{},
+	}
+	if len(rows) == 0 {
+		return distribution, nil
+	}
+	distribution.Meters = rows[0].Total
+	distribution.Mean = analytics.Round(rows[0].Mean)
+	for _, rank := range ranks {
+		position := analytics.NearestRank(rank, distribution.Meters)
+		for _, row := range rows {
+			if row.Position == position {
+				distribution.Percentiles = append(distribution.Percentiles, analytics.Percentile{
+					Rank:         rank,
+					DailyAverage: analytics.Round(row.DailyAverage),
+				})
+				break
+			}
+		}
+	}
+	return distribution, nil
+}
+
+// monthly adds up the intervals of each month from one to another.
+func (a *Analytics) monthly(user, measurementType string, from, to int, ctx context.Context) *gorm.DB {
+	return a.DB.WithContext(ctx).
+		Table("(?) AS intervals", a.intervals(user, measurementType, from, to, ctx)).
+		Select("month, SUM(consumption) AS consumption, SUM(days) AS days, COUNT(*) AS readings").
+		Where("month BETWEEN ? AND ?", from, to).
+		Group("month")
+}
+
+// intervals pairs every confirmed reading taken from the start of month
+// since to the end of month until with the previous one of the same meter.
+// Readings lower than the previous one, such as after a meter replacement,
+// are left out. Only the readings of those months and the last one of each
+// meter before them are scanned, which the (user, type, created_at) index
+// serves.
+func (a *Analytics) intervals(user, measurementType string, since, until int, ctx context.Context) *gorm.DB {
+	db := a.DB.WithContext(ctx)
+	confirmed := true
+	filter := MeasurementFilter{User: user, Type: measurementType, Confirmed: &confirmed}
+	start, end := analytics.PeriodOf(since).Start(), analytics.PeriodOf(until).End()
+
+	inRange := filter.apply(db.Model(&entity.Measurement{})).
+		Select(`"user", value, created_at, id`).
+		Where("created_at >= ? AND created_at < ?", start, end)
+	bounds := filter.apply(db.Model(&entity.Measurement{})).
+		Select(`"user" AS meter, MAX(created_at) AS since`).
+		Where("created_at < ?", start).
+		Group("user")
+	previous := filter.apply(db.Model(&entity.Measurement{})).
+		Select(`"user", value, created_at, id`).
+		Joins(`JOIN (?) AS bounds ON bounds.meter = measurements."user" AND bounds.since = measurements.created_at`, bounds)
+	readings := db.Table("(? UNION ALL ?) AS readings", inRange, previous).
+		Select(`"user", value, created_at, ` +
+			`LAG(value) OVER (PARTITION BY "user" ORDER BY created_at, id) AS previous_value, ` +
+			`LAG(created_at) OVER (PARTITION BY "user" ORDER BY created_at, id) AS previous_at`)
+
+	month, days := postgresMonth, postgresDays
+	if a.DB.Dialector.Name() == "sqlite" {
+		month, days = sqliteMonth, sqliteDays
+	}
+	return db.Table("(?) AS readings", readings).
+		Select(`"user", ` + month + ` AS month, value - previous_value AS consumption, ` + days + ` AS days`).
+		Where("previous_value IS NOT NULL AND value >= previous_value")
+}
+
+func usage(month analytics.Monthly) analytics.Usage {
+	return analytics.NewUsage(analytics.Label(month.Month, analytics.Month), month.Consumption, month.Days, month.Readings)
+}
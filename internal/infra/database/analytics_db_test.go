@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/analytics"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/billing"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	analyticsUser  = "878ab991-20b0-41c3-9c78-849744e8312a"
+	analyticsOther = "5b6f2d1e-4a7c-4b8e-9d3f-2c1a0e9b8d7f"
+)
+
+func newAnalyticsDB(t *testing.T) *Analytics {
+	db := newTestDB(t, &entity.Measurement{})
+
+	readings := []struct {
+		user      string
+		kind      string
+		value     int
+		takenAt   time.Time
+		confirmed bool
+	}{
+		{analyticsUser, "1", 10, analyticsDate(2023, time.February, 1), true},
+		{analyticsUser, "1", 66, analyticsDate(2023, time.March, 1), true},
+		{analyticsUser, "1", 100, analyticsDate(2024, time.January, 1), true},
+		{analyticsUser, "1", 162, analyticsDate(2024, time.February, 1), true},
+		{analyticsUser, "1", 191, analyticsDate(2024, time.March, 1), true},
+		{analyticsUser, "1", 206, analyticsDate(2024, time.March, 16), true},
+		{analyticsUser, "1", 300, analyticsDate(2024, time.April, 1), false},
+		// The meter was replaced.
+		{analyticsUser, "1", 90, analyticsDate(2024, time.May, 1), true},
+		{analyticsUser, "1", 121, analyticsDate(2024, time.June, 1), true},
+		{analyticsOther, "1", 10, analyticsDate(2024, time.February, 1), true},
+		{analyticsOther, "1", 68, analyticsDate(2024, time.March, 1), true},
+		{analyticsOther, "2", 10, analyticsDate(2024, time.February, 1), true},
+		{analyticsOther, "2", 900, analyticsDate(2024, time.March, 1), true},
+	}
+	for _, reading := range readings {
+		m, err := entity.NewImportedMeasurement(reading.value, "", reading.kind, reading.user, reading.takenAt)
+		assert.NoError(t, err)
+		m.Confirmed = reading.confirmed
+		assert.NoError(t, db.Create(m).Error)
+	}
+	return NewAnalytics(db)
+}
+
+func analyticsDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
+}
+
+func month(year int, m time.Month) int {
+	return analytics.MonthIndex(billing.Period{Year: year, Month: m})
+}
+
+func TestAnalyticsConsumption(t *testing.T) {
+	analyticsDB := newAnalyticsDB(t)
+	assert.True(t, analyticsDB.DB.Migrator().HasIndex(&entity.Measurement{}, "idx_measurements_meter"))
+
+	months, err := analyticsDB.Consumption(AnalyticsFilter{Type: "1", From: month(2024, time.February), To: month(2024, time.March)}, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []analytics.Monthly{
+		{Month: month(2024, time.February), Consumption: 62, Days: 31, Readings: 1},
+		{Month: month(2024, time.March), Consumption: 102, Days: 73, Readings: 3},
+	}, months)
+
+	months, err = analyticsDB.Consumption(AnalyticsFilter{User: analyticsUser, Type: "1", From: month(2024, time.January), To: month(2024, time.December)}, context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, months, 4)
+	assert.Equal(t, analytics.Monthly{Month: month(2024, time.January), Consumption: 34, Days: 306, Readings: 1}, months[0])
+	assert.Equal(t, analytics.Monthly{Month: month(2024, time.June), Consumption: 31, Days: 31, Readings: 1}, months[3])
+}
+
+func TestAnalyticsMovingAverage(t *testing.T) {
+	analyticsDB := newAnalyticsDB(t)
+
+	trends, err := analyticsDB.MovingAverage(AnalyticsFilter{User: analyticsUser, Type: "1", From: month(2024, time.March), To: month(2024, time.June)}, 3, context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, trends, 2)
+	assert.Equal(t, "2024-03", trends[0].Period)
+	assert.Equal(t, 1.0, trends[0].DailyAverage)
+	// January to March: 140 over 381 days.
+	assert.Equal(t, 0.367, trends[0].MovingAverage)
+	assert.Equal(t, "2024-06", trends[1].Period)
+	assert.Equal(t, 1.0, trends[1].MovingAverage)
+}
+
+func TestAnalyticsYearOverYear(t *testing.T) {
+	analyticsDB := newAnalyticsDB(t)
+
+	comparisons, err := analyticsDB.YearOverYear(AnalyticsFilter{User: analyticsUser, Type: "1", From: month(2024, time.February), To: month(2024, time.March)}, context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, comparisons, 2)
+	assert.Equal(t, "2024-02", comparisons[0].Period)
+	assert.Nil(t, comparisons[0].PreviousYear)
+	assert.Nil(t, comparisons[0].Change)
+	assert.Equal(t, "2024-03", comparisons[1].Period)
+	assert.Equal(t, &analytics.Usage{Period: "2023-03", Consumption: 56, Days: 28, Readings: 1, DailyAverage: 2}, comparisons[1].PreviousYear)
+	assert.Equal(t, -50.0, *comparisons[1].Change)
+}
+
+func TestAnalyticsPercentiles(t *testing.T) {
+	analyticsDB := newAnalyticsDB(t)
+
+	distribution, err := analyticsDB.Percentiles("1", month(2024, time.March), []int{50, 100}, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &analytics.Distribution{
+		Period: "2024-03",
+		Meters: 2,
+		Mean:   1.5,
+		Percentiles: []analytics.Percentile{
+			{Rank: 50, DailyAverage: 1},
+			{Rank: 100, DailyAverage: 2},
+		},
+	}, distribution)
+
+	distribution, err = analyticsDB.Percentiles("1", month(2022, time.March), analytics.DefaultRanks, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), distribution.Meters)
+	assert.Empty(t, distribution.Percentiles)
+}
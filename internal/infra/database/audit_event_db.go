@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/audit"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"gorm.io/gorm"
+)
+
+type AuditEventFilter struct {
+	Actor    string
+	Action   string
+	Entity   string
+	EntityID string
+	From     time.Time
+	To       time.Time
+}
+
+type AuditEvent struct {
+	DB *gorm.DB
+}
+
+func NewAuditEvent(db *gorm.DB) *AuditEvent {
+	return &AuditEvent{
+		DB: db,
+	}
+}
+
+func (a *AuditEvent) Create(event *entity.AuditEvent, ctx context.Context) error {
+	return a.DB.WithContext(ctx).Create(event).Error
+}
+
+func (a *AuditEvent) FindAll(filter AuditEventFilter, page, limit int, sort string, ctx context.Context) ([]entity.AuditEvent, error) {
+	var events []entity.AuditEvent
+	if sort != "asc" && sort != "desc" {
+		sort = "desc"
+	}
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 10
+	}
+	query := a.DB.WithContext(ctx).Model(&entity.AuditEvent{})
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Entity != "" {
+		query = query.Where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at <= ?", filter.To)
+	}
+	err := query.Order("created_at " + sort).Offset((page - 1) * limit).Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func recordAuditEvent(tx *gorm.DB, action, entityName, entityID string, before, after any, ctx context.Context) error {
+	event, err := newAuditEvent(action, entityName, entityID, before, after, ctx)
+	if err != nil {
+		return err
+	}
+	return NewAuditEvent(tx).Create(event, ctx)
+}
+
+func newAuditEvent(action, entityName, entityID string, before, after any, ctx context.Context) (*entity.AuditEvent, error) {
+	beforeJSON, afterJSON, err := audit.Diff(before, after)
+	if err != nil {
+		return nil, err
+	}
+	metadata := audit.FromContext(ctx)
+	return entity.NewAuditEvent(metadata.Actor, action, entityName, entityID, beforeJSON, afterJSON, metadata.RequestID, metadata.IP)
+}
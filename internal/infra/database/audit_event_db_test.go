@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/audit"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMeasurementChangesAreAudited(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	ctx := audit.NewContext(context.Background(), audit.Metadata{
+		Actor:     "878ab991-20b0-41c3-9c78-849744e8312a",
+		RequestID: "req-1",
+		IP:        "127.0.0.1",
+	})
+	measurement, err := entity.NewMeasurement(19, "image", "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.NoError(t, err)
+	measurementDB := NewMeasurement(db)
+	auditEventDB := NewAuditEvent(db)
+
+	err = measurementDB.Create(measurement, ctx)
+	assert.NoError(t, err)
+	measurement.Value = 20
+	measurement.Confirmed = true
+	err = measurementDB.Update(measurement, ctx)
+	assert.NoError(t, err)
+	err = measurementDB.Delete(measurement.ID.String(), ctx)
+	assert.NoError(t, err)
+
+	events, err := auditEventDB.FindAll(AuditEventFilter{EntityID: measurement.ID.String()}, 1, 10, "asc", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, entity.AuditActionCreate, events[0].Action)
+	assert.Equal(t, entity.JSON(""), events[0].Before)
+	assert.Equal(t, entity.AuditActionConfirm, events[1].Action)
+	assert.JSONEq(t, `{"value":19,"confirmed":false,"version":1}`, string(events[1].Before))
+	assert.JSONEq(t, `{"value":20,"confirmed":true,"version":2}`, string(events[1].After))
+	assert.Equal(t, entity.AuditActionDelete, events[2].Action)
+	assert.Equal(t, entity.JSON(""), events[2].After)
+	for _, event := range events {
+		assert.Equal(t, "878ab991-20b0-41c3-9c78-849744e8312a", event.Actor)
+		assert.Equal(t, "measurement", event.Entity)
+		assert.Equal(t, "req-1", event.RequestID)
+		assert.Equal(t, "127.0.0.1", event.IP)
+	}
+}
+
+func TestFindAllAuditEventsWithFilter(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.AuditEvent{})
+	auditEventDB := NewAuditEvent(db)
+	for _, action := range []string{entity.AuditActionCreate, entity.AuditActionUpdate, entity.AuditActionUpdate} {
+		event, err := entity.NewAuditEvent("878ab991-20b0-41c3-9c78-849744e8312a", action, "measurement", "3c20c52e-8db9-444a-b6b0-f56dd27b0400", "", "", "", "")
+		assert.NoError(t, err)
+		assert.NoError(t, auditEventDB.Create(event, context.Background()))
+	}
+
+	events, err := auditEventDB.FindAll(AuditEventFilter{Action: entity.AuditActionUpdate}, 1, 10, "desc", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	events, err = auditEventDB.FindAll(AuditEventFilter{Actor: "3c20c52e-8db9-444a-b6b0-f56dd27b0400"}, 1, 10, "desc", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, events, 0)
+}
+
+func TestAuditEventIsImmutable(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.AuditEvent{})
+	event, err := entity.NewAuditEvent("", entity.AuditActionCreate, "measurement", "3c20c52e-8db9-444a-b6b0-f56dd27b0400", "", "", "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, NewAuditEvent(db).Create(event, context.Background()))
+
+	event.Action = entity.AuditActionDelete
+	assert.ErrorIs(t, db.Save(event).Error, entity.ErrAuditEventIsImmutable)
+	assert.ErrorIs(t, db.Delete(event).Error, entity.ErrAuditEventIsImmutable)
+}
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotFound        = errors.New("record not found")
+	ErrVersionConflict = errors.New("version conflict")
+	ErrAlreadyExists   = errors.New("record already exists")
+)
+
+// translate converts GORM errors into the repository sentinel errors so
+// callers can tell a missing record apart from a failing database.
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
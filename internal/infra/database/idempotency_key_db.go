@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyKey struct {
+	DB *gorm.DB
+}
+
+func NewIdempotencyKey(db *gorm.DB) *IdempotencyKey {
+	return &IdempotencyKey{
+		DB: db,
+	}
+}
+
+// Reserve inserts key unless an unexpired entry for the same user and key
+// already exists, and reports whether it did. An entry that was never
+// completed is taken over once its lease has run out, since the request
+// holding it is gone. The primary key makes this safe across replicas: only
+// one concurrent request can reserve a key.
+func (i *IdempotencyKey) Reserve(key *entity.IdempotencyKey, ctx context.Context) (bool, error) {
+	db := i.DB.WithContext(ctx)
+	now := time.Now()
+	err := byUserAndKey(db, key.User, key.Key).
+		Where(db.Where("expires_at <= ?", now).Or("response_status = 0 AND locked_until <= ?", now)).
+		Delete(&entity.IdempotencyKey{}).Error
+	if err != nil {
+		return false, err
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (i *IdempotencyKey) Find(user, key string, ctx context.Context) (*entity.IdempotencyKey, error) {
+	var idempotencyKey entity.IdempotencyKey
+	err := byUserAndKey(i.DB.WithContext(ctx), user, key).Where("expires_at > ?", time.Now()).First(&idempotencyKey).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &idempotencyKey, nil
+}
+
+// Complete stores the response of a reserved key.
+func (i *IdempotencyKey) Complete(key *entity.IdempotencyKey, ctx context.Context) error {
+	result := i.DB.WithContext(ctx).Model(key).UpdateColumns(map[string]any{
+		"response_status": key.ResponseStatus,
+		"response_header": key.ResponseHeader,
+		"response_body":   key.ResponseBody,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete releases a key so the request can be retried.
+func (i *IdempotencyKey) Delete(user, key string, ctx context.Context) error {
+	return byUserAndKey(i.DB.WithContext(ctx), user, key).Delete(&entity.IdempotencyKey{}).Error
+}
+
+func (i *IdempotencyKey) DeleteExpired(ctx context.Context) (int64, error) {
+	result := i.DB.WithContext(ctx).Where("expires_at <= ?", time.Now()).Delete(&entity.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
+
+// byUserAndKey quotes the column names since user is a reserved word in
+// PostgreSQL.
+func byUserAndKey(db *gorm.DB, user, key string) *gorm.DB {
+	return db.Where(clause.Eq{Column: clause.Column{Name: "user"}, Value: user}).
+		Where(clause.Eq{Column: clause.Column{Name: "key"}, Value: key})
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+const idempotencyUser = "878ab991-20b0-41c3-9c78-849744e8312a"
+
+func TestReserveIdempotencyKey(t *testing.T) {
+	keys := NewIdempotencyKey(newTestDB(t, &entity.IdempotencyKey{}))
+	ctx := context.Background()
+	first, err := entity.NewIdempotencyKey(idempotencyUser, "retry-1", "hash", time.Hour, time.Minute)
+	assert.NoError(t, err)
+	second, err := entity.NewIdempotencyKey(idempotencyUser, "retry-1", "other", time.Hour, time.Minute)
+	assert.NoError(t, err)
+	other, err := entity.NewIdempotencyKey("3c20c52e-8db9-444a-b6b0-f56dd27b0400", "retry-1", "hash", time.Hour, time.Minute)
+	assert.NoError(t, err)
+
+	reserved, err := keys.Reserve(first, ctx)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+	reserved, err = keys.Reserve(second, ctx)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+	reserved, err = keys.Reserve(other, ctx)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	found, err := keys.Find(idempotencyUser, "retry-1", ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "hash", found.RequestHash)
+	assert.False(t, found.Completed())
+}
+
+func TestReserveExpiredIdempotencyKey(t *testing.T) {
+	keys := NewIdempotencyKey(newTestDB(t, &entity.IdempotencyKey{}))
+	ctx := context.Background()
+	expired, err := entity.NewIdempotencyKey(idempotencyUser, "retry-1", "hash", -time.Second, time.Minute)
+	assert.NoError(t, err)
+	_, err = keys.Reserve(expired, ctx)
+	assert.NoError(t, err)
+
+	_, err = keys.Find(idempotencyUser, "retry-1", ctx)
+	assert.Equal(t, ErrNotFound, err)
+
+	key, err := entity.NewIdempotencyKey(idempotencyUser, "retry-1", "other", time.Hour, time.Minute)
+	assert.NoError(t, err)
+	reserved, err := keys.Reserve(key, ctx)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+}
+
+func TestReserveAbandonedIdempotencyKey(t *testing.T) {
+	keys := NewIdempotencyKey(newTestDB(t, &entity.IdempotencyKey{}))
+	ctx := context.Background()
+	abandoned, err := entity.NewIdempotencyKey(idempotencyUser, "retry-1", "hash", time.Hour, -time.Second)
+	assert.NoError(t, err)
+	completed, err := entity.NewIdempotencyKey(idempotencyUser, "retry-2", "hash", time.Hour, -time.Second)
+	assert.NoError(t, err)
+	keys.Reserve(abandoned, ctx)
+	keys.Reserve(completed, ctx)
+	completed.Complete(201, `{}`, `{"value":19}`)
+	assert.NoError(t, keys.Complete(completed, ctx))
+
+	// The request holding retry-1 died before completing it.
+	key, err := entity.NewIdempotencyKey(idempotencyUser, "retry-1", "hash", time.Hour, time.Minute)
+	assert.NoError(t, err)
+	reserved, err := keys.Reserve(key, ctx)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	// Completed keys are kept until they expire.
+	key, err = entity.NewIdempotencyKey(idempotencyUser, "retry-2", "hash", time.Hour, time.Minute)
+	assert.NoError(t, err)
+	reserved, err = keys.Reserve(key, ctx)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+}
+
+func TestCompleteAndDeleteIdempotencyKey(t *testing.T) {
+	keys := NewIdempotencyKey(newTestDB(t, &entity.IdempotencyKey{}))
+	ctx := context.Background()
+	key, err := entity.NewIdempotencyKey(idempotencyUser, "retry-1", "hash", time.Hour, time.Minute)
+	assert.NoError(t, err)
+	_, err = keys.Reserve(key, ctx)
+	assert.NoError(t, err)
+
+	key.Complete(201, `{"Content-Type":["application/json"]}`, `{"value":19}`)
+	assert.NoError(t, keys.Complete(key, ctx))
+	found, err := keys.Find(idempotencyUser, "retry-1", ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 201, found.ResponseStatus)
+	assert.Equal(t, `{"value":19}`, found.ResponseBody)
+
+	assert.NoError(t, keys.Delete(idempotencyUser, "retry-1", ctx))
+	_, err = keys.Find(idempotencyUser, "retry-1", ctx)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrNotFound, keys.Complete(key, ctx))
+}
+
+func TestDeleteExpiredIdempotencyKeys(t *testing.T) {
+	keys := NewIdempotencyKey(newTestDB(t, &entity.IdempotencyKey{}))
+	ctx := context.Background()
+	expired, err := entity.NewIdempotencyKey(idempotencyUser, "retry-1", "hash", -time.Second, time.Minute)
+	assert.NoError(t, err)
+	live, err := entity.NewIdempotencyKey(idempotencyUser, "retry-2", "hash", time.Hour, time.Minute)
+	assert.NoError(t, err)
+	keys.Reserve(expired, ctx)
+	keys.Reserve(live, ctx)
+
+	deleted, err := keys.DeleteExpired(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
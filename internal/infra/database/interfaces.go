@@ -1,16 +1,81 @@
 package database
 
-import "github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+import (
+	"context"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/analytics"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+)
 
 type UserInterface interface {
-	Create(user *entity.User) error
-	FindByEmail(id string) (*entity.User, error)
+	Create(user *entity.User, ctx context.Context) error
+	FindByEmail(id string, ctx context.Context) (*entity.User, error)
+	FindById(id string, ctx context.Context) (*entity.User, error)
 }
 
 type MeasurementInterface interface {
-	Create(measurement *entity.Measurement) error
-	FindAll(page, limit int, sort string) ([]entity.Measurement, error)
-	FindById(id string) (*entity.Measurement, error)
-	Update(measurement *entity.Measurement) error
-	Delete(id string) error
+	Create(measurement *entity.Measurement, ctx context.Context) error
+	CreateMany(measurements []*entity.Measurement, ctx context.Context) error
+	FindAll(filter MeasurementFilter, page, limit int, sort string, ctx context.Context) ([]entity.Measurement, error)
+	StreamWithConsumption(filter MeasurementFilter, sort string, fn func(measurement *entity.MeasurementWithConsumption) error, ctx context.Context) error
+	FindById(id string, ctx context.Context) (*entity.Measurement, error)
+	FindByImageSHA256(hash string, ctx context.Context) (*entity.Measurement, error)
+	FindRecentByUser(user string, limit int, ctx context.Context) ([]entity.Measurement, error)
+	FindLastConfirmed(user, measurementType string, before time.Time, ctx context.Context) (*entity.Measurement, error)
+	Update(measurement *entity.Measurement, ctx context.Context) error
+	UpdateRenditions(measurement *entity.Measurement, ctx context.Context) error
+	Delete(id string, ctx context.Context) error
+}
+
+type Repositories struct {
+	Measurement MeasurementInterface
+	User        UserInterface
+}
+
+type UnitOfWorkInterface interface {
+	Do(fn func(repositories *Repositories) error, ctx context.Context) error
+}
+
+type AuditEventInterface interface {
+	Create(event *entity.AuditEvent, ctx context.Context) error
+	FindAll(filter AuditEventFilter, page, limit int, sort string, ctx context.Context) ([]entity.AuditEvent, error)
+}
+
+type OCRCacheInterface interface {
+	FindByKey(key string, ctx context.Context) (*entity.OCRCacheEntry, error)
+	Save(entry *entity.OCRCacheEntry, ctx context.Context) error
+	Prune(maxEntries int, ctx context.Context) (int64, error)
+}
+
+type IdempotencyKeyInterface interface {
+	Reserve(key *entity.IdempotencyKey, ctx context.Context) (bool, error)
+	Find(user, key string, ctx context.Context) (*entity.IdempotencyKey, error)
+	Complete(key *entity.IdempotencyKey, ctx context.Context) error
+	Delete(user, key string, ctx context.Context) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type TariffInterface interface {
+	Create(tariff *entity.Tariff, ctx context.Context) error
+	FindAll(measurementType string, ctx context.Context) ([]entity.Tariff, error)
+	FindById(id string, ctx context.Context) (*entity.Tariff, error)
+}
+
+type InvoiceInterface interface {
+	Create(invoice *entity.Invoice, ctx context.Context) error
+	FindAll(filter InvoiceFilter, page, limit int, sort string, ctx context.Context) ([]entity.Invoice, error)
+	FindById(id string, ctx context.Context) (*entity.Invoice, error)
+}
+
+type AnalyticsInterface interface {
+	Consumption(filter AnalyticsFilter, ctx context.Context) ([]analytics.Monthly, error)
+	MovingAverage(filter AnalyticsFilter, window int, ctx context.Context) ([]analytics.Trend, error)
+	YearOverYear(filter AnalyticsFilter, ctx context.Context) ([]analytics.Comparison, error)
+	Percentiles(measurementType string, month int, ranks []int, ctx context.Context) (*analytics.Distribution, error)
+}
+
+type AlertInterface interface {
+	Create(alert *entity.Alert, ctx context.Context) error
+	FindAll(filter AlertFilter, page, limit int, sort string, ctx context.Context) ([]entity.Alert, error)
 }
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const invoiceEntity = "invoice"
+
+// InvoiceFilter narrows invoice queries. Zero fields do not filter; From
+// and To are inclusive YYYY-MM periods.
+type InvoiceFilter struct {
+	User string
+	Type string
+	From string
+	To   string
+}
+
+type Invoice struct {
+	DB *gorm.DB
+}
+
+func NewInvoice(db *gorm.DB) *Invoice {
+	return &Invoice{
+		DB: db,
+	}
+}
+
+// Create stores invoice and its lines. A user is billed once per period and
+// type, so a second invoice for the same cycle fails with ErrAlreadyExists.
+func (i *Invoice) Create(invoice *entity.Invoice, ctx context.Context) error {
+	return i.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit("Lines").Clauses(clause.OnConflict{DoNothing: true}).Create(invoice)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyExists
+		}
+		err := tx.CreateInBatches(invoice.Lines, createBatchSize).Error
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(tx, entity.AuditActionCreate, invoiceEntity, invoice.ID.String(), nil, invoice, ctx)
+	})
+}
+
+func (i *Invoice) FindAll(filter InvoiceFilter, page, limit int, sort string, ctx context.Context) ([]entity.Invoice, error) {
+	var invoices []entity.Invoice
+	if sort != "asc" && sort != "desc" {
+		sort = "desc"
+	}
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 10
+	}
+	query := i.DB.WithContext(ctx).Model(&entity.Invoice{}).Preload("Lines", orderLines)
+	if filter.User != "" {
+		query = query.Where(clause.Eq{Column: clause.Column{Name: "user"}, Value: filter.User})
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.From != "" {
+		query = query.Where("period >= ?", filter.From)
+	}
+	if filter.To != "" {
+		query = query.Where("period <= ?", filter.To)
+	}
+	err := query.Order("period " + sort).Order("type").Offset((page - 1) * limit).Limit(limit).Find(&invoices).Error
+	return invoices, err
+}
+
+func (i *Invoice) FindById(id string, ctx context.Context) (*entity.Invoice, error) {
+	var invoice entity.Invoice
+	err := i.DB.WithContext(ctx).Preload("Lines", orderLines).First(&invoice, "id = ?", id).Error
+	return &invoice, translate(err)
+}
+
+func orderLines(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestInvoice(t *testing.T, user, measurementType, period string) *entity.Invoice {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tariff, err := entity.NewTariff("Residential", measurementType, "BRL", 1500, 0, []entity.TariffTier{{Price: 400}}, start, nil)
+	assert.NoError(t, err)
+	previous, err := entity.NewImportedMeasurement(100, "", measurementType, user, start)
+	assert.NoError(t, err)
+	current, err := entity.NewImportedMeasurement(110, "", measurementType, user, start.AddDate(0, 1, 0))
+	assert.NoError(t, err)
+	invoice, err := entity.NewInvoice(period, tariff, previous, current, []entity.InvoiceLine{
+		{Kind: entity.InvoiceLineConsumption, Description: "Consumption", Quantity: 10, UnitPrice: 400, Amount: 4000},
+		{Kind: entity.InvoiceLineFixedFee, Description: "Fixed fee", Quantity: 1, UnitPrice: 1500, Amount: 1500},
+	})
+	assert.NoError(t, err)
+	return invoice
+}
+
+func TestCreateInvoice(t *testing.T) {
+	invoiceDB := NewInvoice(newTestDB(t, &entity.Invoice{}, &entity.InvoiceLine{}, &entity.AuditEvent{}))
+	user := "878ab991-20b0-41c3-9c78-849744e8312a"
+	invoice := newTestInvoice(t, user, "1", "2024-02")
+	assert.NoError(t, invoiceDB.Create(invoice, context.Background()))
+
+	found, err := invoiceDB.FindById(invoice.ID.String(), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5500), found.Total)
+	assert.Len(t, found.Lines, 2)
+	assert.Equal(t, entity.InvoiceLineConsumption, found.Lines[0].Kind)
+	assert.Equal(t, entity.InvoiceLineFixedFee, found.Lines[1].Kind)
+
+	err = invoiceDB.Create(newTestInvoice(t, user, "1", "2024-02"), context.Background())
+	assert.Equal(t, ErrAlreadyExists, err)
+	assert.NoError(t, invoiceDB.Create(newTestInvoice(t, user, "2", "2024-02"), context.Background()))
+
+	_, err = invoiceDB.FindById("3c20c52e-8db9-444a-b6b0-f56dd27b0400", context.Background())
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestFindAllInvoices(t *testing.T) {
+	invoiceDB := NewInvoice(newTestDB(t, &entity.Invoice{}, &entity.InvoiceLine{}, &entity.AuditEvent{}))
+	user := "878ab991-20b0-41c3-9c78-849744e8312a"
+	for _, period := range []string{"2024-01", "2024-02", "2024-03"} {
+		assert.NoError(t, invoiceDB.Create(newTestInvoice(t, user, "1", period), context.Background()))
+	}
+	assert.NoError(t, invoiceDB.Create(newTestInvoice(t, "3c20c52e-8db9-444a-b6b0-f56dd27b0400", "1", "2024-02"), context.Background()))
+
+	invoices, err := invoiceDB.FindAll(InvoiceFilter{User: user, From: "2024-02"}, 1, 10, "desc", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, invoices, 2)
+	assert.Equal(t, "2024-03", invoices[0].Period)
+	assert.Len(t, invoices[0].Lines, 2)
+
+	invoices, err = invoiceDB.FindAll(InvoiceFilter{To: "2024-02"}, 1, 10, "asc", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, invoices, 3)
+	assert.Equal(t, "2024-01", invoices[0].Period)
+}
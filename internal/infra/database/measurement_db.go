@@ -1,10 +1,47 @@
 package database
 
 import (
+	"context"
+	"time"
+
 	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	measurementEntity = "measurement"
+	createBatchSize   = 100
 )
 
+// MeasurementFilter narrows measurement queries. Zero fields do not filter.
+type MeasurementFilter struct {
+	User      string
+	Type      string
+	Confirmed *bool
+	From      time.Time
+	To        time.Time
+}
+
+func (f MeasurementFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.User != "" {
+		query = query.Where(clause.Eq{Column: clause.Column{Name: "user"}, Value: f.User})
+	}
+	if f.Type != "" {
+		query = query.Where("type = ?", f.Type)
+	}
+	if f.Confirmed != nil {
+		query = query.Where("confirmed = ?", *f.Confirmed)
+	}
+	if !f.From.IsZero() {
+		query = query.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		query = query.Where("created_at <= ?", f.To)
+	}
+	return query
+}
+
 type Measurement struct {
 	DB *gorm.DB
 }
@@ -15,12 +52,40 @@ func NewMeasurement(db *gorm.DB) *Measurement {
 	}
 }
 
-func (m *Measurement) Create(measurement *entity.Measurement) error {
-	err := m.DB.Create(measurement).Error
-	return err
+func (m *Measurement) Create(measurement *entity.Measurement, ctx context.Context) error {
+	return m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(measurement).Error
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(tx, entity.AuditActionCreate, measurementEntity, measurement.ID.String(), nil, measurement, ctx)
+	})
 }
 
-func (m *Measurement) FindAll(page, limit int, sort string) ([]entity.Measurement, error) {
+// CreateMany inserts measurements in batches within a single transaction,
+// auditing each of them.
+func (m *Measurement) CreateMany(measurements []*entity.Measurement, ctx context.Context) error {
+	if len(measurements) == 0 {
+		return nil
+	}
+	return m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.CreateInBatches(measurements, createBatchSize).Error
+		if err != nil {
+			return err
+		}
+		events := make([]*entity.AuditEvent, 0, len(measurements))
+		for _, measurement := range measurements {
+			event, err := newAuditEvent(entity.AuditActionCreate, measurementEntity, measurement.ID.String(), nil, measurement, ctx)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return tx.CreateInBatches(events, createBatchSize).Error
+	})
+}
+
+func (m *Measurement) FindAll(filter MeasurementFilter, page, limit int, sort string, ctx context.Context) ([]entity.Measurement, error) {
 	var measurements []entity.Measurement
 	if sort != "asc" && sort != "desc" {
 		sort = "asc"
@@ -31,28 +96,138 @@ func (m *Measurement) FindAll(page, limit int, sort string) ([]entity.Measuremen
 	if limit == 0 {
 		limit = 10
 	}
-	err := m.DB.Order("created_at " + sort).Offset((page - 1) * limit).Limit(limit).Find(&measurements).Error
+	query := filter.apply(m.DB.WithContext(ctx).Model(&entity.Measurement{}))
+	err := query.Order("created_at " + sort).Offset((page - 1) * limit).Limit(limit).Find(&measurements).Error
 	return measurements, err
 }
 
-func (m *Measurement) FindById(id string) (*entity.Measurement, error) {
-	var measurement entity.Measurement
-	err := m.DB.First(&measurement, "id = ?", id).Error
-	return &measurement, err
-}
-
-func (m *Measurement) Update(measurement *entity.Measurement) error {
-	_, err := m.FindById(measurement.ID.String())
+// StreamWithConsumption calls fn for every measurement matching filter
+// together with the consumption since the previous reading of the same user
+// and type. Rows are read through a cursor so the result set is never held
+// in memory. The previous reading is looked up before the date filter is
+// applied, so the first row of a period still gets its consumption.
+func (m *Measurement) StreamWithConsumption(filter MeasurementFilter, sort string, fn func(measurement *entity.MeasurementWithConsumption) error, ctx context.Context) error {
+	if sort != "asc" && sort != "desc" {
+		sort = "asc"
+	}
+	db := m.DB.WithContext(ctx)
+	readings := MeasurementFilter{User: filter.User, Type: filter.Type, Confirmed: filter.Confirmed}.
+		apply(db.Model(&entity.Measurement{})).
+		Select("*, LAG(value) OVER (PARTITION BY \"user\", type ORDER BY created_at, id) AS previous_value")
+	query := MeasurementFilter{From: filter.From, To: filter.To}.
+		apply(db.Table("(?) AS measurements", readings)).
+		Select("*, value - previous_value AS consumption").
+		Order("created_at " + sort).
+		Order("id")
+	rows, err := query.Rows()
 	if err != nil {
 		return err
 	}
-	return m.DB.Save(&measurement).Error
+	defer rows.Close()
+	for rows.Next() {
+		var measurement entity.MeasurementWithConsumption
+		if err := db.ScanRows(rows, &measurement); err != nil {
+			return err
+		}
+		if err := fn(&measurement); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
 
-func (m *Measurement) Delete(id string) error {
-	measurement, err := m.FindById(id)
-	if err != nil {
-		return err
+func (m *Measurement) FindById(id string, ctx context.Context) (*entity.Measurement, error) {
+	var measurement entity.Measurement
+	err := m.DB.WithContext(ctx).First(&measurement, "id = ?", id).Error
+	return &measurement, translate(err)
+}
+
+// FindByImageSHA256 returns the oldest measurement whose image has the given
+// content hash.
+func (m *Measurement) FindByImageSHA256(hash string, ctx context.Context) (*entity.Measurement, error) {
+	var measurement entity.Measurement
+	err := m.DB.WithContext(ctx).Order("created_at asc").First(&measurement, "image_sha256 = ?", hash).Error
+	return &measurement, translate(err)
+}
+
+// FindRecentByUser returns the latest measurements of user, newest first.
+func (m *Measurement) FindRecentByUser(user string, limit int, ctx context.Context) ([]entity.Measurement, error) {
+	var measurements []entity.Measurement
+	err := m.DB.WithContext(ctx).Where(clause.Eq{Column: clause.Column{Name: "user"}, Value: user}).Order("created_at desc").Limit(limit).Find(&measurements).Error
+	return measurements, err
+}
+
+// FindLastConfirmed returns the latest confirmed measurement of user and
+// measurementType taken before the given time.
+func (m *Measurement) FindLastConfirmed(user, measurementType string, before time.Time, ctx context.Context) (*entity.Measurement, error) {
+	var measurement entity.Measurement
+	err := m.DB.WithContext(ctx).
+		Where(clause.Eq{Column: clause.Column{Name: "user"}, Value: user}).
+		Where("type = ? AND confirmed = ? AND created_at < ?", measurementType, true, before).
+		Order("created_at desc").
+		Order("id desc").
+		First(&measurement).Error
+	return &measurement, translate(err)
+}
+
+func (m *Measurement) Update(measurement *entity.Measurement, ctx context.Context) error {
+	return m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before entity.Measurement
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, "id = ?", measurement.ID).Error
+		if err != nil {
+			return translate(err)
+		}
+		if before.Version != measurement.Version {
+			return ErrVersionConflict
+		}
+		// Renditions are written outside of versioning by UpdateRenditions,
+		// so the copy loaded by the caller may hold stale ones.
+		measurement.Thumbnail, measurement.MediumImage = before.Thumbnail, before.MediumImage
+		measurement.Version++
+		result := tx.Model(&entity.Measurement{}).Where("id = ? AND version = ?", measurement.ID, before.Version).Select("*").Omit("thumbnail", "medium_image").Updates(measurement)
+		if result.Error != nil {
+			measurement.Version--
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			measurement.Version--
+			return ErrVersionConflict
+		}
+		action := entity.AuditActionUpdate
+		if !before.Confirmed && measurement.Confirmed {
+			action = entity.AuditActionConfirm
+		}
+		return recordAuditEvent(tx, action, measurementEntity, measurement.ID.String(), &before, measurement, ctx)
+	})
+}
+
+// UpdateRenditions stores generated image renditions. They are derived
+// from the stored image, so the version and the audit trail are untouched.
+func (m *Measurement) UpdateRenditions(measurement *entity.Measurement, ctx context.Context) error {
+	result := m.DB.WithContext(ctx).Model(&entity.Measurement{}).Where("id = ?", measurement.ID).UpdateColumns(map[string]any{
+		"thumbnail":    measurement.Thumbnail,
+		"medium_image": measurement.MediumImage,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
 	}
-	return m.DB.Delete(measurement).Error
+	return nil
+}
+
+func (m *Measurement) Delete(id string, ctx context.Context) error {
+	return m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var measurement entity.Measurement
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&measurement, "id = ?", id).Error
+		if err != nil {
+			return translate(err)
+		}
+		err = tx.Delete(&measurement).Error
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(tx, entity.AuditActionDelete, measurementEntity, id, &measurement, nil, ctx)
+	})
 }
@@ -1,7 +1,9 @@
 package database
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
 	"github.com/stretchr/testify/assert"
@@ -16,11 +18,11 @@ func TestCreateNewMeasurement(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not open database: %v", err)
 	}
-	db.AutoMigrate(&entity.Measurement{})
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
 	m, err := entity.NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
 	assert.NoError(t, err)
 	measurementDb := NewMeasurement(db)
-	err = measurementDb.Create(m)
+	err = measurementDb.Create(m, context.Background())
 	assert.NoError(t, err)
 	assert.NotEmpty(t, m.ID)
 }
@@ -30,7 +32,7 @@ func TestFindAllMeasurements(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not open database: %v", err)
 	}
-	db.AutoMigrate(&entity.Measurement{})
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
 	count := 0
 	for count < 24 {
 		count++
@@ -39,19 +41,19 @@ func TestFindAllMeasurements(t *testing.T) {
 		db.Create(measurement)
 	}
 	measurementDB := NewMeasurement(db)
-	measurements, err := measurementDB.FindAll(1, 10, "asc")
+	measurements, err := measurementDB.FindAll(MeasurementFilter{}, 1, 10, "asc", context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, measurements, 10)
 
-	measurements, err = measurementDB.FindAll(2, 10, "asc")
+	measurements, err = measurementDB.FindAll(MeasurementFilter{}, 2, 10, "asc", context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, measurements, 10)
 
-	measurements, err = measurementDB.FindAll(3, 10, "asc")
+	measurements, err = measurementDB.FindAll(MeasurementFilter{}, 3, 10, "asc", context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, measurements, 4)
 
-	measurements, err = measurementDB.FindAll(4, 10, "asc")
+	measurements, err = measurementDB.FindAll(MeasurementFilter{}, 4, 10, "asc", context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, measurements, 0)
 }
@@ -61,7 +63,7 @@ func TestFindAllMeasurementsWhenPageIsZero(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not open database: %v", err)
 	}
-	db.AutoMigrate(&entity.Measurement{})
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
 	count := 0
 	for count < 24 {
 		count++
@@ -70,7 +72,7 @@ func TestFindAllMeasurementsWhenPageIsZero(t *testing.T) {
 		db.Create(measurement)
 	}
 	measurementDB := NewMeasurement(db)
-	measurements, err := measurementDB.FindAll(0, 10, "asc")
+	measurements, err := measurementDB.FindAll(MeasurementFilter{}, 0, 10, "asc", context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, measurements, 10)
 }
@@ -80,7 +82,7 @@ func TestFindAllMeasurementsWhenLimitIsZero(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not open database: %v", err)
 	}
-	db.AutoMigrate(&entity.Measurement{})
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
 	count := 0
 	for count < 24 {
 		count++
@@ -89,19 +91,19 @@ func TestFindAllMeasurementsWhenLimitIsZero(t *testing.T) {
 		db.Create(measurement)
 	}
 	measurementDB := NewMeasurement(db)
-	measurements, err := measurementDB.FindAll(1, 0, "asc")
+	measurements, err := measurementDB.FindAll(MeasurementFilter{}, 1, 0, "asc", context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, measurements, 10)
 
-	measurements, err = measurementDB.FindAll(2, 0, "asc")
+	measurements, err = measurementDB.FindAll(MeasurementFilter{}, 2, 0, "asc", context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, measurements, 10)
 
-	measurements, err = measurementDB.FindAll(3, 0, "asc")
+	measurements, err = measurementDB.FindAll(MeasurementFilter{}, 3, 0, "asc", context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, measurements, 4)
 
-	measurements, err = measurementDB.FindAll(4, 0, "asc")
+	measurements, err = measurementDB.FindAll(MeasurementFilter{}, 4, 0, "asc", context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, measurements, 0)
 }
@@ -111,7 +113,7 @@ func TestFindAllMeasurementsWhenAscIsEmpty(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not open database: %v", err)
 	}
-	db.AutoMigrate(&entity.Measurement{})
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
 	count := 0
 	for count < 24 {
 		count++
@@ -120,7 +122,7 @@ func TestFindAllMeasurementsWhenAscIsEmpty(t *testing.T) {
 		db.Create(measurement)
 	}
 	measurementDB := NewMeasurement(db)
-	measurements, err := measurementDB.FindAll(1, 10, "")
+	measurements, err := measurementDB.FindAll(MeasurementFilter{}, 1, 10, "", context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, measurements, 10)
 	assert.Equal(t, 1, measurements[0].Value)
@@ -132,7 +134,7 @@ func TestFindAllMeasurementsWhenSortIsDesc(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not open database: %v", err)
 	}
-	db.AutoMigrate(&entity.Measurement{})
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
 	count := 0
 	for count < 24 {
 		count++
@@ -141,7 +143,7 @@ func TestFindAllMeasurementsWhenSortIsDesc(t *testing.T) {
 		db.Create(measurement)
 	}
 	measurementDB := NewMeasurement(db)
-	measurements, err := measurementDB.FindAll(1, 10, "desc")
+	measurements, err := measurementDB.FindAll(MeasurementFilter{}, 1, 10, "desc", context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, measurements, 10)
 	assert.Equal(t, 24, measurements[0].Value)
@@ -153,12 +155,12 @@ func TestFindMeasurementByID(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not open database: %v", err)
 	}
-	db.AutoMigrate(&entity.Measurement{})
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
 	measurement, err := entity.NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
 	assert.NoError(t, err)
 	db.Create(measurement)
 	measurementDB := NewMeasurement(db)
-	measurement, err = measurementDB.FindById(measurement.ID.String())
+	measurement, err = measurementDB.FindById(measurement.ID.String(), context.Background())
 	assert.NoError(t, err)
 	assert.NotEmpty(t, measurement.ID)
 	assert.Equal(t, 19, measurement.Value)
@@ -169,15 +171,15 @@ func TestUpdateMeasurement(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not open database: %v", err)
 	}
-	db.AutoMigrate(&entity.Measurement{})
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
 	measurement, err := entity.NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
 	assert.NoError(t, err)
 	db.Create(measurement)
 	measurementDB := NewMeasurement(db)
 	measurement.Value = 20
-	err = measurementDB.Update(measurement)
+	err = measurementDB.Update(measurement, context.Background())
 	assert.NoError(t, err)
-	measurement, err = measurementDB.FindById(measurement.ID.String())
+	measurement, err = measurementDB.FindById(measurement.ID.String(), context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 20, measurement.Value)
 }
@@ -187,13 +189,296 @@ func TestDeleteMeasurementByID(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not open database: %v", err)
 	}
-	db.AutoMigrate(&entity.Measurement{})
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
 	measurement, err := entity.NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
 	assert.NoError(t, err)
 	db.Create(measurement)
 	measurementDB := NewMeasurement(db)
-	err = measurementDB.Delete(measurement.ID.String())
+	err = measurementDB.Delete(measurement.ID.String(), context.Background())
 	assert.NoError(t, err)
-	_, err = measurementDB.FindById(measurement.ID.String())
-	assert.Error(t, err)
+	_, err = measurementDB.FindById(measurement.ID.String(), context.Background())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestUpdateMeasurementIncrementsVersion(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	measurement, err := entity.NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.NoError(t, err)
+	db.Create(measurement)
+	measurementDB := NewMeasurement(db)
+	measurement.Value = 20
+	err = measurementDB.Update(measurement, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, measurement.Version)
+	measurement, err = measurementDB.FindById(measurement.ID.String(), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, measurement.Version)
+}
+
+func TestUpdateMeasurementWhenVersionIsStale(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	measurement, err := entity.NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.NoError(t, err)
+	db.Create(measurement)
+	measurementDB := NewMeasurement(db)
+	first := *measurement
+	second := *measurement
+	first.Value = 20
+	err = measurementDB.Update(&first, context.Background())
+	assert.NoError(t, err)
+	second.Value = 21
+	err = measurementDB.Update(&second, context.Background())
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	assert.Equal(t, 1, second.Version)
+	measurement, err = measurementDB.FindById(measurement.ID.String(), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 20, measurement.Value)
+}
+
+func TestUpdateMeasurementRenditions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	measurement, err := entity.NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.NoError(t, err)
+	db.Create(measurement)
+	measurementDB := NewMeasurement(db)
+	measurement.Thumbnail = "https://example.com/thumb.jpg"
+	err = measurementDB.UpdateRenditions(measurement, context.Background())
+	assert.NoError(t, err)
+	measurement, err = measurementDB.FindById(measurement.ID.String(), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/thumb.jpg", measurement.Thumbnail)
+	assert.Equal(t, 1, measurement.Version)
+	var count int64
+	db.Model(&entity.AuditEvent{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestUpdateMeasurementKeepsRenditions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	measurement, err := entity.NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.NoError(t, err)
+	db.Create(measurement)
+	measurementDB := NewMeasurement(db)
+
+	// A rendition is generated after the update loaded the measurement.
+	loaded, err := measurementDB.FindById(measurement.ID.String(), context.Background())
+	assert.NoError(t, err)
+	measurement.Thumbnail = "https://example.com/thumb.jpg"
+	assert.NoError(t, measurementDB.UpdateRenditions(measurement, context.Background()))
+	loaded.Value = 20
+	assert.NoError(t, measurementDB.Update(loaded, context.Background()))
+	assert.Equal(t, "https://example.com/thumb.jpg", loaded.Thumbnail)
+
+	measurement, err = measurementDB.FindById(measurement.ID.String(), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 20, measurement.Value)
+	assert.Equal(t, "https://example.com/thumb.jpg", measurement.Thumbnail)
+}
+
+func TestUpdateMeasurementRenditionsWhenNotFound(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	measurement, err := entity.NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.NoError(t, err)
+	measurementDB := NewMeasurement(db)
+	err = measurementDB.UpdateRenditions(measurement, context.Background())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFindMeasurementByImageSHA256(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	first, err := entity.NewMeasurement(19, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.NoError(t, err)
+	first.ImageSHA256 = "abc"
+	db.Create(first)
+	second, err := entity.NewMeasurement(20, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.NoError(t, err)
+	second.ImageSHA256 = "abc"
+	second.CreatedAt = first.CreatedAt.Add(time.Hour)
+	db.Create(second)
+	measurementDB := NewMeasurement(db)
+	measurement, err := measurementDB.FindByImageSHA256("abc", context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, measurement.ID)
+	_, err = measurementDB.FindByImageSHA256("def", context.Background())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFindRecentMeasurementsByUser(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	start := time.Now()
+	for i := 1; i <= 5; i++ {
+		measurement, err := entity.NewMeasurement(i, image, "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+		assert.NoError(t, err)
+		measurement.CreatedAt = start.Add(time.Duration(i) * time.Hour)
+		db.Create(measurement)
+	}
+	other, err := entity.NewMeasurement(99, image, "1", "2aecca5b-4015-4f64-b399-0857d968fec0")
+	assert.NoError(t, err)
+	other.CreatedAt = start.Add(10 * time.Hour)
+	db.Create(other)
+	measurementDB := NewMeasurement(db)
+	measurements, err := measurementDB.FindRecentByUser("878ab991-20b0-41c3-9c78-849744e8312a", 3, context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, measurements, 3)
+	assert.Equal(t, 5, measurements[0].Value)
+	assert.Equal(t, 3, measurements[2].Value)
+}
+
+func TestCreateManyMeasurements(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	measurementDB := NewMeasurement(db)
+	takenAt := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	var measurements []*entity.Measurement
+	for i := 1; i <= 3; i++ {
+		measurement, err := entity.NewImportedMeasurement(i, "", "1", "878ab991-20b0-41c3-9c78-849744e8312a", takenAt.AddDate(0, i, 0))
+		assert.NoError(t, err)
+		measurements = append(measurements, measurement)
+	}
+
+	err = measurementDB.CreateMany(measurements, context.Background())
+	assert.NoError(t, err)
+
+	var count int64
+	db.Model(&entity.Measurement{}).Where("imported = ?", true).Count(&count)
+	assert.Equal(t, int64(3), count)
+	db.Model(&entity.AuditEvent{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+	found, err := measurementDB.FindById(measurements[0].ID.String(), context.Background())
+	assert.NoError(t, err)
+	assert.True(t, found.CreatedAt.Equal(takenAt.AddDate(0, 1, 0)))
+}
+
+func TestFindAllMeasurementsWithFilter(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	measurementDB := NewMeasurement(db)
+	user := "878ab991-20b0-41c3-9c78-849744e8312a"
+	other := "3c20c52e-8db9-444a-b6b0-f56dd27b0400"
+	for i, owner := range []string{user, user, other} {
+		measurement, err := entity.NewMeasurement(i+1, "image", "1", owner)
+		assert.NoError(t, err)
+		measurement.Confirmed = i == 0
+		assert.NoError(t, measurementDB.Create(measurement, context.Background()))
+	}
+
+	measurements, err := measurementDB.FindAll(MeasurementFilter{User: user}, 1, 10, "asc", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, measurements, 2)
+
+	confirmed := true
+	measurements, err = measurementDB.FindAll(MeasurementFilter{User: user, Confirmed: &confirmed}, 1, 10, "asc", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, measurements, 1)
+	assert.Equal(t, 1, measurements[0].Value)
+}
+
+func TestStreamMeasurementsWithConsumption(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	measurementDB := NewMeasurement(db)
+	user := "878ab991-20b0-41c3-9c78-849744e8312a"
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var measurements []*entity.Measurement
+	for i, reading := range []struct {
+		value int
+		kind  string
+	}{{100, "1"}, {40, "2"}, {112, "1"}, {130, "1"}, {45, "2"}} {
+		measurement, err := entity.NewImportedMeasurement(reading.value, "", reading.kind, user, start.AddDate(0, i, 0))
+		assert.NoError(t, err)
+		measurements = append(measurements, measurement)
+	}
+	assert.NoError(t, measurementDB.CreateMany(measurements, context.Background()))
+
+	var got []entity.MeasurementWithConsumption
+	err = measurementDB.StreamWithConsumption(MeasurementFilter{Type: "1", From: start.AddDate(0, 1, 0)}, "asc", func(m *entity.MeasurementWithConsumption) error {
+		got = append(got, *m)
+		return nil
+	}, context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, 112, got[0].Value)
+	assert.Equal(t, 100, *got[0].PreviousValue)
+	assert.Equal(t, 12, *got[0].Consumption)
+	assert.Equal(t, 18, *got[1].Consumption)
+	assert.True(t, got[0].CreatedAt.Equal(start.AddDate(0, 2, 0)))
+
+	got = nil
+	err = measurementDB.StreamWithConsumption(MeasurementFilter{Type: "2"}, "asc", func(m *entity.MeasurementWithConsumption) error {
+		got = append(got, *m)
+		return nil
+	}, context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Nil(t, got[0].Consumption)
+	assert.Equal(t, 5, *got[1].Consumption)
+}
+
+func TestFindLastConfirmedMeasurement(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	measurementDB := NewMeasurement(db)
+	user := "878ab991-20b0-41c3-9c78-849744e8312a"
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var measurements []*entity.Measurement
+	for i, value := range []int{100, 112, 130} {
+		measurement, err := entity.NewImportedMeasurement(value, "", "1", user, start.AddDate(0, i, 0))
+		assert.NoError(t, err)
+		measurements = append(measurements, measurement)
+	}
+	measurements[2].Confirmed = false
+	assert.NoError(t, measurementDB.CreateMany(measurements, context.Background()))
+
+	found, err := measurementDB.FindLastConfirmed(user, "1", start.AddDate(1, 0, 0), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 112, found.Value)
+
+	found, err = measurementDB.FindLastConfirmed(user, "1", start.AddDate(0, 1, 0), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 100, found.Value)
+
+	_, err = measurementDB.FindLastConfirmed(user, "1", start, context.Background())
+	assert.Equal(t, ErrNotFound, err)
+	_, err = measurementDB.FindLastConfirmed(user, "2", start.AddDate(1, 0, 0), context.Background())
+	assert.Equal(t, ErrNotFound, err)
 }
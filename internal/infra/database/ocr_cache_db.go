@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OCRCache struct {
+	DB *gorm.DB
+}
+
+func NewOCRCache(db *gorm.DB) *OCRCache {
+	return &OCRCache{
+		DB: db,
+	}
+}
+
+// FindByKey returns the entry stored under key, or ErrNotFound when there is
+// none or it has expired.
+func (o *OCRCache) FindByKey(key string, ctx context.Context) (*entity.OCRCacheEntry, error) {
+	var entry entity.OCRCacheEntry
+	err := o.DB.WithContext(ctx).Where("key = ? AND expires_at > ?", key, time.Now()).First(&entry).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &entry, nil
+}
+
+// Save inserts entry or replaces the one stored under the same key.
+func (o *OCRCache) Save(entry *entity.OCRCacheEntry, ctx context.Context) error {
+	return o.DB.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(entry).Error
+}
+
+// Prune deletes expired entries and then the oldest ones beyond maxEntries.
+// A maxEntries of zero only deletes expired entries.
+func (o *OCRCache) Prune(maxEntries int, ctx context.Context) (int64, error) {
+	var deleted int64
+	err := o.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("expires_at <= ?", time.Now()).Delete(&entity.OCRCacheEntry{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		if maxEntries <= 0 {
+			return nil
+		}
+		newest := tx.Model(&entity.OCRCacheEntry{}).Select("key").Order("created_at desc").Limit(maxEntries)
+		result = tx.Where("key NOT IN (?)", newest).Delete(&entity.OCRCacheEntry{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted += result.RowsAffected
+		return nil
+	})
+	return deleted, err
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveAndFindOCRCacheEntry(t *testing.T) {
+	cache := NewOCRCache(newTestDB(t, &entity.OCRCacheEntry{}))
+	ctx := context.Background()
+	entry, err := entity.NewOCRCacheEntry("key", `{"value":"19"}`, time.Hour)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.Save(entry, ctx))
+	entry.Response = `{"value":"20"}`
+	assert.NoError(t, cache.Save(entry, ctx))
+
+	found, err := cache.FindByKey("key", ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.JSON(`{"value":"20"}`), found.Response)
+
+	_, err = cache.FindByKey("missing", ctx)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestFindExpiredOCRCacheEntry(t *testing.T) {
+	cache := NewOCRCache(newTestDB(t, &entity.OCRCacheEntry{}))
+	ctx := context.Background()
+	entry, err := entity.NewOCRCacheEntry("key", `{"value":"19"}`, -time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Save(entry, ctx))
+
+	_, err = cache.FindByKey("key", ctx)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestPruneOCRCache(t *testing.T) {
+	cache := NewOCRCache(newTestDB(t, &entity.OCRCacheEntry{}))
+	ctx := context.Background()
+	expired, err := entity.NewOCRCacheEntry("expired", `{}`, -time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Save(expired, ctx))
+	for i, key := range []string{"a", "b", "c"} {
+		entry, err := entity.NewOCRCacheEntry(key, `{}`, time.Hour)
+		assert.NoError(t, err)
+		entry.CreatedAt = entry.CreatedAt.Add(time.Duration(i) * time.Second)
+		assert.NoError(t, cache.Save(entry, ctx))
+	}
+
+	deleted, err := cache.Prune(2, ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	_, err = cache.FindByKey("a", ctx)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = cache.FindByKey("c", ctx)
+	assert.NoError(t, err)
+}
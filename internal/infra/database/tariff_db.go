@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"gorm.io/gorm"
+)
+
+const tariffEntity = "tariff"
+
+type Tariff struct {
+	DB *gorm.DB
+}
+
+func NewTariff(db *gorm.DB) *Tariff {
+	return &Tariff{
+		DB: db,
+	}
+}
+
+func (t *Tariff) Create(tariff *entity.Tariff, ctx context.Context) error {
+	return t.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(tariff).Error
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(tx, entity.AuditActionCreate, tariffEntity, tariff.ID.String(), nil, tariff, ctx)
+	})
+}
+
+// FindAll returns the tariffs of measurementType, or of every type when it
+// is empty, most recent first.
+func (t *Tariff) FindAll(measurementType string, ctx context.Context) ([]entity.Tariff, error) {
+	var tariffs []entity.Tariff
+	query := t.DB.WithContext(ctx).Preload("Tiers", orderTiers)
+	if measurementType != "" {
+		query = query.Where("type = ?", measurementType)
+	}
+	err := query.Order("effective_from desc").Order("id").Find(&tariffs).Error
+	return tariffs, err
+}
+
+func (t *Tariff) FindById(id string, ctx context.Context) (*entity.Tariff, error) {
+	var tariff entity.Tariff
+	err := t.DB.WithContext(ctx).Preload("Tiers", orderTiers).First(&tariff, "id = ?", id).Error
+	return &tariff, translate(err)
+}
+
+func orderTiers(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
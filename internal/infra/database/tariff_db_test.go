@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateTariff(t *testing.T) {
+	tariffDB := NewTariff(newTestDB(t, &entity.Tariff{}, &entity.TariffTier{}, &entity.AuditEvent{}))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tariff, err := entity.NewTariff("Residential", "1", "BRL", 1500, 1250, []entity.TariffTier{{UpTo: 10, Price: 400}, {UpTo: 20, Price: 650}, {Price: 900}}, start, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, tariffDB.Create(tariff, context.Background()))
+
+	found, err := tariffDB.FindById(tariff.ID.String(), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Residential", found.Name)
+	assert.Len(t, found.Tiers, 3)
+	assert.Equal(t, 10, found.Tiers[0].UpTo)
+	assert.Equal(t, 0, found.Tiers[2].UpTo)
+	assert.Nil(t, found.EffectiveTo)
+
+	_, err = tariffDB.FindById("3c20c52e-8db9-444a-b6b0-f56dd27b0400", context.Background())
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestFindAllTariffs(t *testing.T) {
+	tariffDB := NewTariff(newTestDB(t, &entity.Tariff{}, &entity.TariffTier{}, &entity.AuditEvent{}))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, measurementType := range []string{"1", "2", "1"} {
+		tariff, err := entity.NewTariff("Residential", measurementType, "BRL", 0, 0, []entity.TariffTier{{Price: 300}}, start.AddDate(0, i, 0), nil)
+		assert.NoError(t, err)
+		assert.NoError(t, tariffDB.Create(tariff, context.Background()))
+	}
+
+	tariffs, err := tariffDB.FindAll("1", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, tariffs, 2)
+	assert.True(t, tariffs[0].EffectiveFrom.Equal(start.AddDate(0, 2, 0)))
+	assert.Len(t, tariffs[0].Tiers, 1)
+
+	tariffs, err = tariffDB.FindAll("", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, tariffs, 3)
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type UnitOfWork struct {
+	DB *gorm.DB
+}
+
+func NewUnitOfWork(db *gorm.DB) *UnitOfWork {
+	return &UnitOfWork{
+		DB: db,
+	}
+}
+
+// Do runs fn inside a single database transaction. The repositories handed
+// to fn are bound to that transaction, so every change they make is
+// committed when fn returns nil and rolled back otherwise.
+func (u *UnitOfWork) Do(fn func(repositories *Repositories) error, ctx context.Context) error {
+	return u.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&Repositories{
+			Measurement: NewMeasurement(tx),
+			User:        NewUser(tx),
+		})
+	})
+}
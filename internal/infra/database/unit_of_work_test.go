@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestUnitOfWorkCommits(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.User{}, &entity.AuditEvent{})
+	measurement, err := entity.NewMeasurement(19, "image", "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.NoError(t, err)
+	user, err := entity.NewUser("John Doe", "j@j.com", "123456")
+	assert.NoError(t, err)
+	unitOfWork := NewUnitOfWork(db)
+
+	err = unitOfWork.Do(func(repositories *Repositories) error {
+		err := repositories.User.Create(user, context.Background())
+		if err != nil {
+			return err
+		}
+		return repositories.Measurement.Create(measurement, context.Background())
+	}, context.Background())
+	assert.NoError(t, err)
+
+	_, err = NewMeasurement(db).FindById(measurement.ID.String(), context.Background())
+	assert.NoError(t, err)
+	_, err = NewUser(db).FindByEmail(user.Email, context.Background())
+	assert.NoError(t, err)
+}
+
+func TestUnitOfWorkRollsBackOnError(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.Measurement{}, &entity.AuditEvent{})
+	measurement, err := entity.NewMeasurement(19, "image", "1", "878ab991-20b0-41c3-9c78-849744e8312a")
+	assert.NoError(t, err)
+	errFailed := errors.New("failed")
+
+	err = NewUnitOfWork(db).Do(func(repositories *Repositories) error {
+		err := repositories.Measurement.Create(measurement, context.Background())
+		if err != nil {
+			return err
+		}
+		return errFailed
+	}, context.Background())
+	assert.ErrorIs(t, err, errFailed)
+
+	_, err = NewMeasurement(db).FindById(measurement.ID.String(), context.Background())
+	assert.ErrorIs(t, err, ErrNotFound)
+	events, err := NewAuditEvent(db).FindAll(AuditEventFilter{}, 1, 10, "asc", context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, events, 0)
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"context"
+
 	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
 	"gorm.io/gorm"
 )
@@ -15,12 +17,18 @@ func NewUser(db *gorm.DB) *User {
 	}
 }
 
-func (u *User) Create(user *entity.User) error {
-	return u.DB.Create(user).Error
+func (u *User) Create(user *entity.User, ctx context.Context) error {
+	return u.DB.WithContext(ctx).Create(user).Error
+}
+
+func (u *User) FindByEmail(email string, ctx context.Context) (*entity.User, error) {
+	var user entity.User
+	err := u.DB.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	return &user, translate(err)
 }
 
-func (u *User) FindByEmail(email string) (*entity.User, error) {
+func (u *User) FindById(id string, ctx context.Context) (*entity.User, error) {
 	var user entity.User
-	err := u.DB.Where("email = ?", email).First(&user).Error
-	return &user, err
+	err := u.DB.WithContext(ctx).First(&user, "id = ?", id).Error
+	return &user, translate(err)
 }
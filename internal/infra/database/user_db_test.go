@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"testing"
 
 	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
@@ -18,7 +19,7 @@ func TestCreateUser(t *testing.T) {
 	user, _ := entity.NewUser("John Doe", "j@j.com", "123456")
 	userDB := NewUser(db)
 
-	err = userDB.Create(user)
+	err = userDB.Create(user, context.Background())
 	assert.Nil(t, err)
 
 	var userFound entity.User
@@ -39,13 +40,45 @@ func TestFindByEmail(t *testing.T) {
 	user, _ := entity.NewUser("John Doe", "j@j.com", "123456")
 	userDB := NewUser(db)
 
-	err = userDB.Create(user)
+	err = userDB.Create(user, context.Background())
 	assert.Nil(t, err)
 
-	userFound, err := userDB.FindByEmail(user.Email)
+	userFound, err := userDB.FindByEmail(user.Email, context.Background())
 	assert.Nil(t, err)
 	assert.Equal(t, user.ID, userFound.ID)
 	assert.Equal(t, user.Name, userFound.Name)
 	assert.Equal(t, user.Email, userFound.Email)
 	assert.NotNil(t, userFound.Password)
 }
+
+func TestFindByEmailWhenUserDoesNotExist(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.User{})
+	userDB := NewUser(db)
+
+	_, err = userDB.FindByEmail("j@j.com", context.Background())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFindUserById(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.User{})
+	user, _ := entity.NewUser("John Doe", "j@j.com", "123456")
+	userDB := NewUser(db)
+
+	err = userDB.Create(user, context.Background())
+	assert.Nil(t, err)
+
+	userFound, err := userDB.FindById(user.ID.String(), context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, user.Email, userFound.Email)
+
+	_, err = userDB.FindById("3c20c52e-8db9-444a-b6b0-f56dd27b0400", context.Background())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
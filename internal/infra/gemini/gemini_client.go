@@ -4,60 +4,244 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
 	"strings"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
 	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/circuitbreaker"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const maxBackoff = 5 * time.Second
+
+var (
+	ErrProviderUnavailable = errors.New("ocr provider is unavailable")
+	ErrResponseBlocked     = errors.New("ocr response was blocked by the provider safety filters")
+	ErrEmptyResponse       = errors.New("ocr provider returned no reading")
+)
+
+// Options controls how calls to the provider are bounded and retried.
+// Timeout bounds each attempt and Budget the whole call, retries and
+// backoff included.
+type Options struct {
+	Timeout          time.Duration
+	Budget           time.Duration
+	MaxRetries       int
+	Backoff          time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
 type Gemini struct {
-	model  string
-	Gemini *genai.Client
+	model   string
+	prompts *Prompts
+	options Options
+	breaker *circuitbreaker.Breaker
+	Gemini  *genai.Client
+}
+
+// reading mirrors responseSchema.
+type reading struct {
+	Reading     string  `json:"reading"`
+	Unit        string  `json:"unit"`
+	MeterSerial string  `json:"meter_serial"`
+	DigitCount  int     `json:"digit_count"`
+	Confidence  float64 `json:"confidence"`
+	IsMeter     bool    `json:"is_meter"`
+	MeterType   string  `json:"meter_type"`
+	Legible     bool    `json:"legible"`
 }
 
-func NewGeminiClient(apiKey, model string) (*Gemini, error) {
+var responseSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"reading":      {Type: genai.TypeString, Description: "integer digits of the register, empty when unreadable"},
+		"unit":         {Type: genai.TypeString, Description: "unit printed on the meter"},
+		"meter_serial": {Type: genai.TypeString, Description: "serial number printed on the meter"},
+		"digit_count":  {Type: genai.TypeInteger, Description: "number of integer digits on the register"},
+		"confidence":   {Type: genai.TypeNumber, Description: "confidence in the reading from 0 to 1"},
+		"is_meter":     {Type: genai.TypeBoolean, Description: "whether the image shows a water or gas meter"},
+		"meter_type":   {Type: genai.TypeString, Format: "enum", Enum: []string{dto.MeterTypeWater, dto.MeterTypeGas, dto.MeterTypeUnknown}},
+		"legible":      {Type: genai.TypeBoolean, Description: "whether every integer digit of the register is readable"},
+	},
+	Required: []string{"reading", "confidence", "is_meter", "meter_type", "legible"},
+}
+
+func NewGeminiClient(apiKey, model string, prompts *Prompts, options Options) (*Gemini, error) {
 	client, err := genai.NewClient(context.Background(), option.WithAPIKey(apiKey))
 	if err != nil {
 		return nil, err
 	}
 	return &Gemini{
-		model:  model,
-		Gemini: client,
+		model:   model,
+		prompts: prompts,
+		options: options,
+		breaker: circuitbreaker.New(options.BreakerThreshold, options.BreakerCooldown),
+		Gemini:  client,
 	}, err
 }
 
 func (g *Gemini) ProcessImage(request dto.ProcessImageRequest, ctx context.Context) (dto.ProcessImageResponse, error) {
-	defer g.Gemini.Close()
-
-	model := g.Gemini.GenerativeModel(g.model)
-	model.ResponseMIMEType = "application/json"
-	model.ResponseSchema = &genai.Schema{
-		Type:  genai.TypeArray,
-		Items: &genai.Schema{Type: genai.TypeString},
+	image, err := base64.StdEncoding.DecodeString(request.Data)
+	if err != nil {
+		return dto.ProcessImageResponse{}, err
 	}
 
-	image, err := base64.StdEncoding.DecodeString(request.Data)
+	prompt, err := g.prompts.Select(request.PromptVersion)
 	if err != nil {
 		return dto.ProcessImageResponse{}, err
 	}
 
-	resp, err := model.GenerateContent(
-		ctx,
-		genai.Text("You are a meter reading expert. Extract the entire numeric value of a gas or water meter reading from this image in base64. Explicitly return only the integer numeric value."),
-		genai.ImageData(strings.TrimPrefix(request.Mime, "image/"), image),
-	)
+	if err := g.breaker.Allow(); err != nil {
+		return dto.ProcessImageResponse{}, fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+	callCtx := ctx
+	if g.options.Budget > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, g.options.Budget)
+		defer cancel()
+	}
+	resp, err := g.generate(prompt, strings.TrimPrefix(request.Mime, "image/"), image, callCtx)
+	switch {
+	case err == nil:
+		g.breaker.Success()
+	case isTransient(err, ctx):
+		g.breaker.Failure()
+		return dto.ProcessImageResponse{}, fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	case ctx.Err() != nil:
+		// The caller went away, which says nothing about the provider.
+		return dto.ProcessImageResponse{}, err
+	default:
+		g.breaker.Success()
+	}
+
+	var blocked *genai.BlockedError
+	if errors.As(err, &blocked) {
+		return dto.ProcessImageResponse{}, fmt.Errorf("%w: %w", ErrResponseBlocked, err)
+	}
 	if err != nil {
 		return dto.ProcessImageResponse{}, err
 	}
 
-	var recipes []string
+	response := dto.ProcessImageResponse{PromptVersion: prompt.Key()}
+	if resp.UsageMetadata != nil {
+		response.Usage = dto.ProcessImageUsage{
+			PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
+			CandidatesTokens: int(resp.UsageMetadata.CandidatesTokenCount),
+			TotalTokens:      int(resp.UsageMetadata.TotalTokenCount),
+		}
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return response, ErrEmptyResponse
+	}
+
+	var text strings.Builder
 	for _, part := range resp.Candidates[0].Content.Parts {
 		if txt, ok := part.(genai.Text); ok {
-			if err := json.Unmarshal([]byte(txt), &recipes); err != nil {
-				return dto.ProcessImageResponse{}, err
-			}
+			text.WriteString(string(txt))
+		}
+	}
+	if text.Len() == 0 {
+		return response, ErrEmptyResponse
+	}
+	var result reading
+	if err := json.Unmarshal([]byte(text.String()), &result); err != nil {
+		return response, err
+	}
+	response.Value = strings.TrimSpace(result.Reading)
+	response.Unit = result.Unit
+	response.MeterSerial = result.MeterSerial
+	response.DigitCount = result.DigitCount
+	response.Confidence = result.Confidence
+	response.IsMeter = result.IsMeter
+	response.MeterType = result.MeterType
+	response.Legible = result.Legible
+	if response.Value == "" && response.IsMeter && response.Legible {
+		return response, ErrEmptyResponse
+	}
+	return response, nil
+}
+
+// generate calls the model, retrying transient failures with full jitter
+// backoff. Each attempt gets its own timeout so one hung call does not use
+// up the whole request.
+func (g *Gemini) generate(prompt Prompt, format string, image []byte, ctx context.Context) (*genai.GenerateContentResponse, error) {
+	model := g.Gemini.GenerativeModel(g.model)
+	model.ResponseMIMEType = "application/json"
+	model.ResponseSchema = responseSchema
+
+	var resp *genai.GenerateContentResponse
+	var err error
+	for attempt := 0; ; attempt++ {
+		resp, err = g.attempt(model, prompt, format, image, ctx)
+		if err == nil || attempt >= g.options.MaxRetries || !isTransient(err, ctx) {
+			return resp, err
+		}
+		select {
+		case <-time.After(backoff(g.options.Backoff, attempt)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
 	}
-	return dto.ProcessImageResponse{Value: recipes[0]}, err
+}
+
+func (g *Gemini) attempt(model *genai.GenerativeModel, prompt Prompt, format string, image []byte, ctx context.Context) (*genai.GenerateContentResponse, error) {
+	if g.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.options.Timeout)
+		defer cancel()
+	}
+	return model.GenerateContent(
+		ctx,
+		genai.Text(prompt.Text),
+		genai.ImageData(format, image),
+	)
+}
+
+// isTransient reports whether err is worth retrying. A deadline is only
+// transient when it came from the attempt timeout, not from the caller.
+func isTransient(err error, ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch s.Code() {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Internal, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	ceiling := base << attempt
+	if ceiling <= 0 || ceiling > maxBackoff {
+		ceiling = maxBackoff
+	}
+	return rand.N(ceiling)
+}
+
+// Ping checks that the API key is accepted and the configured model exists.
+func (g *Gemini) Ping(ctx context.Context) error {
+	_, err := g.Gemini.GenerativeModel(g.model).Info(ctx)
+	return err
+}
+
+func (g *Gemini) Close() error {
+	return g.Gemini.Close()
 }
@@ -0,0 +1,114 @@
+package gemini
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path"
+	"strings"
+)
+
+const promptExtension = ".txt"
+
+var (
+	ErrUnknownPrompt     = errors.New("unknown prompt version")
+	ErrInvalidPromptName = errors.New("prompt file names must be <id>@<version>.txt")
+)
+
+//go:embed prompts/*.txt
+var embeddedPrompts embed.FS
+
+// Prompt is an immutable prompt template. Changing the wording of a prompt
+// means adding a new version so stored readings stay reproducible.
+type Prompt struct {
+	ID      string
+	Version string
+	Text    string
+}
+
+// Key identifies the prompt as id@version, the form stored with readings.
+func (p Prompt) Key() string {
+	return p.ID + "@" + p.Version
+}
+
+// Prompts holds the available prompt templates and decides which one a call
+// uses. A share of the calls can be routed to a candidate prompt to compare
+// it against the default.
+type Prompts struct {
+	prompts          map[string]Prompt
+	defaultKey       string
+	candidateKey     string
+	candidatePercent int
+}
+
+// NewPrompts loads the embedded prompts and then the ones in dir, which
+// override embedded prompts with the same key. dir may be empty.
+func NewPrompts(dir, defaultKey, candidateKey string, candidatePercent int) (*Prompts, error) {
+	p := &Prompts{
+		prompts:          make(map[string]Prompt),
+		defaultKey:       defaultKey,
+		candidateKey:     candidateKey,
+		candidatePercent: candidatePercent,
+	}
+	sub, err := fs.Sub(embeddedPrompts, "prompts")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.load(sub); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := p.load(os.DirFS(dir)); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := p.prompts[defaultKey]; !ok {
+		return nil, ErrUnknownPrompt
+	}
+	if _, ok := p.prompts[candidateKey]; candidateKey != "" && !ok {
+		return nil, ErrUnknownPrompt
+	}
+	return p, nil
+}
+
+func (p *Prompts) load(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != promptExtension {
+			continue
+		}
+		id, version, ok := strings.Cut(strings.TrimSuffix(entry.Name(), promptExtension), "@")
+		if !ok || id == "" || version == "" {
+			return ErrInvalidPromptName
+		}
+		text, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return err
+		}
+		prompt := Prompt{ID: id, Version: version, Text: strings.TrimSpace(string(text))}
+		p.prompts[prompt.Key()] = prompt
+	}
+	return nil
+}
+
+// Select returns the prompt with the given key, or picks between the default
+// and the candidate prompt when key is empty. Keys come from the server,
+// such as the OCR cache pinning the prompt it looked up, never from clients.
+func (p *Prompts) Select(key string) (Prompt, error) {
+	if key != "" {
+		prompt, ok := p.prompts[key]
+		if !ok {
+			return Prompt{}, ErrUnknownPrompt
+		}
+		return prompt, nil
+	}
+	if p.candidateKey != "" && rand.IntN(100) < p.candidatePercent {
+		return p.prompts[p.candidateKey], nil
+	}
+	return p.prompts[p.defaultKey], nil
+}
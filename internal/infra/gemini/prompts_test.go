@@ -0,0 +1,74 @@
+package gemini
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPromptsLoadsEmbeddedPrompts(t *testing.T) {
+	prompts, err := NewPrompts("", "meter-reading@v2", "", 0)
+	assert.Nil(t, err)
+
+	prompt, err := prompts.Select("")
+	assert.Nil(t, err)
+	assert.Equal(t, "meter-reading", prompt.ID)
+	assert.Equal(t, "v2", prompt.Version)
+	assert.NotEmpty(t, prompt.Text)
+
+	prompt, err = prompts.Select("meter-reading@v3")
+	assert.Nil(t, err)
+	assert.Equal(t, "meter-reading@v3", prompt.Key())
+
+	// v1 predates the structured response and is retired.
+	_, err = prompts.Select("meter-reading@v1")
+	assert.Equal(t, ErrUnknownPrompt, err)
+}
+
+func TestNewPromptsOverridesEmbeddedPromptsFromDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "meter-reading@v2.txt"), []byte(" custom \n"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "meter-reading@v3.txt"), []byte("new"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0o644))
+
+	prompts, err := NewPrompts(dir, "meter-reading@v3", "meter-reading@v2", 0)
+	assert.Nil(t, err)
+
+	prompt, err := prompts.Select("meter-reading@v2")
+	assert.Nil(t, err)
+	assert.Equal(t, "custom", prompt.Text)
+
+	prompt, err = prompts.Select("")
+	assert.Nil(t, err)
+	assert.Equal(t, "meter-reading@v3", prompt.Key())
+}
+
+func TestNewPromptsWithInvalidFileName(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "meter-reading.txt"), []byte("text"), 0o644))
+
+	_, err := NewPrompts(dir, "meter-reading@v2", "", 0)
+	assert.Equal(t, ErrInvalidPromptName, err)
+}
+
+func TestNewPromptsWithUnknownPrompt(t *testing.T) {
+	_, err := NewPrompts("", "meter-reading@v9", "", 0)
+	assert.Equal(t, ErrUnknownPrompt, err)
+
+	_, err = NewPrompts("", "meter-reading@v2", "meter-reading@v9", 10)
+	assert.Equal(t, ErrUnknownPrompt, err)
+}
+
+func TestPromptsSelectRoutesCandidateShare(t *testing.T) {
+	prompts, err := NewPrompts("", "meter-reading@v2", "meter-reading@v3", 100)
+	assert.Nil(t, err)
+
+	prompt, err := prompts.Select("")
+	assert.Nil(t, err)
+	assert.Equal(t, "meter-reading@v3", prompt.Key())
+
+	_, err = prompts.Select("meter-reading@v9")
+	assert.Equal(t, ErrUnknownPrompt, err)
+}
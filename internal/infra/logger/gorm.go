@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Gorm writes GORM logs through the request scoped slog logger. Query
+// parameters are never logged, only the parameterized SQL.
+type Gorm struct {
+	SlowThreshold time.Duration
+	level         gormlogger.LogLevel
+}
+
+func NewGorm(slowThreshold time.Duration) *Gorm {
+	return &Gorm{
+		SlowThreshold: slowThreshold,
+		level:         gormlogger.Warn,
+	}
+}
+
+func (g *Gorm) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	logger := *g
+	logger.level = level
+	return &logger
+}
+
+func (g *Gorm) Info(ctx context.Context, msg string, args ...any) {
+	if g.level >= gormlogger.Info {
+		FromContext(ctx).InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (g *Gorm) Warn(ctx context.Context, msg string, args ...any) {
+	if g.level >= gormlogger.Warn {
+		FromContext(ctx).WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (g *Gorm) Error(ctx context.Context, msg string, args ...any) {
+	if g.level >= gormlogger.Error {
+		FromContext(ctx).ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (g *Gorm) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if g.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	logger := FromContext(ctx)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && g.level >= gormlogger.Error:
+		sql, rows := fc()
+		logger.ErrorContext(ctx, "database query failed", slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("duration", elapsed), slog.String("error", err.Error()))
+	case g.SlowThreshold != 0 && elapsed > g.SlowThreshold && g.level >= gormlogger.Warn:
+		sql, rows := fc()
+		logger.WarnContext(ctx, "slow database query", slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("duration", elapsed))
+	default:
+		if !logger.Enabled(ctx, slog.LevelDebug) {
+			return
+		}
+		sql, rows := fc()
+		logger.DebugContext(ctx, "database query", slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("duration", elapsed))
+	}
+}
+
+// ParamsFilter drops the query parameters so values such as password hashes
+// and image URLs are not interpolated into the logged SQL.
+func (g *Gorm) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	return sql, nil
+}
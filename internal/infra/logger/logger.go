@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var ErrInvalidLevel = errors.New("invalid log level")
+
+// sensitiveKeys are attribute keys whose values are never written, whatever
+// their content. Keys are compared case-insensitively.
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"access_token":  true,
+	"authorization": true,
+	"cookie":        true,
+	"secret":        true,
+	"api_key":       true,
+	"data":          true,
+	"image":         true,
+}
+
+// minBase64Length is the length from which a string made only of base64
+// characters is treated as an encoded payload. Shorter values such as IDs
+// and hashes are left alone.
+const minBase64Length = 256
+
+type contextKey struct{}
+
+// New returns a JSON logger writing to w at the given level. Sensitive
+// attributes and base64 payloads are redacted before they are encoded.
+func New(level string, w io.Writer) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, ErrInvalidLevel
+	}
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       lvl,
+		ReplaceAttr: redact,
+	})
+	return slog.New(handler), nil
+}
+
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the request scoped logger, or the default logger when
+// ctx does not carry one.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	if a.Value.Kind() != slog.KindString {
+		return a
+	}
+	value := a.Value.String()
+	if strings.HasPrefix(strings.ToLower(value), "bearer ") || isBase64Payload(value) {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+func isBase64Payload(value string) bool {
+	if len(value) < minBase64Length {
+		return false
+	}
+	for _, c := range value {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '+', c == '/', c == '=', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New("warn", &buf)
+	assert.Nil(t, err)
+
+	logger.Info("ignored")
+	assert.Empty(t, buf.String())
+
+	logger.Warn("written")
+	assert.Contains(t, buf.String(), `"msg":"written"`)
+}
+
+func TestNewLoggerWithInvalidLevel(t *testing.T) {
+	_, err := New("verbose", &bytes.Buffer{})
+	assert.Equal(t, ErrInvalidLevel, err)
+}
+
+func TestLoggerRedactsSensitiveAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New("info", &buf)
+	assert.Nil(t, err)
+
+	payload := strings.Repeat("iVBORw0KGgo", 40)
+	logger.Info("request",
+		"password", "123456",
+		"Authorization", "Bearer abc",
+		"header", "Bearer abc",
+		"payload", payload,
+		"user_id", "2aecca5b-4015-4f64-b399-0857d968fec0",
+	)
+
+	var line map[string]any
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, redacted, line["password"])
+	assert.Equal(t, redacted, line["Authorization"])
+	assert.Equal(t, redacted, line["header"])
+	assert.Equal(t, redacted, line["payload"])
+	assert.Equal(t, "2aecca5b-4015-4f64-b399-0857d968fec0", line["user_id"])
+}
@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
+)
+
+type requestKey struct{}
+
+// request holds what inner middlewares learn about a request after the
+// access log middleware has already run, such as the authenticated user.
+type request struct {
+	userID string
+}
+
+// Middleware stores a logger tagged with the request ID in the request
+// context and writes one access log line per request. It must run after the
+// RequestID middleware.
+func Middleware(base *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			state := &request{}
+			logger := base.With(slog.String("request_id", middleware.GetReqID(r.Context())))
+			ctx := context.WithValue(NewContext(r.Context(), logger), requestKey{}, state)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_ip", r.RemoteAddr),
+			}
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				attrs = append(attrs, slog.String("route", rctx.RoutePattern()))
+			}
+			if state.userID != "" {
+				attrs = append(attrs, slog.String("user_id", state.userID))
+			}
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(ctx, level, "http request", attrs...)
+		})
+	}
+}
+
+// WithUser tags the request logger, and the access log line, with the
+// authenticated user.
+func WithUser(ctx context.Context, userID string) context.Context {
+	if state, ok := ctx.Value(requestKey{}).(*request); ok {
+		state.userID = userID
+	}
+	return NewContext(ctx, FromContext(ctx).With(slog.String("user_id", userID)))
+}
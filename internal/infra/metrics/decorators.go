@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/gemini"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/storage"
+)
+
+type Gemini struct {
+	next gemini.GeminiInterface
+}
+
+func NewGemini(next gemini.GeminiInterface) *Gemini {
+	return &Gemini{
+		next: next,
+	}
+}
+
+func (g *Gemini) ProcessImage(request dto.ProcessImageRequest, ctx context.Context) (dto.ProcessImageResponse, error) {
+	start := time.Now()
+	resp, err := g.next.ProcessImage(request, ctx)
+	ocrRequestDuration.Observe(time.Since(start).Seconds())
+	ocrRequestsTotal.WithLabelValues(result(err)).Inc()
+	ocrTokensTotal.WithLabelValues("prompt").Add(float64(resp.Usage.PromptTokens))
+	ocrTokensTotal.WithLabelValues("candidates").Add(float64(resp.Usage.CandidatesTokens))
+	ocrTokensTotal.WithLabelValues("total").Add(float64(resp.Usage.TotalTokens))
+	return resp, err
+}
+
+type Storage struct {
+	next storage.MeasurementStorageInterface
+}
+
+func NewStorage(next storage.MeasurementStorageInterface) *Storage {
+	return &Storage{
+		next: next,
+	}
+}
+
+func (s *Storage) UploadFile(file string, ctx context.Context) (*uploader.UploadResult, error) {
+	start := time.Now()
+	resp, err := s.next.UploadFile(file, ctx)
+	storageUploadDuration.Observe(time.Since(start).Seconds())
+	storageOperationsTotal.WithLabelValues("upload", result(err)).Inc()
+	return resp, err
+}
+
+func (s *Storage) DeleteFile(publicID string, ctx context.Context) error {
+	err := s.next.DeleteFile(publicID, ctx)
+	storageOperationsTotal.WithLabelValues("delete", result(err)).Inc()
+	return err
+}
+
+func (s *Storage) GetFile(url string, ctx context.Context) (*storage.File, error) {
+	file, err := s.next.GetFile(url, ctx)
+	storageOperationsTotal.WithLabelValues("get", result(err)).Inc()
+	return file, err
+}
+
+func (s *Storage) Stores(url string) bool {
+	return s.next.Stores(url)
+}
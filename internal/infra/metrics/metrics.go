@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "wgm"
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by method and route.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 20, 30},
+	}, []string{"method", "route"})
+	httpRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "Number of HTTP requests currently being served.",
+	})
+
+	ocrRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ocr_requests_total",
+		Help:      "Total number of OCR provider calls by result.",
+	}, []string{"result"})
+	ocrRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ocr_request_duration_seconds",
+		Help:      "Duration of OCR provider calls.",
+		Buckets:   []float64{.25, .5, 1, 2, 4, 8, 16, 32},
+	})
+	ocrRejectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ocr_rejections_total",
+		Help:      "Total number of images rejected after OCR by measurement type and reason.",
+	}, []string{"type", "reason"})
+	ocrCacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ocr_cache_requests_total",
+		Help:      "Total number of OCR cache lookups by result (hit, miss or error).",
+	}, []string{"result"})
+	ocrTokensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ocr_tokens_total",
+		Help:      "Total number of tokens consumed by the OCR provider by kind.",
+	}, []string{"kind"})
+
+	storageOperationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_operations_total",
+		Help:      "Total number of storage operations by operation and result.",
+	}, []string{"operation", "result"})
+	storageUploadDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_upload_duration_seconds",
+		Help:      "Duration of image uploads to the storage backend.",
+		Buckets:   []float64{.1, .25, .5, 1, 2, 4, 8, 16},
+	})
+
+	measurementsCreatedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "measurements_created_total",
+		Help:      "Total number of readings created by measurement type.",
+	}, []string{"type"})
+	measurementsImportedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "measurements_imported_total",
+		Help:      "Total number of historical readings imported by measurement type.",
+	}, []string{"type"})
+	measurementsFlaggedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "measurements_flagged_total",
+		Help:      "Total number of readings flagged as possible duplicates of an earlier reading by measurement type.",
+	}, []string{"type"})
+	measurementsConfirmedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "measurements_confirmed_total",
+		Help:      "Total number of readings confirmed by measurement type and whether the OCR value was corrected.",
+	}, []string{"type", "corrected"})
+	alertsRaisedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_raised_total",
+		Help:      "Total number of consumption anomaly alerts raised by measurement type, kind and severity.",
+	}, []string{"type", "kind", "severity"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDB exposes the connection pool statistics of db.
+func RegisterDB(db *sql.DB, name string) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+func MeasurementCreated(measurementType string) {
+	measurementsCreatedTotal.WithLabelValues(measurementType).Inc()
+}
+
+func MeasurementImported(measurementType string) {
+	measurementsImportedTotal.WithLabelValues(measurementType).Inc()
+}
+
+func OCRRejected(measurementType, reason string) {
+	ocrRejectionsTotal.WithLabelValues(measurementType, reason).Inc()
+}
+
+func OCRCacheLookup(result string) {
+	ocrCacheRequestsTotal.WithLabelValues(result).Inc()
+}
+
+func MeasurementFlagged(measurementType string) {
+	measurementsFlaggedTotal.WithLabelValues(measurementType).Inc()
+}
+
+func MeasurementConfirmed(measurementType string, corrected bool) {
+	measurementsConfirmedTotal.WithLabelValues(measurementType, strconv.FormatBool(corrected)).Inc()
+}
+
+func AlertRaised(measurementType, kind, severity string) {
+	alertsRaisedTotal.WithLabelValues(measurementType, kind, severity).Inc()
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
@@ -0,0 +1,73 @@
+package ocrcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/logger"
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/throttle"
+)
+
+const pruneInterval = 10 * time.Minute
+
+// Database keeps responses in the ocr_cache_entries table so every replica
+// shares them. Expired entries and the oldest ones beyond size are pruned
+// at most once every pruneInterval, after a write.
+type Database struct {
+	repository database.OCRCacheInterface
+	size       int
+	ttl        time.Duration
+	prune      *throttle.Throttle
+}
+
+func NewDatabase(repository database.OCRCacheInterface, size int, ttl time.Duration) *Database {
+	return &Database{
+		repository: repository,
+		size:       size,
+		ttl:        ttl,
+		prune:      throttle.New(pruneInterval),
+	}
+}
+
+func (d *Database) Get(key string, ctx context.Context) (dto.ProcessImageResponse, bool, error) {
+	var resp dto.ProcessImageResponse
+	entry, err := d.repository.FindByKey(key, ctx)
+	if errors.Is(err, database.ErrNotFound) {
+		return resp, false, nil
+	}
+	if err != nil {
+		return resp, false, err
+	}
+	if err := json.Unmarshal([]byte(entry.Response), &resp); err != nil {
+		return resp, false, err
+	}
+	return resp, true, nil
+}
+
+func (d *Database) Set(key string, response dto.ProcessImageResponse, ctx context.Context) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	entry, err := entity.NewOCRCacheEntry(key, entity.JSON(data), d.ttl)
+	if err != nil {
+		return err
+	}
+	if err := d.repository.Save(entry, ctx); err != nil {
+		return err
+	}
+	if d.prune.Allow() {
+		deleted, err := d.repository.Prune(d.size, ctx)
+		if err != nil {
+			return err
+		}
+		logger.FromContext(ctx).DebugContext(ctx, "ocr cache pruned", slog.Int64("deleted", deleted))
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package ocrcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/lru"
+)
+
+// Memory keeps responses in a per process LRU cache.
+type Memory struct {
+	cache *lru.Cache[string, dto.ProcessImageResponse]
+}
+
+func NewMemory(size int, ttl time.Duration) *Memory {
+	return &Memory{
+		cache: lru.New[string, dto.ProcessImageResponse](size, ttl),
+	}
+}
+
+func (m *Memory) Get(key string, ctx context.Context) (dto.ProcessImageResponse, bool, error) {
+	resp, ok := m.cache.Get(key)
+	return resp, ok, nil
+}
+
+func (m *Memory) Set(key string, response dto.ProcessImageResponse, ctx context.Context) error {
+	m.cache.Add(key, response)
+	return nil
+}
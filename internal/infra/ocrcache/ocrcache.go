@@ -0,0 +1,121 @@
+package ocrcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/gemini"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/logger"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/metrics"
+	"gorm.io/gorm"
+)
+
+const (
+	StoreNone     = "none"
+	StoreMemory   = "memory"
+	StoreDatabase = "database"
+
+	resultHit   = "hit"
+	resultMiss  = "miss"
+	resultError = "error"
+)
+
+var ErrInvalidStore = errors.New("invalid ocr cache store")
+
+// Store holds provider responses by cache key. Get reports false when the
+// key is missing or has expired.
+type Store interface {
+	Get(key string, ctx context.Context) (dto.ProcessImageResponse, bool, error)
+	Set(key string, response dto.ProcessImageResponse, ctx context.Context) error
+}
+
+// Gemini answers repeated requests for the same image, model and prompt
+// version from a Store instead of calling the provider again. Failed calls
+// are never cached. A nil Store disables caching.
+type Gemini struct {
+	next    gemini.GeminiInterface
+	store   Store
+	model   string
+	prompts *gemini.Prompts
+}
+
+func NewGemini(next gemini.GeminiInterface, store Store, model string, prompts *gemini.Prompts) *Gemini {
+	return &Gemini{
+		next:    next,
+		store:   store,
+		model:   model,
+		prompts: prompts,
+	}
+}
+
+func (g *Gemini) ProcessImage(request dto.ProcessImageRequest, ctx context.Context) (dto.ProcessImageResponse, error) {
+	if g.store == nil {
+		return g.next.ProcessImage(request, ctx)
+	}
+	image, err := base64.StdEncoding.DecodeString(request.Data)
+	if err != nil {
+		return g.next.ProcessImage(request, ctx)
+	}
+	// The prompt is picked here rather than by the client so that requests
+	// routed to a candidate prompt get their own cache entries.
+	prompt, err := g.prompts.Select(request.PromptVersion)
+	if err != nil {
+		return dto.ProcessImageResponse{}, err
+	}
+	request.PromptVersion = prompt.Key()
+	key := Key(image, g.model, prompt.Key())
+	log := logger.FromContext(ctx)
+
+	resp, ok, err := g.store.Get(key, ctx)
+	switch {
+	case err != nil:
+		metrics.OCRCacheLookup(resultError)
+		log.WarnContext(ctx, "ocr cache lookup failed", slog.Any("error", err))
+	case ok:
+		metrics.OCRCacheLookup(resultHit)
+		log.DebugContext(ctx, "ocr cache hit", slog.String("prompt_version", prompt.Key()))
+		// Nothing was spent on this request.
+		resp.Usage = dto.ProcessImageUsage{}
+		return resp, nil
+	default:
+		metrics.OCRCacheLookup(resultMiss)
+		log.DebugContext(ctx, "ocr cache miss", slog.String("prompt_version", prompt.Key()))
+	}
+
+	resp, err = g.next.ProcessImage(request, ctx)
+	if err != nil {
+		return resp, err
+	}
+	if err := g.store.Set(key, resp, ctx); err != nil {
+		log.WarnContext(ctx, "ocr cache store failed", slog.Any("error", err))
+	}
+	return resp, nil
+}
+
+// Key identifies a provider response by the image bytes, the model and the
+// prompt version that produced it.
+func Key(image []byte, model, promptVersion string) string {
+	sum := sha256.Sum256(image)
+	return hex.EncodeToString(sum[:]) + ":" + model + ":" + promptVersion
+}
+
+// NewStore returns the store named by kind, or nil when caching is disabled.
+func NewStore(kind string, db *gorm.DB, size int, ttl time.Duration) (Store, error) {
+	switch kind {
+	case StoreNone:
+		return nil, nil
+	case StoreMemory:
+		return NewMemory(size, ttl), nil
+	case StoreDatabase:
+		return NewDatabase(database.NewOCRCache(db), size, ttl), nil
+	default:
+		return nil, ErrInvalidStore
+	}
+}
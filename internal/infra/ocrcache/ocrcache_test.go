@@ -0,0 +1,119 @@
+package ocrcache
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/gemini"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeGemini struct {
+	calls    int
+	requests []dto.ProcessImageRequest
+	err      error
+}
+
+func (f *fakeGemini) ProcessImage(request dto.ProcessImageRequest, ctx context.Context) (dto.ProcessImageResponse, error) {
+	f.calls++
+	f.requests = append(f.requests, request)
+	if f.err != nil {
+		return dto.ProcessImageResponse{}, f.err
+	}
+	return dto.ProcessImageResponse{
+		Value:         "19",
+		PromptVersion: request.PromptVersion,
+		Usage:         dto.ProcessImageUsage{TotalTokens: 100},
+	}, nil
+}
+
+func newCachedGemini(t *testing.T, next gemini.GeminiInterface, store Store) *Gemini {
+	prompts, err := gemini.NewPrompts("", "meter-reading@v3", "", 0)
+	assert.NoError(t, err)
+	return NewGemini(next, store, "gemini-1.5-flash", prompts)
+}
+
+func request(image string) dto.ProcessImageRequest {
+	return dto.ProcessImageRequest{Mime: "image/jpeg", Data: base64.StdEncoding.EncodeToString([]byte(image))}
+}
+
+func TestCacheAnswersRepeatedRequests(t *testing.T) {
+	next := &fakeGemini{}
+	g := newCachedGemini(t, next, NewMemory(10, time.Hour))
+	ctx := context.Background()
+
+	first, err := g.ProcessImage(request("image"), ctx)
+	assert.NoError(t, err)
+	second, err := g.ProcessImage(request("image"), ctx)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, next.calls)
+	assert.Equal(t, "meter-reading@v3", next.requests[0].PromptVersion)
+	assert.Equal(t, first.Value, second.Value)
+	assert.Equal(t, 100, first.Usage.TotalTokens)
+	assert.Equal(t, 0, second.Usage.TotalTokens)
+}
+
+func TestCacheIsKeyedByImageAndPromptVersion(t *testing.T) {
+	next := &fakeGemini{}
+	g := newCachedGemini(t, next, NewMemory(10, time.Hour))
+	ctx := context.Background()
+
+	g.ProcessImage(request("image"), ctx)
+	g.ProcessImage(request("other image"), ctx)
+	pinned := request("image")
+	pinned.PromptVersion = "meter-reading@v2"
+	g.ProcessImage(pinned, ctx)
+
+	assert.Equal(t, 3, next.calls)
+}
+
+func TestCacheDoesNotStoreFailures(t *testing.T) {
+	next := &fakeGemini{err: errors.New("unavailable")}
+	g := newCachedGemini(t, next, NewMemory(10, time.Hour))
+	ctx := context.Background()
+
+	_, err := g.ProcessImage(request("image"), ctx)
+	assert.Error(t, err)
+	_, err = g.ProcessImage(request("image"), ctx)
+	assert.Error(t, err)
+
+	assert.Equal(t, 2, next.calls)
+}
+
+func TestCacheRejectsUnknownPrompt(t *testing.T) {
+	next := &fakeGemini{}
+	g := newCachedGemini(t, next, NewMemory(10, time.Hour))
+	req := request("image")
+	req.PromptVersion = "meter-reading@v99"
+
+	_, err := g.ProcessImage(req, context.Background())
+	assert.Equal(t, gemini.ErrUnknownPrompt, err)
+	assert.Equal(t, 0, next.calls)
+}
+
+func TestDatabaseStore(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.OCRCacheEntry{})
+	next := &fakeGemini{}
+	g := newCachedGemini(t, next, NewDatabase(database.NewOCRCache(db), 10, time.Hour))
+	ctx := context.Background()
+
+	g.ProcessImage(request("image"), ctx)
+	resp, err := g.ProcessImage(request("image"), ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "19", resp.Value)
+	assert.Equal(t, "meter-reading@v3", resp.PromptVersion)
+	assert.Equal(t, 1, next.calls)
+}
@@ -8,4 +8,12 @@ import (
 
 type MeasurementStorageInterface interface {
 	UploadFile(file string, ctx context.Context) (*uploader.UploadResult, error)
+	DeleteFile(publicID string, ctx context.Context) error
+	GetFile(url string, ctx context.Context) (*File, error)
+	Stores(url string) bool
+}
+
+type File struct {
+	Data        []byte
+	ContentType string
 }
@@ -2,22 +2,64 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 )
 
+const (
+	// maxFileSize bounds downloads so a corrupt or hostile asset cannot
+	// exhaust memory.
+	maxFileSize = 20 << 20
+	// deliveryHost serves the files of every cloud under /<cloud name>/.
+	deliveryHost = "res.cloudinary.com"
+)
+
+var (
+	ErrFileNotFound = errors.New("file not found")
+	ErrFileTooLarge = errors.New("file too large")
+	ErrForeignURL   = errors.New("url does not point to the storage")
+)
+
 type Storage struct {
-	storage *cloudinary.Cloudinary
+	storage   *cloudinary.Cloudinary
+	cloudName string
+	client    *http.Client
 }
 
 func NewStorage(cloudName, apiKey, apiSecret string) (*Storage, error) {
 	cld, err := cloudinary.NewFromParams(cloudName, apiKey, apiSecret)
 	return &Storage{
-		storage: cld,
+		storage:   cld,
+		cloudName: cloudName,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			// Stored files are served directly, so a redirect can only lead
+			// away from the storage.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
 	}, err
 }
 
+// Stores reports whether rawURL is an https URL of a file in the configured
+// cloud, the only URLs GetFile downloads.
+func (s *Storage) Stores(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || s.cloudName == "" {
+		return false
+	}
+	return u.Scheme == "https" && u.User == nil && u.Host == deliveryHost && strings.HasPrefix(u.Path, "/"+s.cloudName+"/")
+}
+
 func (s *Storage) UploadFile(file string, ctx context.Context) (*uploader.UploadResult, error) {
 	resp, err := s.storage.Upload.Upload(ctx, file, uploader.UploadParams{})
 	if err != nil {
@@ -25,3 +67,59 @@ func (s *Storage) UploadFile(file string, ctx context.Context) (*uploader.Upload
 	}
 	return resp, err
 }
+
+func (s *Storage) DeleteFile(publicID string, ctx context.Context) error {
+	resp, err := s.storage.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: publicID})
+	if err != nil {
+		return err
+	}
+	if resp.Error.Message != "" {
+		return errors.New(resp.Error.Message)
+	}
+	return nil
+}
+
+// GetFile downloads a stored file by the URL returned from UploadFile. URLs
+// outside the storage return ErrForeignURL and redirects are not followed,
+// so the server cannot be made to fetch arbitrary hosts.
+func (s *Storage) GetFile(fileURL string, ctx context.Context) (*File, error) {
+	if !s.Stores(fileURL) {
+		return nil, ErrForeignURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrFileNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status downloading file: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFileSize {
+		return nil, ErrFileTooLarge
+	}
+	return &File{Data: data, ContentType: resp.Header.Get("Content-Type")}, nil
+}
+
+// Ping checks that the storage backend is reachable with the configured
+// credentials.
+func (s *Storage) Ping(ctx context.Context) error {
+	resp, err := s.storage.Admin.Ping(ctx)
+	if err != nil {
+		return err
+	}
+	if resp.Error.Message != "" {
+		return errors.New(resp.Error.Message)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStores(t *testing.T) {
+	s, err := NewStorage("demo", "key", "secret")
+	assert.NoError(t, err)
+
+	assert.True(t, s.Stores("https://res.cloudinary.com/demo/image/upload/v1/sample.jpg"))
+	assert.False(t, s.Stores("http://res.cloudinary.com/demo/image/upload/v1/sample.jpg"))
+	assert.False(t, s.Stores("https://res.cloudinary.com/other/image/upload/v1/sample.jpg"))
+	assert.False(t, s.Stores("https://res.cloudinary.com.example.com/demo/sample.jpg"))
+	assert.False(t, s.Stores("https://user@res.cloudinary.com/demo/sample.jpg"))
+	assert.False(t, s.Stores("https://169.254.169.254/latest/meta-data/"))
+	assert.False(t, s.Stores("not a url"))
+}
+
+func TestGetFileRefusesForeignURL(t *testing.T) {
+	s, err := NewStorage("demo", "key", "secret")
+	assert.NoError(t, err)
+
+	_, err = s.GetFile("https://169.254.169.254/latest/meta-data/", context.Background())
+	assert.Equal(t, ErrForeignURL, err)
+}
@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/gemini"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Gemini struct {
+	next gemini.GeminiInterface
+}
+
+func NewGemini(next gemini.GeminiInterface) *Gemini {
+	return &Gemini{
+		next: next,
+	}
+}
+
+func (g *Gemini) ProcessImage(request dto.ProcessImageRequest, ctx context.Context) (dto.ProcessImageResponse, error) {
+	ctx, span := tracer().Start(ctx, "gemini.ProcessImage",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("image.mime", request.Mime),
+			attribute.Int("image.size", len(request.Data)),
+		),
+	)
+	defer span.End()
+
+	resp, err := g.next.ProcessImage(request, ctx)
+	span.SetAttributes(attribute.Int("ocr.tokens.total", resp.Usage.TotalTokens))
+	recordError(span, err)
+	return resp, err
+}
+
+type Storage struct {
+	next storage.MeasurementStorageInterface
+}
+
+func NewStorage(next storage.MeasurementStorageInterface) *Storage {
+	return &Storage{
+		next: next,
+	}
+}
+
+func (s *Storage) UploadFile(file string, ctx context.Context) (*uploader.UploadResult, error) {
+	ctx, span := tracer().Start(ctx, "storage.UploadFile",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int("file.size", len(file))),
+	)
+	defer span.End()
+
+	resp, err := s.next.UploadFile(file, ctx)
+	if resp != nil {
+		span.SetAttributes(attribute.String("storage.public_id", resp.PublicID))
+	}
+	recordError(span, err)
+	return resp, err
+}
+
+func (s *Storage) DeleteFile(publicID string, ctx context.Context) error {
+	ctx, span := tracer().Start(ctx, "storage.DeleteFile",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("storage.public_id", publicID)),
+	)
+	defer span.End()
+
+	err := s.next.DeleteFile(publicID, ctx)
+	recordError(span, err)
+	return err
+}
+
+func (s *Storage) GetFile(url string, ctx context.Context) (*storage.File, error) {
+	ctx, span := tracer().Start(ctx, "storage.GetFile",
+		trace.WithSpanKind(trace.SpanKindClient),
+	)
+	defer span.End()
+
+	file, err := s.next.GetFile(url, ctx)
+	if file != nil {
+		span.SetAttributes(attribute.Int("file.size", len(file.Data)))
+	}
+	recordError(span, err)
+	return file, err
+}
+
+func (s *Storage) Stores(url string) bool {
+	return s.next.Stores(url)
+}
+
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+	entityPkg "github.com/melkzsiqueira/water-gas-measurement/pkg/entity"
+)
+
+type AlertHandler struct {
+	AlertDB database.AlertInterface
+}
+
+func NewAlertHandler(db database.AlertInterface) *AlertHandler {
+	return &AlertHandler{
+		AlertDB: db,
+	}
+}
+
+// List alerts			godoc
+// @Summary      		List alerts
+// @Description  		Get the consumption anomalies raised by confirmed readings, filtered by user, type, kind, severity and reading date
+// @Tags         		alerts
+// @Accept       		json
+// @Produce      		json
+// @Param        		user      		query   	string  			false  "user ID"
+// @Param        		type      		query   	string  			false  "measurement type"	Enums(1, 2)
+// @Param        		kind      		query   	string  			false  "alert kind"	Enums(spike, drop, zero_consumption)
+// @Param        		severity  		query   	string  			false  "severity"	Enums(warning, critical)
+// @Param        		from      		query   	string  			false  "start reading date (RFC 3339)"
+// @Param        		to        		query   	string  			false  "end reading date (RFC 3339)"
+// @Param        		page      		query   	string  			false  "page number"
+// @Param        		limit     		query   	string  			false  "records limit"
+// @Param        		sort      		query   	string  			false  "sort order"	Enums(asc, desc)
+// @Success      		200       		{array} 	entity.Alert
+// @Failure      		400       		{object}	Problem
+// @Failure      		500       		{object}	Problem
+// @Router       		/alerts 		[get]
+// @Security 			ApiKeyAuth
+func (h *AlertHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page := query.Get("page")
+	if page == "" || page == "0" {
+		page = "1"
+	}
+	pageInt, err := strconv.Atoi(page)
+	if err != nil {
+		writeProblem(w, r, invalidParameter("page"))
+		return
+	}
+
+	limit := query.Get("limit")
+	if limit == "" || limit == "0" {
+		limit = "10"
+	}
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		writeProblem(w, r, invalidParameter("limit"))
+		return
+	}
+
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = "desc"
+	}
+	if sort != "asc" && sort != "desc" {
+		writeProblem(w, r, invalidParameter("sort"))
+		return
+	}
+
+	filter := database.AlertFilter{
+		User:     query.Get("user"),
+		Type:     query.Get("type"),
+		Kind:     query.Get("kind"),
+		Severity: query.Get("severity"),
+	}
+	if filter.User != "" {
+		if _, err := entityPkg.ParseID(filter.User); err != nil {
+			writeProblem(w, r, invalidParameter("user"))
+			return
+		}
+	}
+	if filter.Type != "" && filter.Type != entity.MeasurementTypeWater && filter.Type != entity.MeasurementTypeGas {
+		writeProblem(w, r, invalidParameter("type"))
+		return
+	}
+	if filter.Kind != "" && filter.Kind != entity.AlertKindSpike && filter.Kind != entity.AlertKindDrop && filter.Kind != entity.AlertKindZero {
+		writeProblem(w, r, invalidParameter("kind"))
+		return
+	}
+	if filter.Severity != "" && filter.Severity != entity.AlertSeverityWarning && filter.Severity != entity.AlertSeverityCritical {
+		writeProblem(w, r, invalidParameter("severity"))
+		return
+	}
+	if from := query.Get("from"); from != "" {
+		filter.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			writeProblem(w, r, invalidParameter("from"))
+			return
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		filter.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			writeProblem(w, r, invalidParameter("to"))
+			return
+		}
+	}
+
+	alerts, err := h.AlertDB.FindAll(filter, pageInt, limitInt, sort, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(alerts)
+}
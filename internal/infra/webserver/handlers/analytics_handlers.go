@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/analytics"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/billing"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+	entityPkg "github.com/melkzsiqueira/water-gas-measurement/pkg/entity"
+)
+
+const (
+	// analyticsDefaultMonths is the range, ending in the current month,
+	// reported when from is not given.
+	analyticsDefaultMonths = 12
+	// analyticsMaxMonths bounds the range of a single query.
+	analyticsMaxMonths = 120
+	defaultWindow      = 3
+	maxWindow          = 24
+)
+
+type AnalyticsHandler struct {
+	AnalyticsDB database.AnalyticsInterface
+}
+
+func NewAnalyticsHandler(db database.AnalyticsInterface) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		AnalyticsDB: db,
+	}
+}
+
+// Get consumption		godoc
+// @Summary      		Get consumption
+// @Description  		Get the consumption and daily average of every period with readings, for a user or across all users. Consumption is attributed to the month of the later of two confirmed readings
+// @Tags         		analytics
+// @Accept       		json
+// @Produce      		json
+// @Param        		type      				query   	string  	true   "measurement type"	Enums(1, 2)
+// @Param        		user      				query   	string  	false  "user ID"
+// @Param        		from      				query   	string  	false  "first month (YYYY-MM), 11 months before to by default"
+// @Param        		to        				query   	string  	false  "last month (YYYY-MM), the current month by default"
+// @Param        		granularity				query   	string  	false  "period length"	Enums(month, quarter, season, year)
+// @Success      		200       				{array} 	analytics.Usage
+// @Failure      		400       				{object}	Problem
+// @Failure      		500       				{object}	Problem
+// @Router       		/analytics/consumption	[get]
+// @Security 			ApiKeyAuth
+func (h *AnalyticsHandler) GetConsumption(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter, err := analyticsFilter(query)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	granularity := analytics.Month
+	if value := query.Get("granularity"); value != "" {
+		granularity, err = analytics.ParseGranularity(value)
+		if err != nil {
+			writeProblem(w, r, invalidParameter("granularity"))
+			return
+		}
+	}
+
+	months, err := h.AnalyticsDB.Consumption(filter, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	writeAnalytics(w, analytics.Rollup(months, granularity))
+}
+
+// Get moving average	godoc
+// @Summary      		Get moving average
+// @Description  		Get the monthly consumption with the daily average over the window months ending in each month
+// @Tags         		analytics
+// @Accept       		json
+// @Produce      		json
+// @Param        		type      					query   	string  	true   "measurement type"	Enums(1, 2)
+// @Param        		user      					query   	string  	false  "user ID"
+// @Param        		from      					query   	string  	false  "first month (YYYY-MM), 11 months before to by default"
+// @Param        		to        					query   	string  	false  "last month (YYYY-MM), the current month by default"
+// @Param        		window    					query   	int     	false  "window length in months, 3 by default"	minimum(1)	maximum(24)
+// @Success      		200       					{array} 	analytics.Trend
+// @Failure      		400       					{object}	Problem
+// @Failure      		500       					{object}	Problem
+// @Router       		/analytics/moving-average	[get]
+// @Security 			ApiKeyAuth
+func (h *AnalyticsHandler) GetMovingAverage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter, err := analyticsFilter(query)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	window := defaultWindow
+	if value := query.Get("window"); value != "" {
+		window, err = strconv.Atoi(value)
+		if err != nil || window < 1 || window > maxWindow {
+			writeProblem(w, r, invalidParameter("window"))
+			return
+		}
+	}
+
+	trends, err := h.AnalyticsDB.MovingAverage(filter, window, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	writeAnalytics(w, trends)
+}
+
+// Get year over year	godoc
+// @Summary      		Get year over year
+// @Description  		Compare the monthly consumption with the same month of the previous year. The change is the percent change of the daily average
+// @Tags         		analytics
+// @Accept       		json
+// @Produce      		json
+// @Param        		type      					query   	string  	true   "measurement type"	Enums(1, 2)
+// @Param        		user      					query   	string  	false  "user ID"
+// @Param        		from      					query   	string  	false  "first month (YYYY-MM), 11 months before to by default"
+// @Param        		to        					query   	string  	false  "last month (YYYY-MM), the current month by default"
+// @Success      		200       					{array} 	analytics.Comparison
+// @Failure      		400       					{object}	Problem
+// @Failure      		500       					{object}	Problem
+// @Router       		/analytics/year-over-year	[get]
+// @Security 			ApiKeyAuth
+func (h *AnalyticsHandler) GetYearOverYear(w http.ResponseWriter, r *http.Request) {
+	filter, err := analyticsFilter(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	comparisons, err := h.AnalyticsDB.YearOverYear(filter, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	writeAnalytics(w, comparisons)
+}
+
+// Get percentiles		godoc
+// @Summary      		Get percentiles
+// @Description  		Get the distribution of the daily average of every meter of a type in a month, using the nearest rank method
+// @Tags         		analytics
+// @Accept       		json
+// @Produce      		json
+// @Param        		type      				query   	string  	true   "measurement type"	Enums(1, 2)
+// @Param        		period    				query   	string  	false  "month (YYYY-MM), the current month by default"
+// @Param        		ranks     				query   	string  	false  "comma separated ranks from 1 to 100, 50,75,90,95,99 by default"
+// @Success      		200       				{object}	analytics.Distribution
+// @Failure      		400       				{object}	Problem
+// @Failure      		500       				{object}	Problem
+// @Router       		/analytics/percentiles	[get]
+// @Security 			ApiKeyAuth
+func (h *AnalyticsHandler) GetPercentiles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	measurementType, err := analyticsType(query)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	period := billing.PeriodOf(time.Now())
+	if value := query.Get("period"); value != "" {
+		period, err = billing.ParsePeriod(value)
+		if err != nil {
+			writeProblem(w, r, invalidParameter("period"))
+			return
+		}
+	}
+	var ranks []int
+	if value := query.Get("ranks"); value != "" {
+		for _, field := range strings.Split(value, ",") {
+			rank, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				writeProblem(w, r, invalidParameter("ranks"))
+				return
+			}
+			ranks = append(ranks, rank)
+		}
+	}
+	ranks, err = analytics.ParseRanks(ranks)
+	if err != nil {
+		writeProblem(w, r, invalidParameter("ranks"))
+		return
+	}
+
+	distribution, err := h.AnalyticsDB.Percentiles(measurementType, analytics.MonthIndex(period), ranks, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	writeAnalytics(w, distribution)
+}
+
+// analyticsFilter reads the type, user and month range shared by the
+// analytics endpoints.
+func analyticsFilter(query url.Values) (database.AnalyticsFilter, error) {
+	measurementType, err := analyticsType(query)
+	if err != nil {
+		return database.AnalyticsFilter{}, err
+	}
+	filter := database.AnalyticsFilter{
+		User: query.Get("user"),
+		Type: measurementType,
+	}
+	if filter.User != "" {
+		if _, err := entityPkg.ParseID(filter.User); err != nil {
+			return filter, invalidParameter("user")
+		}
+	}
+
+	to := billing.PeriodOf(time.Now())
+	if value := query.Get("to"); value != "" {
+		to, err = billing.ParsePeriod(value)
+		if err != nil {
+			return filter, invalidParameter("to")
+		}
+	}
+	from := to.AddMonths(1 - analyticsDefaultMonths)
+	if value := query.Get("from"); value != "" {
+		from, err = billing.ParsePeriod(value)
+		if err != nil {
+			return filter, invalidParameter("from")
+		}
+	}
+	filter.From, filter.To = analytics.MonthIndex(from), analytics.MonthIndex(to)
+	if filter.From > filter.To || filter.To-filter.From >= analyticsMaxMonths {
+		return filter, invalidParameter("from")
+	}
+	return filter, nil
+}
+
+func analyticsType(query url.Values) (string, error) {
+	measurementType := query.Get("type")
+	if measurementType != entity.MeasurementTypeWater && measurementType != entity.MeasurementTypeGas {
+		return "", invalidParameter("type")
+	}
+	return measurementType, nil
+}
+
+func writeAnalytics(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(body)
+}
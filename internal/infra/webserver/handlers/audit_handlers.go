@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+)
+
+type AuditHandler struct {
+	AuditEventDB database.AuditEventInterface
+}
+
+func NewAuditHandler(db database.AuditEventInterface) *AuditHandler {
+	return &AuditHandler{
+		AuditEventDB: db,
+	}
+}
+
+// List audit events	godoc
+// @Summary      		List audit events
+// @Description  		Get audit events filtered by actor, action, entity and period
+// @Tags         		audit
+// @Accept       		json
+// @Produce      		json
+// @Param        		actor      		query   	string  			false  "actor ID"
+// @Param        		action     		query   	string  			false  "action"	Enums(create, update, confirm, delete)
+// @Param        		entity     		query   	string  			false  "entity name"
+// @Param        		entity_id  		query   	string  			false  "entity ID"
+// @Param        		from       		query   	string  			false  "start date (RFC 3339)"
+// @Param        		to         		query   	string  			false  "end date (RFC 3339)"
+// @Param        		page       		query   	string  			false  "page number"
+// @Param        		limit      		query   	string  			false  "records limit"
+// @Param        		sort       		query   	string  			false  "sort order"	Enums(asc, desc)
+// @Success      		200       		{array} 	entity.AuditEvent
+// @Failure      		400       		{object}	Problem
+// @Failure      		500       		{object}	Problem
+// @Router       		/audit 			[get]
+// @Security 			ApiKeyAuth
+func (h *AuditHandler) GetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page := query.Get("page")
+	if page == "" || page == "0" {
+		page = "1"
+	}
+	pageInt, err := strconv.Atoi(page)
+	if err != nil {
+		writeProblem(w, r, invalidParameter("page"))
+		return
+	}
+
+	limit := query.Get("limit")
+	if limit == "" || limit == "0" {
+		limit = "10"
+	}
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		writeProblem(w, r, invalidParameter("limit"))
+		return
+	}
+
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = "desc"
+	}
+	if sort != "asc" && sort != "desc" {
+		writeProblem(w, r, invalidParameter("sort"))
+		return
+	}
+
+	filter := database.AuditEventFilter{
+		Actor:    query.Get("actor"),
+		Action:   query.Get("action"),
+		Entity:   query.Get("entity"),
+		EntityID: query.Get("entity_id"),
+	}
+	if from := query.Get("from"); from != "" {
+		filter.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			writeProblem(w, r, invalidParameter("from"))
+			return
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		filter.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			writeProblem(w, r, invalidParameter("to"))
+			return
+		}
+	}
+
+	events, err := h.AuditEventDB.FindAll(filter, pageInt, limitInt, sort, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(events)
+}
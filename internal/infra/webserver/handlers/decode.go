@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	maxBodySize      = 1 << 20
+	maxImageBodySize = 10 << 20
+	maxImportSize    = 100 << 20
+)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// decodeAndValidate reads at most maxBytes of JSON from the request body
+// into dst, rejecting unknown fields and trailing data, and then runs the
+// validate tags of dst, reporting every failing field at once.
+func decodeAndValidate(w http.ResponseWriter, r *http.Request, dst any, maxBytes int64) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(dst)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("body must contain a single JSON object")
+	}
+	if err != nil {
+		return decodeError(err, maxBytes)
+	}
+	return validateStruct(dst)
+}
+
+func decodeError(err error, maxBytes int64) error {
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &apiError{
+			Status: http.StatusRequestEntityTooLarge,
+			Code:   "body_too_large",
+			Detail: fmt.Sprintf("request body must not be larger than %d bytes", maxBytes),
+			Err:    err,
+		}
+	case errors.As(err, &typeErr):
+		return &apiError{
+			Status: http.StatusBadRequest,
+			Code:   "invalid_field_type",
+			Detail: typeErr.Field + " must be a " + typeErr.Type.String(),
+			Errors: []FieldError{{Field: typeErr.Field, Code: "invalid_field_type", Message: "must be a " + typeErr.Type.String()}},
+			Err:    err,
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &apiError{
+			Status: http.StatusBadRequest,
+			Code:   "unknown_field",
+			Detail: "unknown field " + field,
+			Errors: []FieldError{{Field: field, Code: "unknown_field", Message: "field is not allowed"}},
+			Err:    err,
+		}
+	case errors.Is(err, io.EOF):
+		return badRequest("malformed_body", "request body must not be empty")
+	}
+	return malformedBody(err)
+}
+
+func validateStruct(value any) error {
+	err := validate.Struct(value)
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+	fieldErrs := make([]FieldError, 0, len(validationErrs))
+	for _, validationErr := range validationErrs {
+		_, field, _ := strings.Cut(validationErr.Namespace(), ".")
+		fieldErrs = append(fieldErrs, FieldError{
+			Field:   field,
+			Code:    validationErr.Tag(),
+			Message: validationMessage(validationErr),
+		})
+	}
+	return &apiError{
+		Status: http.StatusUnprocessableEntity,
+		Code:   "validation_failed",
+		Detail: "request body has invalid fields",
+		Errors: fieldErrs,
+		Err:    err,
+	}
+}
+
+func validationMessage(err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(err.Param(), " ", ", ")
+	case "email":
+		return "must be a valid email"
+	case "uuid":
+		return "must be a valid UUID"
+	case "base64":
+		return "must be base64 encoded"
+	case "url":
+		return "must be a valid URL"
+	case "len":
+		return "must have exactly " + err.Param() + " characters"
+	case "uppercase":
+		return "must be uppercase"
+	case "startswith":
+		return "must start with " + err.Param()
+	case "min", "gte":
+		if err.Kind() == reflect.String {
+			return "must have at least " + err.Param() + " characters"
+		}
+		return "must be at least " + err.Param()
+	case "max", "lte":
+		if err.Kind() == reflect.String {
+			return "must have at most " + err.Param() + " characters"
+		}
+		return "must be at most " + err.Param()
+	case "gt":
+		return "must be greater than " + err.Param()
+	}
+	return "is invalid"
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const anyVersion = 0
+
+var (
+	errIfMatchIsRequired = errors.New("If-Match header is required")
+	errIfMatchIsInvalid  = errors.New("If-Match header is invalid")
+)
+
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch returns the versions referenced by an If-Match header, which
+// may list several entity tags separated by commas, or anyVersion alone when
+// the client sent "*". If-Match uses the strong comparison, so weak tags are
+// accepted but never match and tags that are not a version are ignored.
+func parseIfMatch(header string) ([]int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, errIfMatchIsRequired
+	}
+	if header == "*" {
+		return []int{anyVersion}, nil
+	}
+	var versions []int
+	var tags int
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		tags++
+		weak := strings.HasPrefix(tag, "W/")
+		tag = strings.TrimPrefix(tag, "W/")
+		if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || strings.Contains(tag[1:len(tag)-1], `"`) {
+			return nil, errIfMatchIsInvalid
+		}
+		if weak {
+			continue
+		}
+		version, err := strconv.Atoi(tag[1 : len(tag)-1])
+		if err == nil && version >= 1 {
+			versions = append(versions, version)
+		}
+	}
+	if tags == 0 {
+		return nil, errIfMatchIsInvalid
+	}
+	return versions, nil
+}
+
+// matchesIfMatch reports whether version satisfies the versions returned by
+// parseIfMatch.
+func matchesIfMatch(versions []int, version int) bool {
+	for _, v := range versions {
+		if v == anyVersion || v == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header   string
+		versions []int
+		err      error
+	}{
+		{``, nil, errIfMatchIsRequired},
+		{`*`, []int{anyVersion}, nil},
+		{`"3"`, []int{3}, nil},
+		{`"2", "3" ,"abc"`, []int{2, 3}, nil},
+		{`W/"3"`, nil, nil},
+		{`W/"2", "4"`, []int{4}, nil},
+		{`3`, nil, errIfMatchIsInvalid},
+		{`"3", 4`, nil, errIfMatchIsInvalid},
+		{` , `, nil, errIfMatchIsInvalid},
+	}
+	for _, test := range tests {
+		versions, err := parseIfMatch(test.header)
+		assert.Equal(t, test.err, err, test.header)
+		assert.Equal(t, test.versions, versions, test.header)
+	}
+}
+
+func TestMatchesIfMatch(t *testing.T) {
+	assert.True(t, matchesIfMatch([]int{anyVersion}, 7))
+	assert.True(t, matchesIfMatch([]int{2, 7}, 7))
+	assert.False(t, matchesIfMatch([]int{2}, 7))
+	// Weak tags leave nothing to match.
+	versions, err := parseIfMatch(`W/"7"`)
+	assert.NoError(t, err)
+	assert.False(t, matchesIfMatch(versions, 7))
+}
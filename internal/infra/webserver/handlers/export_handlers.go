@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/exporter"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/logger"
+)
+
+const (
+	exportBufferSize     = 32 << 10
+	exportStatusHeader   = "Export-Status"
+	defaultExportTimeout = 10 * time.Minute
+)
+
+// Export measurements	godoc
+// @Summary      		Export measurements
+// @Description  		Stream the measurements matching the list filters as CSV, NDJSON or XLSX, with the previous reading and the consumption of the same user and type
+// @Tags         		measurements
+// @Produce      		text/csv,application/x-ndjson,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param        		format    		query   	string  			false  "file format"	Enums(csv, ndjson, xlsx)
+// @Param        		user      		query   	string  			false  "user ID"
+// @Param        		type      		query   	string  			false  "measurement type"	Enums(1, 2)
+// @Param        		confirmed 		query   	bool    			false  "confirmed readings only (true) or unconfirmed only (false)"
+// @Param        		from      		query   	string  			false  "start date (RFC 3339)"
+// @Param        		to        		query   	string  			false  "end date (RFC 3339)"
+// @Param        		sort      		query   	string  			false  "sort order"	Enums(asc, desc)
+// @Success      		200       		{file}  	file
+// @Header       		200       		{string}	Export-Status	"trailer set to complete, or failed when the file was cut short"
+// @Failure      		400       		{object}	Problem
+// @Failure      		500       		{object}	Problem
+// @Router       		/measurements/export	[get]
+// @Security 			ApiKeyAuth
+func (h *MeasurementHandler) ExportMeasurements(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exporter.FormatCSV
+	}
+	if format != exporter.FormatCSV && format != exporter.FormatNDJSON && format != exporter.FormatXLSX {
+		writeProblem(w, r, invalidParameter("format"))
+		return
+	}
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		sort = "asc"
+	}
+	if sort != "asc" && sort != "desc" {
+		writeProblem(w, r, invalidParameter("sort"))
+		return
+	}
+	filter, err := parseMeasurementFilter(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	// Large exports take longer than the server write timeout allows. The
+	// deadline stays bounded so a client that stops reading cannot hold the
+	// database connection the rows are streamed from.
+	timeout := h.Options.ExportTimeout
+	if timeout <= 0 {
+		timeout = defaultExportTimeout
+	}
+	err = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		writeProblem(w, r, err)
+		return
+	}
+
+	filename := "measurements-" + time.Now().UTC().Format("20060102") + "." + format
+	w.Header().Set("Content-Type", exporter.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Trailer", exportStatusHeader)
+	w.WriteHeader(http.StatusOK)
+
+	buf := bufio.NewWriterSize(w, exportBufferSize)
+	out, err := exporter.NewWriter(format, buf)
+	if err == nil {
+		rows := 0
+		err = h.MeasurementDB.StreamWithConsumption(filter, sort, func(m *entity.MeasurementWithConsumption) error {
+			rows++
+			return out.Write(m)
+		}, r.Context())
+		if err == nil {
+			err = out.Close()
+		}
+		if err == nil {
+			err = buf.Flush()
+		}
+		logger.FromContext(r.Context()).DebugContext(r.Context(), "measurements exported",
+			slog.String("format", format),
+			slog.Int("rows", rows),
+		)
+	}
+	if err != nil {
+		// The status line is already sent, so the failure can only be
+		// reported in the trailer; the file is truncated.
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "export failed", slog.Any("error", err))
+		w.Header().Set(exportStatusHeader, "failed")
+		return
+	}
+	w.Header().Set(exportStatusHeader, "complete")
+}
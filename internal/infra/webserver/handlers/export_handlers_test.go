@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+)
+
+// endlessMeasurementDB streams measurements until the callback fails and
+// reports the error it stopped with.
+type endlessMeasurementDB struct {
+	database.MeasurementInterface
+	stopped chan error
+}
+
+func (db *endlessMeasurementDB) StreamWithConsumption(filter database.MeasurementFilter, sort string, fn func(measurement *entity.MeasurementWithConsumption) error, ctx context.Context) error {
+	m := &entity.MeasurementWithConsumption{Measurement: entity.Measurement{Value: 1, Type: entity.MeasurementTypeWater, User: idempotencyUser}}
+	var err error
+	for err == nil {
+		err = fn(m)
+	}
+	db.stopped <- err
+	return err
+}
+
+func TestExportCutsOffSlowReader(t *testing.T) {
+	db := &endlessMeasurementDB{stopped: make(chan error, 1)}
+	h := &MeasurementHandler{MeasurementDB: db, Options: MeasurementOptions{ExportTimeout: 200 * time.Millisecond}}
+	server := httptest.NewServer(http.HandlerFunc(h.ExportMeasurements))
+	defer server.Close()
+
+	// The client reads the status line and then stops reading the body.
+	resp, err := http.Get(server.URL + "?format=ndjson")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	select {
+	case err := <-db.stopped:
+		assert.Error(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("export kept streaming to a client that stopped reading")
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/logger"
+)
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+	healthStatusDraining    = "draining"
+)
+
+// HealthCheck probes a single dependency. Check must honour ctx so a hung
+// dependency cannot block the readiness probe past its timeout. When
+// CacheFor is set the result is reused for that long, so that probes do not
+// call rate-limited third-party APIs every time.
+type HealthCheck struct {
+	Name     string
+	Check    func(ctx context.Context) error
+	CacheFor time.Duration
+}
+
+type HealthHandler struct {
+	Checks   []HealthCheck
+	Timeout  time.Duration
+	draining atomic.Bool
+	mu       sync.Mutex
+	cache    map[string]cachedStatus
+}
+
+type cachedStatus struct {
+	status  dependencyStatus
+	expires time.Time
+}
+
+type dependencyStatus struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type healthResponse struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]dependencyStatus `json:"dependencies,omitempty"`
+}
+
+func NewHealthHandler(timeout time.Duration, checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{
+		Checks:  checks,
+		Timeout: timeout,
+		cache:   make(map[string]cachedStatus),
+	}
+}
+
+// Liveness reports that the process is able to serve HTTP. It never touches
+// dependencies so a database outage does not get the process restarted.
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthResponse{Status: healthStatusOK})
+}
+
+// Drain makes readiness fail so the orchestrator stops routing traffic to
+// the process while it shuts down.
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// Readiness runs every dependency check concurrently, each bounded by
+// Timeout, and answers 503 when any of them fails or the process is
+// draining.
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		writeHealth(w, http.StatusServiceUnavailable, healthResponse{Status: healthStatusDraining})
+		return
+	}
+
+	response := healthResponse{
+		Status:       healthStatusOK,
+		Dependencies: make(map[string]dependencyStatus, len(h.Checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range h.Checks {
+		wg.Add(1)
+		go func(check HealthCheck) {
+			defer wg.Done()
+			status := h.cached(check, r.Context())
+			mu.Lock()
+			response.Dependencies[check.Name] = status
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for _, status := range response.Dependencies {
+		if status.Status != healthStatusOK {
+			response.Status = healthStatusUnavailable
+			code = http.StatusServiceUnavailable
+		}
+	}
+	writeHealth(w, code, response)
+}
+
+// cached returns the last result of check while it is fresh and runs it
+// otherwise.
+func (h *HealthHandler) cached(check HealthCheck, ctx context.Context) dependencyStatus {
+	if check.CacheFor <= 0 {
+		return h.run(check, ctx)
+	}
+	h.mu.Lock()
+	entry, ok := h.cache[check.Name]
+	h.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.status
+	}
+	status := h.run(check, ctx)
+	h.mu.Lock()
+	h.cache[check.Name] = cachedStatus{status: status, expires: time.Now().Add(check.CacheFor)}
+	h.mu.Unlock()
+	return status
+}
+
+func (h *HealthHandler) run(check HealthCheck, ctx context.Context) dependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Check(ctx)
+	status := dependencyStatus{
+		Status:     healthStatusOK,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err == nil {
+		return status
+	}
+
+	// The cause may contain hosts or credentials hints, so it is only logged.
+	logger.FromContext(ctx).WarnContext(ctx, "dependency check failed",
+		slog.String("dependency", check.Name),
+		slog.String("error", err.Error()),
+	)
+	status.Status = healthStatusUnavailable
+	status.Error = "check failed"
+	if errors.Is(err, context.DeadlineExceeded) {
+		status.Error = "timeout"
+	}
+	return status
+}
+
+func writeHealth(w http.ResponseWriter, code int, response healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
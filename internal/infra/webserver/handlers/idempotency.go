@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/audit"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/logger"
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/throttle"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	idempotencyPruneInterval = 10 * time.Minute
+	idempotencyRetryAfter    = "1"
+)
+
+var (
+	errIdempotencyKeyReused = errors.New("Idempotency-Key was already used for a different request")
+	errIdempotencyKeyInUse  = errors.New("a request with this Idempotency-Key is still in progress")
+)
+
+// replayedHeaders are the response headers stored with an idempotency key.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// IdempotencyHandler makes unsafe requests retryable. The first response to
+// a request carrying an Idempotency-Key header is stored per user and key,
+// and retries with the same method, path and body get it back without the
+// request running again. A retry with a different body gets 422 and a retry
+// that arrives while the first request is still running gets 409. Server
+// errors are not stored so the client can retry them.
+type IdempotencyHandler struct {
+	IdempotencyKeyDB database.IdempotencyKeyInterface
+	TTL              time.Duration
+	// Lease is how long a request may hold its key before a retry can take
+	// it over. It should cover the server write timeout.
+	Lease         time.Duration
+	pruneThrottle *throttle.Throttle
+}
+
+func NewIdempotencyHandler(db database.IdempotencyKeyInterface, ttl, lease time.Duration) *IdempotencyHandler {
+	return &IdempotencyHandler{
+		IdempotencyKeyDB: db,
+		TTL:              ttl,
+		Lease:            lease,
+		pruneThrottle:    throttle.New(idempotencyPruneInterval),
+	}
+}
+
+// Middleware must run after the Audit middleware, which provides the user
+// keys are scoped to.
+func (h *IdempotencyHandler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(idempotencyKeyHeader)
+		user := audit.FromContext(r.Context()).Actor
+		if header == "" || user == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxImageBodySize+1))
+		if err != nil {
+			writeProblem(w, r, malformedBody(err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key, err := entity.NewIdempotencyKey(user, header, requestHash(r, body), h.TTL, h.Lease)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		reserved, err := h.IdempotencyKeyDB.Reserve(key, r.Context())
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		if !reserved {
+			h.replay(w, r, key)
+			return
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				h.release(key, r.Context())
+			}
+		}()
+
+		var buf bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&buf)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		stored := make(http.Header)
+		for _, name := range replayedHeaders {
+			if value := w.Header().Get(name); value != "" {
+				stored.Set(name, value)
+			}
+		}
+		storedJSON, err := json.Marshal(stored)
+		if err != nil {
+			return
+		}
+		key.Complete(status, entity.JSON(storedJSON), buf.String())
+		// The response has been written, so a client that went away must not
+		// stop it from being stored.
+		ctx := context.WithoutCancel(r.Context())
+		if err := h.IdempotencyKeyDB.Complete(key, ctx); err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "idempotency key could not be completed", slog.Any("error", err))
+			return
+		}
+		completed = true
+		h.prune(ctx)
+	})
+}
+
+// replay answers a request whose key is already reserved.
+func (h *IdempotencyHandler) replay(w http.ResponseWriter, r *http.Request, key *entity.IdempotencyKey) {
+	stored, err := h.IdempotencyKeyDB.Find(key.User, key.Key, r.Context())
+	if errors.Is(err, database.ErrNotFound) {
+		// The first request failed and released the key in the meantime.
+		err = errIdempotencyKeyInUse
+	}
+	if err != nil {
+		w.Header().Set("Retry-After", idempotencyRetryAfter)
+		writeProblem(w, r, err)
+		return
+	}
+	if stored.RequestHash != key.RequestHash {
+		writeProblem(w, r, errIdempotencyKeyReused)
+		return
+	}
+	if !stored.Completed() {
+		w.Header().Set("Retry-After", idempotencyRetryAfter)
+		writeProblem(w, r, errIdempotencyKeyInUse)
+		return
+	}
+
+	var header http.Header
+	if err := json.Unmarshal([]byte(stored.ResponseHeader), &header); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	for name, values := range header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(idempotentReplayedHeader, "true")
+	w.WriteHeader(stored.ResponseStatus)
+	io.WriteString(w, stored.ResponseBody)
+}
+
+func (h *IdempotencyHandler) release(key *entity.IdempotencyKey, ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	if err := h.IdempotencyKeyDB.Delete(key.User, key.Key, ctx); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "idempotency key could not be released", slog.Any("error", err))
+	}
+}
+
+// prune deletes expired keys at most once every idempotencyPruneInterval.
+func (h *IdempotencyHandler) prune(ctx context.Context) {
+	if !h.pruneThrottle.Allow() {
+		return
+	}
+
+	deleted, err := h.IdempotencyKeyDB.DeleteExpired(ctx)
+	if err != nil {
+		logger.FromContext(ctx).WarnContext(ctx, "expired idempotency keys could not be deleted", slog.Any("error", err))
+		return
+	}
+	logger.FromContext(ctx).DebugContext(ctx, "expired idempotency keys deleted", slog.Int64("deleted", deleted))
+}
+
+// requestHash fingerprints what makes two requests the same operation.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
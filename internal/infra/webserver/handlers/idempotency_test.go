@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/audit"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const idempotencyUser = "878ab991-20b0-41c3-9c78-849744e8312a"
+
+// idempotencyTest wraps a handler that answers with status and counts its
+// calls. It panics instead when status is zero.
+type idempotencyTest struct {
+	handler *IdempotencyHandler
+	keys    *database.IdempotencyKey
+	status  int
+	calls   int
+}
+
+func newIdempotencyTest(t *testing.T) *idempotencyTest {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.AutoMigrate(&entity.IdempotencyKey{})
+	keys := database.NewIdempotencyKey(db)
+	return &idempotencyTest{
+		handler: NewIdempotencyHandler(keys, time.Hour, time.Minute),
+		keys:    keys,
+		status:  http.StatusCreated,
+	}
+}
+
+func (it *idempotencyTest) serve(key, body string) *httptest.ResponseRecorder {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		it.calls++
+		if it.status == 0 {
+			panic("handler failed")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", etag(it.calls))
+		w.WriteHeader(it.status)
+		json.NewEncoder(w).Encode(map[string]int{"call": it.calls})
+	})
+	r := httptest.NewRequest(http.MethodPost, "/measurements", strings.NewReader(body))
+	r = r.WithContext(audit.NewContext(r.Context(), audit.Metadata{Actor: idempotencyUser}))
+	if key != "" {
+		r.Header.Set(idempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	func() {
+		defer func() { recover() }()
+		it.handler.Middleware(next).ServeHTTP(w, r)
+	}()
+	return w
+}
+
+func problemCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	var problem Problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	return problem.Code
+}
+
+func TestIdempotencyWithoutKey(t *testing.T) {
+	it := newIdempotencyTest(t)
+
+	assert.Equal(t, http.StatusCreated, it.serve("", `{"value":1}`).Code)
+	assert.Equal(t, http.StatusCreated, it.serve("", `{"value":1}`).Code)
+	assert.Equal(t, 2, it.calls)
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	it := newIdempotencyTest(t)
+
+	first := it.serve("key-1", `{"value":1}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(idempotentReplayedHeader))
+
+	retry := it.serve("key-1", `{"value":1}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, first.Header().Get("ETag"), retry.Header().Get("ETag"))
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, 1, it.calls)
+
+	// Keys are independent of each other.
+	assert.Equal(t, http.StatusCreated, it.serve("key-2", `{"value":1}`).Code)
+	assert.Equal(t, 2, it.calls)
+}
+
+func TestIdempotencyReplaysClientErrors(t *testing.T) {
+	it := newIdempotencyTest(t)
+	it.status = http.StatusUnprocessableEntity
+
+	assert.Equal(t, http.StatusUnprocessableEntity, it.serve("key-1", `{"value":1}`).Code)
+	retry := it.serve("key-1", `{"value":1}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, 1, it.calls)
+}
+
+func TestIdempotencyRejectsDifferentBody(t *testing.T) {
+	it := newIdempotencyTest(t)
+
+	assert.Equal(t, http.StatusCreated, it.serve("key-1", `{"value":1}`).Code)
+	w := it.serve("key-1", `{"value":2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "idempotency_key_reused", problemCode(t, w))
+	assert.Equal(t, 1, it.calls)
+}
+
+func TestIdempotencyRejectsRequestInFlight(t *testing.T) {
+	it := newIdempotencyTest(t)
+	r := httptest.NewRequest(http.MethodPost, "/measurements", nil)
+	key, err := entity.NewIdempotencyKey(idempotencyUser, "key-1", requestHash(r, []byte(`{"value":1}`)), time.Hour, time.Minute)
+	assert.NoError(t, err)
+	reserved, err := it.keys.Reserve(key, context.Background())
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	w := it.serve("key-1", `{"value":1}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, idempotencyRetryAfter, w.Header().Get("Retry-After"))
+	assert.Equal(t, "idempotency_key_in_use", problemCode(t, w))
+	assert.Equal(t, 0, it.calls)
+}
+
+func TestIdempotencyTakesOverAbandonedKey(t *testing.T) {
+	it := newIdempotencyTest(t)
+	r := httptest.NewRequest(http.MethodPost, "/measurements", nil)
+	key, err := entity.NewIdempotencyKey(idempotencyUser, "key-1", requestHash(r, []byte(`{"value":1}`)), time.Hour, -time.Second)
+	assert.NoError(t, err)
+	_, err = it.keys.Reserve(key, context.Background())
+	assert.NoError(t, err)
+
+	w := it.serve("key-1", `{"value":1}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, 1, it.calls)
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	it := newIdempotencyTest(t)
+	it.status = http.StatusBadGateway
+
+	assert.Equal(t, http.StatusBadGateway, it.serve("key-1", `{"value":1}`).Code)
+	_, err := it.keys.Find(idempotencyUser, "key-1", context.Background())
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	it.status = http.StatusCreated
+	retry := it.serve("key-1", `{"value":1}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Empty(t, retry.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, 2, it.calls)
+}
+
+func TestIdempotencyReleasesKeyOnPanic(t *testing.T) {
+	it := newIdempotencyTest(t)
+	it.status = 0
+
+	it.serve("key-1", `{"value":1}`)
+	_, err := it.keys.Find(idempotencyUser, "key-1", context.Background())
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	it.status = http.StatusCreated
+	assert.Equal(t, http.StatusCreated, it.serve("key-1", `{"value":1}`).Code)
+	assert.Equal(t, 2, it.calls)
+}
+
+func TestIdempotencyRejectsInvalidKey(t *testing.T) {
+	it := newIdempotencyTest(t)
+
+	w := it.serve(strings.Repeat("k", 256), `{"value":1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "invalid_idempotency_key", problemCode(t, w))
+	assert.Equal(t, 0, it.calls)
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/importer"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/logger"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/metrics"
+)
+
+const (
+	defaultImportChunkSize = 500
+	defaultImportTimeout   = 10 * time.Minute
+	importDateLayout       = "2006-01-02"
+)
+
+// ImportReport summarizes a bulk import. Rows that fail are listed in
+// Errors with their 1-based position in the file, header excluded.
+type ImportReport struct {
+	DryRun   bool             `json:"dry_run"`
+	Rows     int              `json:"rows"`
+	Valid    int              `json:"valid"`
+	Imported int              `json:"imported"`
+	Failed   int              `json:"failed"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+type ImportRowError struct {
+	Row    int          `json:"row"`
+	Code   string       `json:"code"`
+	Detail string       `json:"detail"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+func (r *ImportReport) fail(row int, problem Problem) {
+	r.Failed++
+	r.Errors = append(r.Errors, ImportRowError{Row: row, Code: problem.Code, Detail: problem.Detail, Errors: problem.Errors})
+}
+
+// Import measurements	godoc
+// @Summary      		Import measurements
+// @Description  		Import historical readings from CSV (with a user, type, value, date and optional image header) or NDJSON. Valid rows are inserted in chunks, each in its own transaction, and invalid rows are reported without stopping the import.
+// @Tags         		measurements
+// @Accept       		text/csv,application/x-ndjson
+// @Produce      		json
+// @Param        		request				body		string			true	"CSV or NDJSON rows"
+// @Param        		dry_run				query		bool			false	"validate the rows without inserting them"
+// @Success      		200					{object}	ImportReport
+// @Failure      		400         		{object}	Problem
+// @Failure      		413         		{object}	Problem
+// @Failure      		415         		{object}	Problem
+// @Failure      		500         		{object}	Problem
+// @Router       		/measurements/import	[post]
+// @Security 			ApiKeyAuth
+func (h *MeasurementHandler) ImportMeasurements(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			writeProblem(w, r, invalidParameter("dry_run"))
+			return
+		}
+	}
+
+	format, err := importer.Format(r.Header.Get("Content-Type"))
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	// Large imports take longer to upload and insert than the server read
+	// and write timeouts allow. The deadlines stay bounded so a slow client
+	// cannot hold the connection, and the inserts stop with them.
+	timeout := h.Options.ImportTimeout
+	if timeout <= 0 {
+		timeout = defaultImportTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	rc := http.NewResponseController(w)
+	err = rc.SetReadDeadline(deadline)
+	if err == nil {
+		err = rc.SetWriteDeadline(deadline)
+	}
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		writeProblem(w, r, err)
+		return
+	}
+	ctx, cancel := context.WithDeadline(r.Context(), deadline)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	reader, err := importer.NewReader(format, http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		writeProblem(w, r, importError(err))
+		return
+	}
+
+	chunkSize := h.Options.ImportChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultImportChunkSize
+	}
+	report := ImportReport{DryRun: dryRun, Errors: []ImportRowError{}}
+	chunk := make([]*entity.Measurement, 0, chunkSize)
+	rows := make([]int, 0, chunkSize)
+	flush := func() {
+		if !dryRun && len(chunk) > 0 {
+			err := h.MeasurementDB.CreateMany(chunk, r.Context())
+			if err != nil {
+				logger.FromContext(r.Context()).ErrorContext(r.Context(), "import chunk failed",
+					slog.Int("first_row", rows[0]),
+					slog.Int("rows", len(rows)),
+					slog.Any("error", err),
+				)
+				for _, row := range rows {
+					report.fail(row, Problem{Code: "insert_failed", Detail: "the row could not be stored"})
+				}
+			} else {
+				report.Imported += len(chunk)
+				for _, m := range chunk {
+					metrics.MeasurementImported(m.Type)
+				}
+			}
+		}
+		chunk = chunk[:0]
+		rows = rows[:0]
+	}
+
+	for row := 1; ; row++ {
+		input, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *importer.RowError
+		if errors.As(err, &rowErr) {
+			report.Rows++
+			report.fail(row, malformedRow(rowErr))
+			continue
+		}
+		if err != nil {
+			// Chunks inserted so far stay committed; the log tells how far
+			// the import got.
+			logger.FromContext(r.Context()).WarnContext(r.Context(), "import aborted",
+				slog.Int("row", row),
+				slog.Int("imported", report.Imported),
+			)
+			writeProblem(w, r, importError(err))
+			return
+		}
+		report.Rows++
+
+		m, err := h.newImportedMeasurement(input)
+		if err != nil {
+			problem := newProblem(r, err)
+			if problem.Code == "validation_failed" {
+				problem.Detail = "row has invalid fields"
+			}
+			report.fail(row, problem)
+			continue
+		}
+		report.Valid++
+		chunk = append(chunk, m)
+		rows = append(rows, row)
+		if len(chunk) == chunkSize {
+			flush()
+		}
+	}
+	flush()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// errImageNotStored rejects import rows pointing at images outside the
+// storage, which the image endpoints would otherwise fetch from the server.
+var errImageNotStored = &apiError{
+	Status: http.StatusUnprocessableEntity,
+	Code:   "image_not_stored",
+	Detail: "image must be a URL of the measurement storage",
+	Errors: []FieldError{{Field: "image", Code: "image_not_stored", Message: "image must be a URL of the measurement storage"}},
+}
+
+func (h *MeasurementHandler) newImportedMeasurement(input dto.ImportMeasurementRow) (*entity.Measurement, error) {
+	if err := validateStruct(input); err != nil {
+		return nil, err
+	}
+	if input.Image != "" && !h.MeasurementStorage.Stores(input.Image) {
+		return nil, errImageNotStored
+	}
+	takenAt, err := parseImportDate(input.Date)
+	if err != nil {
+		return nil, err
+	}
+	return entity.NewImportedMeasurement(input.Value, input.Image, input.Type, input.User, takenAt)
+}
+
+// parseImportDate accepts an RFC 3339 timestamp or a date, which is taken
+// as midnight UTC.
+func parseImportDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(importDateLayout, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, entity.ErrInvalidDate
+}
+
+func malformedRow(err *importer.RowError) Problem {
+	problem := Problem{Code: "malformed_row", Detail: err.Error()}
+	if err.Field != "" {
+		problem.Errors = []FieldError{{Field: err.Field, Code: "malformed_row", Message: err.Err.Error()}}
+	}
+	return problem
+}
+
+// importError maps errors that stop an import altogether.
+func importError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return decodeError(err, maxImportSize)
+	case errors.Is(err, importer.ErrMissingColumn):
+		return &apiError{Status: http.StatusBadRequest, Code: "missing_column", Detail: err.Error(), Err: err}
+	case errors.Is(err, importer.ErrEmptyImport), errors.Is(err, importer.ErrUnsupportedFormat):
+		return err
+	}
+	return &apiError{Status: http.StatusBadRequest, Code: "malformed_body", Detail: "request body could not be read", Err: err}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/billing"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+	entityPkg "github.com/melkzsiqueira/water-gas-measurement/pkg/entity"
+)
+
+var errInvoiceExists = &apiError{Status: http.StatusConflict, Code: "invoice_exists", Detail: "the user is already billed for this period and type"}
+
+type InvoiceHandler struct {
+	InvoiceDB     database.InvoiceInterface
+	TariffDB      database.TariffInterface
+	MeasurementDB database.MeasurementInterface
+}
+
+func NewInvoiceHandler(invoiceDB database.InvoiceInterface, tariffDB database.TariffInterface, measurementDB database.MeasurementInterface) *InvoiceHandler {
+	return &InvoiceHandler{
+		InvoiceDB:     invoiceDB,
+		TariffDB:      tariffDB,
+		MeasurementDB: measurementDB,
+	}
+}
+
+// Create invoice		godoc
+// @Summary      		Create invoice
+// @Description  		Bill the consumption of a user between the last confirmed reading before the period and the last confirmed reading within it
+// @Tags         		invoices
+// @Accept       		json
+// @Produce      		json
+// @Param        		request		body		dto.CreateInvoiceInput	true	"invoice request"
+// @Success      		201			{object}	entity.Invoice
+// @Failure      		400			{object}	Problem
+// @Failure      		409			{object}	Problem
+// @Failure      		422			{object}	Problem
+// @Failure      		500			{object}	Problem
+// @Router       		/invoices	[post]
+// @Security 			ApiKeyAuth
+func (h *InvoiceHandler) CreateInvoice(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateInvoiceInput
+	err := decodeAndValidate(w, r, &input, maxBodySize)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	period, err := billing.ParsePeriod(input.Period)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	readings := make([]entity.Measurement, 0, 2)
+	for _, before := range []time.Time{period.Start(), period.End()} {
+		reading, err := h.MeasurementDB.FindLastConfirmed(input.User, input.Type, before, r.Context())
+		if errors.Is(err, database.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		readings = append(readings, *reading)
+	}
+	tariffs, err := h.TariffDB.FindAll(input.Type, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	invoice, err := billing.Bill(period, readings, tariffs)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	err = h.InvoiceDB.Create(invoice, r.Context())
+	if errors.Is(err, database.ErrAlreadyExists) {
+		err = errInvoiceExists
+	}
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invoice)
+}
+
+// List invoices		godoc
+// @Summary      		List invoices
+// @Description  		Get invoices filtered by user, type and period
+// @Tags         		invoices
+// @Accept       		json
+// @Produce      		json
+// @Param        		user      		query   	string  			false  "user ID"
+// @Param        		type      		query   	string  			false  "measurement type"	Enums(1, 2)
+// @Param        		from      		query   	string  			false  "first period (YYYY-MM)"
+// @Param        		to        		query   	string  			false  "last period (YYYY-MM)"
+// @Param        		page      		query   	string  			false  "page number"
+// @Param        		limit     		query   	string  			false  "records limit"
+// @Param        		sort      		query   	string  			false  "sort order"	Enums(asc, desc)
+// @Success      		200       		{array} 	entity.Invoice
+// @Failure      		400       		{object}	Problem
+// @Failure      		500       		{object}	Problem
+// @Router       		/invoices 		[get]
+// @Security 			ApiKeyAuth
+func (h *InvoiceHandler) GetInvoices(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page := query.Get("page")
+	if page == "" || page == "0" {
+		page = "1"
+	}
+	pageInt, err := strconv.Atoi(page)
+	if err != nil {
+		writeProblem(w, r, invalidParameter("page"))
+		return
+	}
+
+	limit := query.Get("limit")
+	if limit == "" || limit == "0" {
+		limit = "10"
+	}
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		writeProblem(w, r, invalidParameter("limit"))
+		return
+	}
+
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = "desc"
+	}
+	if sort != "asc" && sort != "desc" {
+		writeProblem(w, r, invalidParameter("sort"))
+		return
+	}
+
+	filter := database.InvoiceFilter{
+		User: query.Get("user"),
+		Type: query.Get("type"),
+	}
+	if filter.User != "" {
+		if _, err := entityPkg.ParseID(filter.User); err != nil {
+			writeProblem(w, r, invalidParameter("user"))
+			return
+		}
+	}
+	if filter.Type != "" && filter.Type != entity.MeasurementTypeWater && filter.Type != entity.MeasurementTypeGas {
+		writeProblem(w, r, invalidParameter("type"))
+		return
+	}
+	if from := query.Get("from"); from != "" {
+		period, err := billing.ParsePeriod(from)
+		if err != nil {
+			writeProblem(w, r, invalidParameter("from"))
+			return
+		}
+		filter.From = period.String()
+	}
+	if to := query.Get("to"); to != "" {
+		period, err := billing.ParsePeriod(to)
+		if err != nil {
+			writeProblem(w, r, invalidParameter("to"))
+			return
+		}
+		filter.To = period.String()
+	}
+
+	invoices, err := h.InvoiceDB.FindAll(filter, pageInt, limitInt, sort, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(invoices)
+}
+
+// Get invoice			godoc
+// @Summary      		Get an invoice
+// @Description  		Get an invoice with its line items
+// @Tags         		invoices
+// @Accept       		json
+// @Produce      		json
+// @Param        		id				path		string	true	"invoice ID"	Format(uuid)
+// @Success      		200				{object}	entity.Invoice
+// @Failure      		400				{object}	Problem
+// @Failure      		404				{object}	Problem
+// @Failure      		500				{object}	Problem
+// @Router       		/invoices/{id}	[get]
+// @Security 			ApiKeyAuth
+func (h *InvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		writeProblem(w, r, invalidParameter("id"))
+		return
+	}
+	invoice, err := h.InvoiceDB.FindById(id, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(invoice)
+}
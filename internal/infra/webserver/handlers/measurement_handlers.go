@@ -1,32 +1,106 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
+	"mime"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/audit"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/detection"
 	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
 	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
 	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
 	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/gemini"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/logger"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/metrics"
 	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/storage"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/worker"
 	entityPkg "github.com/melkzsiqueira/water-gas-measurement/pkg/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/imaging"
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/mergepatch"
+)
+
+var measurementMutableFields = map[string]bool{
+	"value":     true,
+	"type":      true,
+	"confirmed": true,
+}
+
+// renditionSizes is the longest side, in pixels, of each generated
+// rendition.
+var renditionSizes = map[string]int{
+	entity.RenditionThumb:  200,
+	entity.RenditionMedium: 800,
+}
+
+var meterTypes = map[string]string{
+	entity.MeasurementTypeWater: dto.MeterTypeWater,
+	entity.MeasurementTypeGas:   dto.MeterTypeGas,
+}
+
+var (
+	errNotAMeter         = &apiError{Status: http.StatusUnprocessableEntity, Code: "not_a_meter", Detail: "the image does not show a water or gas meter"}
+	errMeterTypeMismatch = &apiError{Status: http.StatusUnprocessableEntity, Code: "meter_type_mismatch", Detail: "the meter in the image does not match the measurement type"}
+	errIllegibleImage    = &apiError{Status: http.StatusUnprocessableEntity, Code: "illegible_image", Detail: "the meter register in the image is not legible"}
 )
 
 type MeasurementHandler struct {
 	MeasurementDB      database.MeasurementInterface
+	AlertDB            database.AlertInterface
+	UnitOfWork         database.UnitOfWorkInterface
 	MeasurementStorage storage.MeasurementStorageInterface
 	Gemini             gemini.GeminiInterface
+	Worker             worker.PoolInterface
+	ImagePipeline      *imaging.Pipeline
+	Options            MeasurementOptions
 }
 
-func NewMeasurementHandler(db database.MeasurementInterface, storage storage.MeasurementStorageInterface, gemini gemini.GeminiInterface) *MeasurementHandler {
+// MeasurementOptions tunes how uploaded readings are checked and stored.
+type MeasurementOptions struct {
+	// MinConfidence is the lowest OCR confidence accepted for a reading.
+	MinConfidence float64
+	// KeepOriginal also stores the raw upload next to the normalized image.
+	KeepOriginal bool
+	// DuplicateDistance is the largest perceptual hash distance at which an
+	// image counts as a near duplicate of one of the user's readings.
+	DuplicateDistance int
+	// DuplicateLookback is how many of the user's latest readings are
+	// compared against a new image.
+	DuplicateLookback int
+	// ImportChunkSize is how many imported rows are inserted per
+	// transaction.
+	ImportChunkSize int
+	// ImportTimeout bounds how long an import may take to upload and
+	// insert, in place of the server read and write timeouts.
+	ImportTimeout time.Duration
+	// ExportTimeout bounds how long an export may take to download, in
+	// place of the server write timeout.
+	ExportTimeout time.Duration
+	// Anomaly holds, by measurement type, the thresholds newly confirmed
+	// readings are scored against. Types without thresholds are not scored.
+	Anomaly map[string]detection.Thresholds
+}
+
+func NewMeasurementHandler(db database.MeasurementInterface, alertDB database.AlertInterface, uow database.UnitOfWorkInterface, storage storage.MeasurementStorageInterface, gemini gemini.GeminiInterface, worker worker.PoolInterface, pipeline *imaging.Pipeline, options MeasurementOptions) *MeasurementHandler {
 	return &MeasurementHandler{
 		MeasurementDB:      db,
+		AlertDB:            alertDB,
+		UnitOfWork:         uow,
 		MeasurementStorage: storage,
 		Gemini:             gemini,
+		Worker:             worker,
+		ImagePipeline:      pipeline,
+		Options:            options,
 	}
 }
 
@@ -37,78 +111,160 @@ func NewMeasurementHandler(db database.MeasurementInterface, storage storage.Mea
 // @Accept       		json
 // @Produce      		json
 // @Param        		request				body		dto.CreateMeasurementInput	true	"measurement request"
+// @Param        		Idempotency-Key		header		string						false	"retries with the same key and body get the first response back"
 // @Success      		201					{object}	entity.Measurement
-// @Failure      		400         		{object}	Error
-// @Failure      		500         		{object}	Error
+// @Failure      		400         		{object}	Problem
+// @Failure      		409         		{object}	Problem
+// @Failure      		413         		{object}	Problem
+// @Failure      		415         		{object}	Problem
+// @Failure      		422         		{object}	Problem
+// @Failure      		500         		{object}	Problem
+// @Failure      		502         		{object}	Problem
+// @Failure      		503         		{object}	Problem
 // @Router       		/measurements		[post]
 // @Security 			ApiKeyAuth
 func (h *MeasurementHandler) CreateMeasurement(w http.ResponseWriter, r *http.Request) {
 	var measurement dto.CreateMeasurementInput
 
-	body, err := io.ReadAll(r.Body)
+	err := decodeAndValidate(w, r, &measurement, maxImageBodySize)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
-	err = json.Unmarshal(body, &measurement)
+
+	original, err := base64.StdEncoding.DecodeString(measurement.Image.Data)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, &apiError{Status: http.StatusUnprocessableEntity, Code: "invalid_image", Detail: "the image is not valid base64", Err: err})
+		return
+	}
+	// Images are only stored once re-encoded, which strips their EXIF and GPS
+	// metadata, so formats the pipeline cannot decode are refused.
+	normalized, err := h.ImagePipeline.Process(original)
+	switch {
+	case errors.Is(err, imaging.ErrUnsupportedFormat):
+		writeProblem(w, r, &apiError{Status: http.StatusUnsupportedMediaType, Code: "unsupported_image_format", Detail: "images must be JPEG, PNG or WebP", Err: err})
+		return
+	case errors.Is(err, imaging.ErrImageTooLarge):
+		writeProblem(w, r, &apiError{Status: http.StatusUnprocessableEntity, Code: "invalid_image", Detail: "the image has too many pixels", Err: err})
+		return
+	case err != nil:
+		writeProblem(w, r, &apiError{Status: http.StatusUnprocessableEntity, Code: "invalid_image", Detail: "the image could not be decoded", Err: err})
 		return
 	}
+	data := base64.StdEncoding.EncodeToString(normalized.Data)
 
 	imgReq := dto.ProcessImageRequest{
-		Data: measurement.Image.Data,
-		Mime: measurement.Image.Mime,
+		Data: data,
+		Mime: normalized.Mime,
 	}
 	imgResp, err := h.Gemini.ProcessImage(imgReq, r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		if !isOCRRejection(err) {
+			err = dependencyFailed("ocr_failed", "the image could not be processed", err)
+		}
+		writeProblem(w, r, err)
+		return
+	}
+	err = h.checkReading(imgResp, measurement.Type)
+	if err != nil {
+		writeProblem(w, r, err)
 		return
 	}
 	measurement.Value, err = strconv.Atoi(imgResp.Value)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, &apiError{Status: http.StatusUnprocessableEntity, Code: "unreadable_image", Detail: "no numeric reading could be extracted from the image", Err: err})
 		return
 	}
 
-	s, err := h.MeasurementStorage.UploadFile("data:"+measurement.Image.Mime+";base64,"+measurement.Image.Data, r.Context())
+	// Exact copies of an image that is already stored reuse its object
+	// instead of being uploaded again.
+	sum := sha256.Sum256(original)
+	imageHash := hex.EncodeToString(sum[:])
+	existing, err := h.MeasurementDB.FindByImageSHA256(imageHash, r.Context())
+	if errors.Is(err, database.ErrNotFound) {
+		existing, err = nil, nil
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
 
+	var uploaded []string
+	cleanup := func() {
+		for _, publicID := range uploaded {
+			h.deleteUploadedFile(publicID, r.Context())
+		}
+	}
+
+	var imageURL string
+	if existing != nil {
+		imageURL = existing.Image
+	} else {
+		s, err := h.MeasurementStorage.UploadFile("data:"+normalized.Mime+";base64,"+data, r.Context())
+		if err != nil {
+			writeProblem(w, r, dependencyFailed("storage_failed", "the image could not be stored", err))
+			return
+		}
+		uploaded = append(uploaded, s.PublicID)
+		imageURL = s.SecureURL
+	}
+
 	m, err := entity.NewMeasurement(
 		measurement.Value,
-		s.SecureURL,
+		imageURL,
 		measurement.Type,
 		measurement.User,
 	)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		cleanup()
+		writeProblem(w, r, err)
 		return
 	}
 
-	err = h.MeasurementDB.Create(m)
+	m.OCRValue = measurement.Value
+	m.PromptVersion = imgResp.PromptVersion
+	m.ImageSHA256 = imageHash
+	m.ImagePHash = strconv.FormatUint(normalized.PHash, 16)
+
+	if existing != nil {
+		m.ReuseImage(existing)
+	} else if h.Options.KeepOriginal {
+		o, err := h.MeasurementStorage.UploadFile("data:"+measurement.Image.Mime+";base64,"+measurement.Image.Data, r.Context())
+		if err != nil {
+			cleanup()
+			writeProblem(w, r, dependencyFailed("storage_failed", "the image could not be stored", err))
+			return
+		}
+		uploaded = append(uploaded, o.PublicID)
+		m.OriginalImage = o.SecureURL
+	}
+
+	err = h.flagDuplicate(m, r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		cleanup()
+		writeProblem(w, r, err)
 		return
 	}
 
+	err = h.UnitOfWork.Do(func(repositories *database.Repositories) error {
+		return repositories.Measurement.Create(m, r.Context())
+	}, r.Context())
+	if err != nil {
+		cleanup()
+		writeProblem(w, r, err)
+		return
+	}
+	metrics.MeasurementCreated(m.Type)
+	if m.SuspectedFraud {
+		metrics.MeasurementFlagged(m.Type)
+		logger.FromContext(r.Context()).WarnContext(r.Context(), "measurement flagged as possible duplicate",
+			slog.String("measurement_id", m.ID.String()),
+			slog.String("duplicate_of", m.DuplicateOf),
+		)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(m.Version))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(m)
 }
@@ -119,11 +275,17 @@ func (h *MeasurementHandler) CreateMeasurement(w http.ResponseWriter, r *http.Re
 // @Tags         		measurements
 // @Accept       		json
 // @Produce      		json
+// @Param        		user      		query   	string  			false  "user ID"
+// @Param        		type      		query   	string  			false  "measurement type"	Enums(1, 2)
+// @Param        		confirmed 		query   	bool    			false  "confirmed readings only (true) or unconfirmed only (false)"
+// @Param        		from      		query   	string  			false  "start date (RFC 3339)"
+// @Param        		to        		query   	string  			false  "end date (RFC 3339)"
 // @Param        		page      		query   	string  			false  "page number"
 // @Param        		limit     		query   	string  			false  "records limit"
+// @Param        		sort      		query   	string  			false  "sort order"	Enums(asc, desc)
 // @Success      		200       		{array} 	entity.Measurement
-// @Failure      		400       		{object}	Error
-// @Failure      		500       		{object}	Error
+// @Failure      		400       		{object}	Problem
+// @Failure      		500       		{object}	Problem
 // @Router       		/measurements 	[get]
 // @Security 			ApiKeyAuth
 func (h *MeasurementHandler) GetMeasurements(w http.ResponseWriter, r *http.Request) {
@@ -133,9 +295,7 @@ func (h *MeasurementHandler) GetMeasurements(w http.ResponseWriter, r *http.Requ
 	}
 	pageInt, err := strconv.Atoi(page)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: "page is invalid"}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, invalidParameter("page"))
 		return
 	}
 
@@ -145,9 +305,7 @@ func (h *MeasurementHandler) GetMeasurements(w http.ResponseWriter, r *http.Requ
 	}
 	limitInt, err := strconv.Atoi(limit)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: "limit is invalid"}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, invalidParameter("limit"))
 		return
 	}
 
@@ -156,17 +314,19 @@ func (h *MeasurementHandler) GetMeasurements(w http.ResponseWriter, r *http.Requ
 		sort = "desc"
 	}
 	if sort != "asc" && sort != "desc" {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: "sort is invalid"}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, invalidParameter("sort"))
+		return
+	}
+
+	filter, err := parseMeasurementFilter(r)
+	if err != nil {
+		writeProblem(w, r, err)
 		return
 	}
 
-	m, err := h.MeasurementDB.FindAll(pageInt, limitInt, sort)
+	m, err := h.MeasurementDB.FindAll(filter, pageInt, limitInt, sort, r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -174,6 +334,45 @@ func (h *MeasurementHandler) GetMeasurements(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(m)
 }
 
+// parseMeasurementFilter reads the filters shared by the list and export
+// endpoints.
+func parseMeasurementFilter(r *http.Request) (database.MeasurementFilter, error) {
+	query := r.URL.Query()
+	filter := database.MeasurementFilter{
+		User: query.Get("user"),
+		Type: query.Get("type"),
+	}
+	if filter.User != "" {
+		if _, err := entityPkg.ParseID(filter.User); err != nil {
+			return filter, invalidParameter("user")
+		}
+	}
+	if filter.Type != "" && filter.Type != entity.MeasurementTypeWater && filter.Type != entity.MeasurementTypeGas {
+		return filter, invalidParameter("type")
+	}
+	if confirmed := query.Get("confirmed"); confirmed != "" {
+		value, err := strconv.ParseBool(confirmed)
+		if err != nil {
+			return filter, invalidParameter("confirmed")
+		}
+		filter.Confirmed = &value
+	}
+	var err error
+	if from := query.Get("from"); from != "" {
+		filter.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, invalidParameter("from")
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		filter.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, invalidParameter("to")
+		}
+	}
+	return filter, nil
+}
+
 // Get measurement	godoc
 // @Summary      	Get a measurement
 // @Description  	Get a measurement
@@ -182,89 +381,182 @@ func (h *MeasurementHandler) GetMeasurements(w http.ResponseWriter, r *http.Requ
 // @Produce      	json
 // @Param        	id   				path		string				true	"measurement ID"	Format(uuid)
 // @Success      	200  				{object}	entity.Measurement
-// @Failure      	400  				{object}  	Error
-// @Failure      	404  				{object}  	Error
+// @Header       	200  				{string}	ETag	"measurement version"
+// @Failure      	400  				{object}  	Problem
+// @Failure      	404  				{object}  	Problem
+// @Failure      	500  				{object}  	Problem
 // @Router       	/measurements/{id}	[get]
 // @Security 		ApiKeyAuth
 func (h *MeasurementHandler) GetMeasurement(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: "id is required"}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, invalidParameter("id"))
 		return
 	}
-	m, err := h.MeasurementDB.FindById(id)
+	m, err := h.MeasurementDB.FindById(id, r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(m.Version))
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(m)
 }
 
 // Update measurement	godoc
 // @Summary     		Update a measurement
-// @Description 		Update a measurement
+// @Description 		Replace the mutable fields of a measurement
 // @Tags        		measurements
 // @Accept      		json
 // @Produce     		json
-// @Param       		id        			path		string                  	true	"product ID"			Format(uuid)
-// @Param       		request     		body      	dto.CreateMeasurementInput	true	"measurement request"
+// @Param       		id        			path		string                  	true	"measurement ID"		Format(uuid)
+// @Param       		If-Match   			header		string                  	true	"measurement ETag"
+// @Param       		request     		body      	dto.UpdateMeasurementInput	true	"measurement request"
 // @Success      		200  				{object}	entity.Measurement
-// @Failure     		400	   				{object}	Error
-// @Failure     		404	   				{object}	Error
-// @Failure     		500       			{object}	Error
+// @Header       		200  				{string}	ETag	"measurement version"
+// @Failure     		400	   				{object}	Problem
+// @Failure     		404	   				{object}	Problem
+// @Failure     		412	   				{object}	Problem
+// @Failure     		422	   				{object}	Problem
+// @Failure     		428	   				{object}	Problem
+// @Failure     		500       			{object}	Problem
 // @Router      		/measurements/{id} 	[put]
 // @Security 			ApiKeyAuth
 func (h *MeasurementHandler) UpdateMeasurement(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: "id is required"}
-		json.NewEncoder(w).Encode(error)
+	var input dto.UpdateMeasurementInput
+	err := decodeAndValidate(w, r, &input, maxBodySize)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	h.updateMeasurement(w, r, func(m *entity.Measurement) error {
+		m.Value = input.Value
+		m.Type = input.Type
+		m.Confirmed = input.Confirmed
+		return nil
+	})
+}
+
+// Patch measurement	godoc
+// @Summary     		Patch a measurement
+// @Description 		Partially update a measurement with a JSON Merge Patch (RFC 7386). Only value, type and confirmed are mutable.
+// @Tags        		measurements
+// @Accept      		application/merge-patch+json
+// @Produce     		json
+// @Param       		id        			path		string                  	true	"measurement ID"		Format(uuid)
+// @Param       		If-Match   			header		string                  	true	"measurement ETag"
+// @Param       		request     		body      	dto.PatchMeasurementInput	true	"measurement merge patch"
+// @Success      		200  				{object}	entity.Measurement
+// @Header       		200  				{string}	ETag	"measurement version"
+// @Failure     		400	   				{object}	Problem
+// @Failure     		404	   				{object}	Problem
+// @Failure     		412	   				{object}	Problem
+// @Failure     		415	   				{object}	Problem
+// @Failure     		422	   				{object}	Problem
+// @Failure     		428	   				{object}	Problem
+// @Failure     		500       			{object}	Problem
+// @Router      		/measurements/{id} 	[patch]
+// @Security 			ApiKeyAuth
+func (h *MeasurementHandler) PatchMeasurement(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/merge-patch+json" {
+		writeProblem(w, r, &apiError{Status: http.StatusUnsupportedMediaType, Code: "unsupported_media_type", Detail: "content type must be application/merge-patch+json"})
 		return
 	}
-	var m entity.Measurement
-	body, err := io.ReadAll(r.Body)
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, decodeError(err, maxBodySize))
 		return
 	}
-	err = json.Unmarshal(body, &m)
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(patch, &fields)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, badRequest("malformed_body", "patch must be a JSON object"))
+		return
+	}
+	for field := range fields {
+		if !measurementMutableFields[field] {
+			writeProblem(w, r, &apiError{
+				Status: http.StatusUnprocessableEntity,
+				Code:   "field_not_mutable",
+				Detail: "field " + field + " is not mutable",
+				Errors: []FieldError{{Field: field, Code: "field_not_mutable", Message: "field " + field + " is not mutable"}},
+			})
+			return
+		}
+	}
+	h.updateMeasurement(w, r, func(m *entity.Measurement) error {
+		original, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		patched, err := mergepatch.Apply(original, patch)
+		if err != nil {
+			return malformedBody(err)
+		}
+		var result entity.Measurement
+		err = json.Unmarshal(patched, &result)
+		if err != nil {
+			return &apiError{Status: http.StatusUnprocessableEntity, Code: "invalid_patch", Detail: "patch does not match the measurement schema", Err: err}
+		}
+		m.Value = result.Value
+		m.Type = result.Type
+		m.Confirmed = result.Confirmed
+		return nil
+	})
+}
+
+// updateMeasurement loads the measurement referenced by the request, checks
+// it against If-Match, lets apply change its mutable fields and saves it
+// after domain validation, all inside one unit of work.
+func (h *MeasurementHandler) updateMeasurement(w http.ResponseWriter, r *http.Request, apply func(m *entity.Measurement) error) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		writeProblem(w, r, invalidParameter("id"))
 		return
 	}
-	m.ID, err = entityPkg.ParseID(id)
+	_, err := entityPkg.ParseID(id)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: "id is invalid"}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, invalidParameter("id"))
 		return
 	}
-	_, err = h.MeasurementDB.FindById(m.ID.String())
+	versions, err := parseIfMatch(r.Header.Get("If-Match"))
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
-	err = h.MeasurementDB.Update(&m)
+	var m *entity.Measurement
+	var wasConfirmed bool
+	err = h.UnitOfWork.Do(func(repositories *database.Repositories) error {
+		m, err = repositories.Measurement.FindById(id, r.Context())
+		if err != nil {
+			return err
+		}
+		if !matchesIfMatch(versions, m.Version) {
+			return database.ErrVersionConflict
+		}
+		wasConfirmed = m.Confirmed
+		err = apply(m)
+		if err != nil {
+			return err
+		}
+		err = m.Validate()
+		if err != nil {
+			return err
+		}
+		return repositories.Measurement.Update(m, r.Context())
+	}, r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
+	if !wasConfirmed && m.Confirmed {
+		metrics.MeasurementConfirmed(m.Type, m.OCRValue != 0 && m.OCRValue != m.Value)
+		h.detectAnomaly(*m, r.Context())
+	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(m.Version))
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(m)
 }
@@ -277,31 +569,25 @@ func (h *MeasurementHandler) UpdateMeasurement(w http.ResponseWriter, r *http.Re
 // @Produce      		json
 // @Param        		id        				path      	string				true	"measurement ID"	Format(uuid)
 // @Success      		200						{object}	entity.Measurement
-// @Failure      		400						{object}	Error
-// @Failure      		404						{object}	Error
-// @Failure      		500       				{object}	Error
+// @Failure      		400						{object}	Problem
+// @Failure      		404						{object}	Problem
+// @Failure      		500       				{object}	Problem
 // @Router       		/measurements/{id}		[delete]
 // @Security 			ApiKeyAuth
 func (h *MeasurementHandler) DeleteMeasurement(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: "id is required"}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, invalidParameter("id"))
 		return
 	}
-	_, err := h.MeasurementDB.FindById(id)
+	_, err := h.MeasurementDB.FindById(id, r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
-	err = h.MeasurementDB.Delete(id)
+	err = h.MeasurementDB.Delete(id, r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -309,41 +595,251 @@ func (h *MeasurementHandler) DeleteMeasurement(w http.ResponseWriter, r *http.Re
 
 // Get measurement image	godoc
 // @Summary      			Get a measurement image
-// @Description  			Get a measurement image
+// @Description  			Get a measurement image rendition. Thumbnails and medium renditions are generated on first request
 // @Tags         			measurements
 // @Accept       			json
-// @Produce      			json
+// @Produce      			jpeg
 // @Param        			id   						path		string		true	"measurement ID"	Format(uuid)
+// @Param        			size   						query		string		false	"rendition"	Enums(thumb, medium, original)	default(original)
 // @Success      			200  						{file}  	image
-// @Failure      			400  						{object}  	Error
-// @Failure      			404  						{object}  	Error
+// @Failure      			400  						{object}  	Problem
+// @Failure      			404  						{object}  	Problem
+// @Failure      			422  						{object}  	Problem
+// @Failure      			502  						{object}  	Problem
 // @Router       			/measurements/{id}/image	[get]
 // @Security 				ApiKeyAuth
 func (h *MeasurementHandler) GetMeasurementImage(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: "id is required"}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, invalidParameter("id"))
 		return
 	}
-	m, err := h.MeasurementDB.FindById(id)
+	size := r.URL.Query().Get("size")
+	if size == "" {
+		size = entity.RenditionOriginal
+	}
+	m, err := h.MeasurementDB.FindById(id, r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
+		return
+	}
+	if m.Image == "" {
+		// Imported readings may come without a photo.
+		writeProblem(w, r, &apiError{Status: http.StatusNotFound, Code: "image_not_found", Detail: "measurement has no image"})
+		return
+	}
+	url, err := m.Rendition(size)
+	if err != nil {
+		writeProblem(w, r, invalidParameter("size"))
 		return
 	}
 
-	image, err := base64.StdEncoding.DecodeString(m.Image)
+	var file *storage.File
+	if url != "" {
+		file, err = h.MeasurementStorage.GetFile(url, r.Context())
+		if err != nil {
+			err = errImageUnavailable(err)
+		}
+	} else {
+		file, err = h.generateRendition(m, size, r.Context())
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/jpeg")
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = http.DetectContentType(file.Data)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
 	w.WriteHeader(http.StatusOK)
-	w.Write(image)
+	w.Write(file.Data)
+}
+
+func errImageUnavailable(err error) error {
+	return dependencyFailed("storage_failed", "the image could not be loaded", err)
+}
+
+// generateRendition downscales the stored image, stores the result next to
+// it and records its URL. Concurrent first requests may each generate the
+// rendition; the last one stored wins, which is harmless.
+func (h *MeasurementHandler) generateRendition(m *entity.Measurement, size string, ctx context.Context) (*storage.File, error) {
+	source, err := h.MeasurementStorage.GetFile(m.Image, ctx)
+	if err != nil {
+		return nil, errImageUnavailable(err)
+	}
+	pipeline, err := imaging.NewPipeline(imaging.Options{MaxDimension: renditionSizes[size], MaxPixels: h.ImagePipeline.MaxPixels()})
+	if err != nil {
+		return nil, err
+	}
+	rendition, err := pipeline.Process(source.Data)
+	if errors.Is(err, imaging.ErrUnsupportedFormat) {
+		return nil, &apiError{Status: http.StatusUnprocessableEntity, Code: "rendition_unavailable", Detail: "renditions cannot be generated for this image format", Err: err}
+	}
+	if errors.Is(err, imaging.ErrImageTooLarge) {
+		return nil, &apiError{Status: http.StatusUnprocessableEntity, Code: "rendition_unavailable", Detail: "renditions cannot be generated for an image this large", Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := h.MeasurementStorage.UploadFile("data:"+rendition.Mime+";base64,"+base64.StdEncoding.EncodeToString(rendition.Data), ctx)
+	if err != nil {
+		return nil, dependencyFailed("storage_failed", "the image could not be stored", err)
+	}
+	err = m.SetRendition(size, s.SecureURL)
+	if err == nil {
+		err = h.MeasurementDB.UpdateRenditions(m, ctx)
+	}
+	if err != nil {
+		h.deleteUploadedFile(s.PublicID, ctx)
+		return nil, err
+	}
+	return &storage.File{Data: rendition.Data, ContentType: rendition.Mime}, nil
+}
+
+// checkReading rejects images the OCR step classified as something other
+// than a legible meter of the requested type, so invented numbers are never
+// saved as readings.
+func (h *MeasurementHandler) checkReading(reading dto.ProcessImageResponse, measurementType string) error {
+	var err *apiError
+	switch {
+	case !reading.IsMeter:
+		err = errNotAMeter
+	case reading.MeterType != "" && reading.MeterType != dto.MeterTypeUnknown && reading.MeterType != meterTypes[measurementType]:
+		err = errMeterTypeMismatch
+	case !reading.Legible || reading.Confidence < h.Options.MinConfidence:
+		err = errIllegibleImage
+	default:
+		return nil
+	}
+	metrics.OCRRejected(measurementType, err.Code)
+	return err
+}
+
+// flagDuplicate compares m with the user's latest readings and flags it when
+// its image is the same photo as one of them, or close enough that it was
+// probably re-submitted after a crop or re-encode.
+func (h *MeasurementHandler) flagDuplicate(m *entity.Measurement, ctx context.Context) error {
+	recent, err := h.MeasurementDB.FindRecentByUser(m.User, h.Options.DuplicateLookback, ctx)
+	if err != nil {
+		return err
+	}
+	hash, hashErr := strconv.ParseUint(m.ImagePHash, 16, 64)
+	var match *entity.Measurement
+	best := h.Options.DuplicateDistance + 1
+	for i := range recent {
+		distance := best
+		switch {
+		case recent[i].ImageSHA256 == m.ImageSHA256:
+			distance = 0
+		case hashErr == nil && recent[i].ImagePHash != "":
+			other, err := strconv.ParseUint(recent[i].ImagePHash, 16, 64)
+			if err == nil {
+				distance = imaging.Distance(hash, other)
+			}
+		}
+		if distance < best {
+			best = distance
+			match = &recent[i]
+		}
+	}
+	if match != nil {
+		m.FlagDuplicateOf(match.ID)
+	}
+	return nil
+}
+
+// detectAnomaly scores a newly confirmed reading against the history of its
+// meter in the background, so confirming never waits for it or fails
+// because of it. The alert is audited on behalf of the confirming request.
+func (h *MeasurementHandler) detectAnomaly(m entity.Measurement, ctx context.Context) {
+	thresholds, ok := h.Options.Anomaly[m.Type]
+	if !ok {
+		return
+	}
+	log := logger.FromContext(ctx)
+	metadata := audit.FromContext(ctx)
+	task := func(ctx context.Context) {
+		ctx = audit.NewContext(ctx, metadata)
+		alert, err := h.scoreReading(&m, thresholds, ctx)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to score reading",
+				slog.String("measurement_id", m.ID.String()),
+				slog.String("error", err.Error()),
+			)
+			return
+		}
+		if alert != nil {
+			log.WarnContext(ctx, "consumption anomaly",
+				slog.String("measurement_id", m.ID.String()),
+				slog.String("alert_id", alert.ID.String()),
+				slog.String("kind", alert.Kind),
+				slog.String("severity", alert.Severity),
+				slog.Float64("score", alert.Score),
+			)
+		}
+	}
+	if err := h.Worker.Submit(task); err != nil {
+		task(context.WithoutCancel(ctx))
+	}
+}
+
+// scoreReading stores the alert m raises, if any. A reading confirmed a
+// second time keeps the alert it raised the first time.
+func (h *MeasurementHandler) scoreReading(m *entity.Measurement, thresholds detection.Thresholds, ctx context.Context) (*entity.Alert, error) {
+	confirmed := true
+	filter := database.MeasurementFilter{
+		User:      m.User,
+		Type:      m.Type,
+		Confirmed: &confirmed,
+		From:      m.CreatedAt.AddDate(-detection.HistoryYears, 0, 0),
+		To:        m.CreatedAt,
+	}
+	history, err := h.MeasurementDB.FindAll(filter, 1, detection.HistoryLimit, "desc", ctx)
+	if err != nil {
+		return nil, err
+	}
+	alert, err := detection.Detect(m, history, thresholds)
+	if err != nil || alert == nil {
+		return nil, err
+	}
+	err = h.AlertDB.Create(alert, ctx)
+	if errors.Is(err, database.ErrAlreadyExists) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	metrics.AlertRaised(alert.Type, alert.Kind, alert.Severity)
+	return alert, nil
+}
+
+// isOCRRejection reports whether err already has its own problem mapping
+// instead of being a generic provider failure.
+func isOCRRejection(err error) bool {
+	return errors.Is(err, gemini.ErrProviderUnavailable) ||
+		errors.Is(err, gemini.ErrResponseBlocked) ||
+		errors.Is(err, gemini.ErrEmptyResponse)
+}
+
+// deleteUploadedFile removes an orphaned upload in the background so the
+// client gets its error without waiting for the storage round trip. The
+// request may already be cancelled by then, so only its values are kept.
+func (h *MeasurementHandler) deleteUploadedFile(publicID string, ctx context.Context) {
+	log := logger.FromContext(ctx)
+	task := func(ctx context.Context) {
+		err := h.MeasurementStorage.DeleteFile(publicID, ctx)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to delete orphaned upload",
+				slog.String("public_id", publicID),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+	if err := h.Worker.Submit(task); err != nil {
+		task(context.WithoutCancel(ctx))
+	}
 }
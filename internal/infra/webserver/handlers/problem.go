@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/billing"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/importer"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/gemini"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/logger"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details response. Code is a stable,
+// machine readable identifier clients can rely on instead of Detail.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiError carries the HTTP representation of an error raised by a handler.
+// Err is the underlying cause and is never exposed to clients.
+type apiError struct {
+	Status int
+	Code   string
+	Detail string
+	Errors []FieldError
+	Err    error
+}
+
+func (e *apiError) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *apiError) Unwrap() error {
+	return e.Err
+}
+
+type domainError struct {
+	Err    error
+	Status int
+	Code   string
+	Field  string
+}
+
+var domainErrors = []domainError{
+	{entity.ErrValueIsRequired, http.StatusUnprocessableEntity, "value_is_required", "value"},
+	{entity.ErrInvalidValue, http.StatusUnprocessableEntity, "invalid_value", "value"},
+	{entity.ErrImageIsRequired, http.StatusUnprocessableEntity, "image_is_required", "image"},
+	{entity.ErrTypeIsRequired, http.StatusUnprocessableEntity, "type_is_required", "type"},
+	{entity.ErrInvalidType, http.StatusUnprocessableEntity, "invalid_type", "type"},
+	{entity.ErrUserIsRequired, http.StatusUnprocessableEntity, "user_is_required", "user"},
+	{entity.ErrInvalidUser, http.StatusUnprocessableEntity, "invalid_user", "user"},
+	{entity.ErrNameIsRequired, http.StatusUnprocessableEntity, "name_is_required", "name"},
+	{entity.ErrEmailIsRequired, http.StatusUnprocessableEntity, "email_is_required", "email"},
+	{entity.ErrInvalidEmail, http.StatusUnprocessableEntity, "invalid_email", "email"},
+	{entity.ErrInvalidDate, http.StatusUnprocessableEntity, "invalid_date", "date"},
+	{entity.ErrInvalidCurrency, http.StatusUnprocessableEntity, "invalid_currency", "currency"},
+	{entity.ErrInvalidFixedFee, http.StatusUnprocessableEntity, "invalid_fixed_fee", "fixed_fee"},
+	{entity.ErrInvalidTaxRate, http.StatusUnprocessableEntity, "invalid_tax_rate", "tax_rate"},
+	{entity.ErrTiersAreRequired, http.StatusUnprocessableEntity, "tiers_are_required", "tiers"},
+	{entity.ErrInvalidTier, http.StatusUnprocessableEntity, "invalid_tier", "tiers"},
+	{entity.ErrEffectiveIsRequired, http.StatusUnprocessableEntity, "effective_from_is_required", "effective_from"},
+	{entity.ErrInvalidEffectiveTo, http.StatusUnprocessableEntity, "invalid_effective_to", "effective_to"},
+	{entity.ErrPasswordIsRequired, http.StatusUnprocessableEntity, "password_is_required", "password"},
+	{entity.ErrInvalidPassword, http.StatusUnprocessableEntity, "invalid_password", "password"},
+	{database.ErrNotFound, http.StatusNotFound, "not_found", ""},
+	{database.ErrVersionConflict, http.StatusPreconditionFailed, "version_conflict", ""},
+	{database.ErrAlreadyExists, http.StatusConflict, "already_exists", ""},
+	{errIfMatchIsRequired, http.StatusPreconditionRequired, "if_match_required", ""},
+	{errIfMatchIsInvalid, http.StatusBadRequest, "invalid_if_match", ""},
+	{importer.ErrUnsupportedFormat, http.StatusUnsupportedMediaType, "unsupported_media_type", ""},
+	{importer.ErrEmptyImport, http.StatusBadRequest, "empty_import", ""},
+	{billing.ErrInvalidPeriod, http.StatusUnprocessableEntity, "invalid_period", "period"},
+	{billing.ErrNoPreviousReading, http.StatusUnprocessableEntity, "no_previous_reading", ""},
+	{billing.ErrNoCurrentReading, http.StatusUnprocessableEntity, "no_current_reading", ""},
+	{billing.ErrNegativeConsumption, http.StatusUnprocessableEntity, "negative_consumption", ""},
+	{billing.ErrNoTariff, http.StatusUnprocessableEntity, "no_tariff", ""},
+	{entity.ErrInvalidKey, http.StatusBadRequest, "invalid_idempotency_key", ""},
+	{errIdempotencyKeyReused, http.StatusUnprocessableEntity, "idempotency_key_reused", ""},
+	{errIdempotencyKeyInUse, http.StatusConflict, "idempotency_key_in_use", ""},
+	{gemini.ErrProviderUnavailable, http.StatusServiceUnavailable, "ocr_unavailable", ""},
+	{gemini.ErrResponseBlocked, http.StatusUnprocessableEntity, "image_blocked", "image"},
+	{gemini.ErrEmptyResponse, http.StatusUnprocessableEntity, "unreadable_image", "image"},
+}
+
+func badRequest(code, detail string) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: code, Detail: detail}
+}
+
+func invalidParameter(name string) *apiError {
+	return &apiError{
+		Status: http.StatusBadRequest,
+		Code:   "invalid_parameter",
+		Detail: name + " is invalid",
+		Errors: []FieldError{{Field: name, Code: "invalid_parameter", Message: name + " is invalid"}},
+	}
+}
+
+func malformedBody(err error) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: "malformed_body", Detail: "request body is not valid JSON", Err: err}
+}
+
+func dependencyFailed(code, detail string, err error) *apiError {
+	return &apiError{Status: http.StatusBadGateway, Code: code, Detail: detail, Err: err}
+}
+
+// newProblem maps err to a problem. Handler errors keep their own status,
+// domain and repository sentinels use the domainErrors table and anything
+// else becomes an opaque 500 so internal messages never reach clients.
+func newProblem(r *http.Request, err error) Problem {
+	problem := Problem{
+		Type:      "about:blank",
+		Status:    http.StatusInternalServerError,
+		Code:      "internal_error",
+		Detail:    "an unexpected error occurred",
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		problem.Status = apiErr.Status
+		problem.Code = apiErr.Code
+		problem.Detail = apiErr.Detail
+		problem.Errors = apiErr.Errors
+	} else {
+		for _, mapped := range domainErrors {
+			if !errors.Is(err, mapped.Err) {
+				continue
+			}
+			problem.Status = mapped.Status
+			problem.Code = mapped.Code
+			problem.Detail = mapped.Err.Error()
+			if mapped.Field != "" {
+				problem.Errors = []FieldError{{Field: mapped.Field, Code: mapped.Code, Message: mapped.Err.Error()}}
+			}
+			break
+		}
+	}
+	problem.Title = http.StatusText(problem.Status)
+	return problem
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := newProblem(r, err)
+	logProblem(r, problem, err)
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// logProblem keeps the cause the client never sees. Server and dependency
+// failures are errors, client mistakes are only useful when debugging.
+func logProblem(r *http.Request, problem Problem, err error) {
+	level := slog.LevelDebug
+	if problem.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	logger.FromContext(r.Context()).LogAttrs(r.Context(), level, "request failed",
+		slog.Int("status", problem.Status),
+		slog.String("code", problem.Code),
+		slog.String("error", err.Error()),
+	)
+}
+
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, &apiError{Status: http.StatusNotFound, Code: "route_not_found", Detail: "route not found"})
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, &apiError{Status: http.StatusMethodNotAllowed, Code: "method_not_allowed", Detail: "method not allowed"})
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/billing"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/logger"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/storage"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/statement"
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/imaging"
+)
+
+// statementPhotoSize is the longest side, in pixels, of the photo printed
+// on statements: sharp enough for print at the size it is drawn.
+const statementPhotoSize = 400
+
+var (
+	errUnauthenticated   = &apiError{Status: http.StatusUnauthorized, Code: "unauthorized", Detail: "the token does not identify a user"}
+	errStatementNotFound = &apiError{Status: http.StatusNotFound, Code: "statement_not_found", Detail: "there is no invoice for this period"}
+)
+
+type StatementHandler struct {
+	UserDB             database.UserInterface
+	InvoiceDB          database.InvoiceInterface
+	MeasurementDB      database.MeasurementInterface
+	MeasurementStorage storage.MeasurementStorageInterface
+}
+
+func NewStatementHandler(userDB database.UserInterface, invoiceDB database.InvoiceInterface, measurementDB database.MeasurementInterface, storage storage.MeasurementStorageInterface) *StatementHandler {
+	return &StatementHandler{
+		UserDB:             userDB,
+		InvoiceDB:          invoiceDB,
+		MeasurementDB:      measurementDB,
+		MeasurementStorage: storage,
+	}
+}
+
+// Get statement		godoc
+// @Summary      		Get statement
+// @Description  		Render the PDF statement of the authenticated user for a period, with a page per invoiced measurement type
+// @Tags         		users
+// @Produce      		application/pdf
+// @Param        		period      						path		string	true	"billing period (YYYY-MM)"
+// @Success      		200         						{file}		file
+// @Failure      		400         						{object}	Problem
+// @Failure      		401         						{object}	Problem
+// @Failure      		404         						{object}	Problem
+// @Failure      		500         						{object}	Problem
+// @Router       		/users/me/statements/{period}.pdf	[get]
+// @Security 			ApiKeyAuth
+func (h *StatementHandler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	sub, _ := claims["sub"].(string)
+	if err != nil || sub == "" {
+		writeProblem(w, r, errUnauthenticated)
+		return
+	}
+	period, err := billing.ParsePeriod(chi.URLParam(r, "period"))
+	if err != nil {
+		writeProblem(w, r, invalidParameter("period"))
+		return
+	}
+	user, err := h.UserDB.FindById(sub, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	doc := statement.Statement{
+		Name:        user.Name,
+		Email:       user.Email,
+		Period:      period,
+		GeneratedAt: time.Now(),
+	}
+	for _, measurementType := range []string{entity.MeasurementTypeWater, entity.MeasurementTypeGas} {
+		section, found, err := h.section(sub, measurementType, period, r.Context())
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		if found {
+			doc.Sections = append(doc.Sections, section)
+		}
+	}
+	if len(doc.Sections) == 0 {
+		writeProblem(w, r, errStatementNotFound)
+		return
+	}
+
+	// The document is small, so it is rendered up front and a failure can
+	// still be reported as a problem.
+	var buf bytes.Buffer
+	err = statement.Render(doc, &buf)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="statement-`+period.String()+`.pdf"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+// section gathers the invoice of period, the readings it was computed from
+// and the invoices of the months before it for the consumption chart.
+func (h *StatementHandler) section(user, measurementType string, period billing.Period, ctx context.Context) (statement.Section, bool, error) {
+	filter := database.InvoiceFilter{
+		User: user,
+		Type: measurementType,
+		From: period.AddMonths(1 - statement.HistoryMonths).String(),
+		To:   period.String(),
+	}
+	invoices, err := h.InvoiceDB.FindAll(filter, 1, statement.HistoryMonths, "asc", ctx)
+	if err != nil || len(invoices) == 0 || invoices[len(invoices)-1].Period != period.String() {
+		return statement.Section{}, false, err
+	}
+	section := statement.Section{
+		Invoice: invoices[len(invoices)-1],
+		History: statement.History(period, invoices),
+	}
+	previous, err := h.reading(section.Invoice.PreviousReading.String(), ctx)
+	if err != nil {
+		return section, false, err
+	}
+	current, err := h.reading(section.Invoice.CurrentReading.String(), ctx)
+	if err != nil {
+		return section, false, err
+	}
+	section.Previous, section.Current = *previous, *current
+	section.Photo = h.photo(current, ctx)
+	return section, true, nil
+}
+
+// reading returns an empty measurement when the reading was deleted after
+// it was billed, since the invoice still holds its value.
+func (h *StatementHandler) reading(id string, ctx context.Context) (*entity.Measurement, error) {
+	m, err := h.MeasurementDB.FindById(id, ctx)
+	if errors.Is(err, database.ErrNotFound) {
+		return &entity.Measurement{}, nil
+	}
+	return m, err
+}
+
+// photo returns the JPEG printed on the statement. A missing or broken photo
+// only leaves it out of the statement.
+func (h *StatementHandler) photo(m *entity.Measurement, ctx context.Context) []byte {
+	url := m.MediumImage
+	if url == "" {
+		url = m.Image
+	}
+	if url == "" {
+		return nil
+	}
+	data, err := h.loadPhoto(url, ctx)
+	if err != nil {
+		logger.FromContext(ctx).WarnContext(ctx, "statement photo unavailable",
+			slog.String("measurement_id", m.ID.String()),
+			slog.Any("error", err),
+		)
+		return nil
+	}
+	return data
+}
+
+func (h *StatementHandler) loadPhoto(url string, ctx context.Context) ([]byte, error) {
+	file, err := h.MeasurementStorage.GetFile(url, ctx)
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := imaging.NewPipeline(imaging.Options{MaxDimension: statementPhotoSize, Format: imaging.FormatJPEG})
+	if err != nil {
+		return nil, err
+	}
+	result, err := pipeline.Process(file.Data)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
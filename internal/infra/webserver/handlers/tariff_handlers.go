@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/dto"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/database"
+)
+
+type TariffHandler struct {
+	TariffDB database.TariffInterface
+}
+
+func NewTariffHandler(db database.TariffInterface) *TariffHandler {
+	return &TariffHandler{
+		TariffDB: db,
+	}
+}
+
+// Create tariff		godoc
+// @Summary      		Create tariff
+// @Description  		Create a tariff. Amounts are in cents, the tax rate is in basis points and the last tier has no upper bound
+// @Tags         		tariffs
+// @Accept       		json
+// @Produce      		json
+// @Param        		request		body		dto.CreateTariffInput	true	"tariff request"
+// @Success      		201			{object}	entity.Tariff
+// @Failure      		400			{object}	Problem
+// @Failure      		422			{object}	Problem
+// @Failure      		500			{object}	Problem
+// @Router       		/tariffs	[post]
+// @Security 			ApiKeyAuth
+func (h *TariffHandler) CreateTariff(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateTariffInput
+	err := decodeAndValidate(w, r, &input, maxBodySize)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	tiers := make([]entity.TariffTier, 0, len(input.Tiers))
+	for _, tier := range input.Tiers {
+		tiers = append(tiers, entity.TariffTier{UpTo: tier.UpTo, Price: tier.Price})
+	}
+	tariff, err := entity.NewTariff(input.Name, input.Type, input.Currency, input.FixedFee, input.TaxRate, tiers, input.EffectiveFrom, input.EffectiveTo)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	err = h.TariffDB.Create(tariff, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tariff)
+}
+
+// List tariffs			godoc
+// @Summary      		List tariffs
+// @Description  		Get every tariff, most recent first
+// @Tags         		tariffs
+// @Accept       		json
+// @Produce      		json
+// @Param        		type		query		string	false	"measurement type"	Enums(1, 2)
+// @Success      		200			{array}		entity.Tariff
+// @Failure      		400			{object}	Problem
+// @Failure      		500			{object}	Problem
+// @Router       		/tariffs	[get]
+// @Security 			ApiKeyAuth
+func (h *TariffHandler) GetTariffs(w http.ResponseWriter, r *http.Request) {
+	measurementType := r.URL.Query().Get("type")
+	if measurementType != "" && measurementType != entity.MeasurementTypeWater && measurementType != entity.MeasurementTypeGas {
+		writeProblem(w, r, invalidParameter("type"))
+		return
+	}
+	tariffs, err := h.TariffDB.FindAll(measurementType, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tariffs)
+}
+
+// Get tariff			godoc
+// @Summary      		Get a tariff
+// @Description  		Get a tariff
+// @Tags         		tariffs
+// @Accept       		json
+// @Produce      		json
+// @Param        		id				path		string	true	"tariff ID"	Format(uuid)
+// @Success      		200				{object}	entity.Tariff
+// @Failure      		400				{object}	Problem
+// @Failure      		404				{object}	Problem
+// @Failure      		500				{object}	Problem
+// @Router       		/tariffs/{id}	[get]
+// @Security 			ApiKeyAuth
+func (h *TariffHandler) GetTariff(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		writeProblem(w, r, invalidParameter("id"))
+		return
+	}
+	tariff, err := h.TariffDB.FindById(id, r.Context())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tariff)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -15,9 +16,7 @@ type UserHandler struct {
 	UserDB database.UserInterface
 }
 
-type Error struct {
-	Message string `json:"message"`
-}
+var errInvalidCredentials = &apiError{Status: http.StatusUnauthorized, Code: "invalid_credentials", Detail: "email or password invalid"}
 
 func NewUserHandler(userDB database.UserInterface) *UserHandler {
 	return &UserHandler{
@@ -33,32 +32,32 @@ func NewUserHandler(userDB database.UserInterface) *UserHandler {
 // @Produce    	json
 // @Param      	request				body		dto.GetTokenInput	true	"user credentials"
 // @Success    	200					{object}	dto.GetTokenOutput
-// @Failure    	400					{object}	Error
-// @Failure    	401					{object}  	Error
+// @Failure    	400					{object}	Problem
+// @Failure    	401					{object}  	Problem
+// @Failure    	422					{object}  	Problem
+// @Failure    	500					{object}  	Problem
 // @Router     	/users/token		[post]
 func (h *UserHandler) GetToken(w http.ResponseWriter, r *http.Request) {
 	jwt := r.Context().Value("token").(*jwtauth.JWTAuth)
 	jwtExpiresIn := r.Context().Value("token_expires_in").(int)
 
 	var login dto.GetTokenInput
-	err := json.NewDecoder(r.Body).Decode(&login)
+	err := decodeAndValidate(w, r, &login, maxBodySize)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
+		return
+	}
+	u, err := h.UserDB.FindByEmail(login.Email, r.Context())
+	if errors.Is(err, database.ErrNotFound) {
+		writeProblem(w, r, errInvalidCredentials)
 		return
 	}
-	u, err := h.UserDB.FindByEmail(login.Email)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		error := Error{Message: "email or password invalid"}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
 	if !u.ValidatePassword(login.Password) {
-		w.WriteHeader(http.StatusUnauthorized)
-		error := Error{Message: "email or password invalid"}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, errInvalidCredentials)
 		return
 	}
 	_, token, _ := jwt.Encode(map[string]interface{}{
@@ -79,30 +78,25 @@ func (h *UserHandler) GetToken(w http.ResponseWriter, r *http.Request) {
 // @Produce     json
 // @Param       request	body      dto.CreateUserInput	true	"user request"
 // @Success     201		{object}  entity.User
-// @Failure     400		{object}  Error
-// @Failure     500		{object}  Error
+// @Failure     400		{object}  Problem
+// @Failure     422		{object}  Problem
+// @Failure     500		{object}  Problem
 // @Router      /users	[post]
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var user dto.CreateUserInput
-	err := json.NewDecoder(r.Body).Decode(&user)
+	err := decodeAndValidate(w, r, &user, maxBodySize)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
 	u, err := entity.NewUser(user.Name, user.Email, user.Password)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
-	err = h.UserDB.Create(u)
+	err = h.UserDB.Create(u, r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		writeProblem(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/jwtauth"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/audit"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/infra/logger"
+)
+
+// Audit stores the authenticated actor, the request ID and the client IP in
+// the request context so repositories can attach them to audit events. It
+// must run after the JWT verifier and the RequestID middleware. The client
+// IP is the socket peer unless RealIP resolved it from a trusted proxy. The
+// actor is also attached to the request logger.
+func Audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metadata := audit.Metadata{
+			RequestID: middleware.GetReqID(r.Context()),
+			IP:        clientIP(r),
+		}
+		if _, claims, err := jwtauth.FromContext(r.Context()); err == nil {
+			if sub, ok := claims["sub"].(string); ok {
+				metadata.Actor = sub
+			}
+		}
+		ctx := audit.NewContext(r.Context(), metadata)
+		if metadata.Actor != "" {
+			ctx = logger.WithUser(ctx, metadata.Actor)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
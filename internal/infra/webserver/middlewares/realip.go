@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseProxies parses a comma separated list of IP addresses and CIDR
+// networks of trusted reverse proxies.
+func ParseProxies(value string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.Contains(field, "/") {
+			if ip := net.ParseIP(field); ip != nil && ip.To4() != nil {
+				field += "/32"
+			} else {
+				field += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(field)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// RealIP replaces the remote address of requests received from a trusted
+// proxy with the client address the proxies forwarded. X-Forwarded-For is
+// read from the right, skipping the trusted proxies that appended to it, so
+// a client cannot pick its address by sending the header itself. Requests
+// from any other peer keep the socket address and their forwarded headers
+// are ignored.
+func RealIP(proxies []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if trusted(proxies, clientIP(r)) {
+				if ip := forwardedIP(proxies, r.Header); ip != "" {
+					r.RemoteAddr = ip
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func forwardedIP(proxies []*net.IPNet, header http.Header) string {
+	var hops []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			return ""
+		}
+		if !trusted(proxies, hop) || i == 0 {
+			return hop
+		}
+	}
+	if ip := strings.TrimSpace(header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return ""
+}
+
+func trusted(proxies []*net.IPNet, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
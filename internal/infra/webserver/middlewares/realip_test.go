@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func remoteAddr(t *testing.T, proxies string, peer string, header http.Header) string {
+	networks, err := ParseProxies(proxies)
+	assert.NoError(t, err)
+	var seen string
+	handler := RealIP(networks)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.RemoteAddr
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = peer
+	r.Header = header
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	return seen
+}
+
+func TestParseProxies(t *testing.T) {
+	proxies, err := ParseProxies(" 10.0.0.0/8, 192.168.1.1 ,::1")
+	assert.NoError(t, err)
+	assert.Len(t, proxies, 3)
+	assert.Equal(t, "192.168.1.1/32", proxies[1].String())
+
+	_, err = ParseProxies("proxy")
+	assert.Error(t, err)
+}
+
+func TestRealIP(t *testing.T) {
+	forwarded := http.Header{"X-Forwarded-For": {"1.2.3.4, 203.0.113.7, 10.0.0.2"}}
+
+	// Without trusted proxies the socket peer is kept.
+	assert.Equal(t, "203.0.113.9:5000", remoteAddr(t, "", "203.0.113.9:5000", forwarded))
+	// A client that is not a trusted proxy cannot forge its address.
+	assert.Equal(t, "203.0.113.9:5000", remoteAddr(t, "10.0.0.0/8", "203.0.113.9:5000", forwarded))
+	// The rightmost untrusted hop is the client, not the leftmost one the
+	// client may have sent.
+	assert.Equal(t, "203.0.113.7", remoteAddr(t, "10.0.0.0/8", "10.0.0.1:5000", forwarded))
+	assert.Equal(t, "198.51.100.4", remoteAddr(t, "10.0.0.0/8", "10.0.0.1:5000", http.Header{"X-Real-Ip": {"198.51.100.4"}}))
+	assert.Equal(t, "10.0.0.1:5000", remoteAddr(t, "10.0.0.0/8", "10.0.0.1:5000", http.Header{"X-Forwarded-For": {"unknown"}}))
+}
@@ -0,0 +1,9 @@
+package worker
+
+import "context"
+
+type Task func(ctx context.Context)
+
+type PoolInterface interface {
+	Submit(task Task) error
+}
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrPoolClosed = errors.New("worker pool is closed")
+	ErrQueueFull  = errors.New("worker pool queue is full")
+)
+
+// Pool runs background tasks that must outlive the request that scheduled
+// them, such as compensating deletes, on a fixed number of workers.
+type Pool struct {
+	tasks  chan Task
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewPool(workers, queueSize int) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		tasks:  make(chan Task, queueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues task without blocking. Tasks receive a context that is only
+// cancelled when Shutdown gives up waiting for them.
+func (p *Pool) Submit(task Task) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+	select {
+	case p.tasks <- task:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Shutdown stops accepting tasks and waits for the queued ones to finish.
+// When ctx expires first the running tasks are cancelled and ctx's error is
+// returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for task := range p.tasks {
+		task(p.ctx)
+	}
+}
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolRunsSubmittedTasks(t *testing.T) {
+	pool := NewPool(2, 10)
+	var count atomic.Int32
+	for i := 0; i < 5; i++ {
+		err := pool.Submit(func(ctx context.Context) {
+			count.Add(1)
+		})
+		assert.Nil(t, err)
+	}
+
+	err := pool.Shutdown(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int32(5), count.Load())
+}
+
+func TestPoolRejectsTasksAfterShutdown(t *testing.T) {
+	pool := NewPool(1, 1)
+	assert.Nil(t, pool.Shutdown(context.Background()))
+
+	err := pool.Submit(func(ctx context.Context) {})
+	assert.Equal(t, ErrPoolClosed, err)
+}
+
+func TestPoolRejectsTasksWhenQueueIsFull(t *testing.T) {
+	pool := NewPool(1, 1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	assert.Nil(t, pool.Submit(func(ctx context.Context) {
+		close(started)
+		<-release
+	}))
+	<-started
+	assert.Nil(t, pool.Submit(func(ctx context.Context) {}))
+
+	err := pool.Submit(func(ctx context.Context) {})
+	assert.Equal(t, ErrQueueFull, err)
+
+	close(release)
+	assert.Nil(t, pool.Shutdown(context.Background()))
+}
+
+func TestPoolShutdownCancelsTasksAfterDeadline(t *testing.T) {
+	pool := NewPool(1, 1)
+	cancelled := make(chan struct{})
+	assert.Nil(t, pool.Submit(func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := pool.Shutdown(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	<-cancelled
+}
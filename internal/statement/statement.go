@@ -0,0 +1,261 @@
+// Package statement renders the printable monthly statement of a customer:
+// one page per billed meter with its readings, a 12 month consumption chart,
+// the charges of the invoice and the photo of the current reading.
+package statement
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/billing"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/melkzsiqueira/water-gas-measurement/pkg/pdf"
+)
+
+// HistoryMonths is how many periods the consumption chart covers, the
+// statement period included.
+const HistoryMonths = 12
+
+const (
+	margin       = 50.0
+	contentWidth = pdf.A4Width - 2*margin
+	right        = pdf.A4Width - margin
+	dateLayout   = "2006-01-02"
+)
+
+var (
+	gray      = pdf.Color{R: 110, G: 110, B: 110}
+	lightGray = pdf.Color{R: 225, G: 225, B: 225}
+	accent    = pdf.Color{R: 31, G: 94, B: 160}
+	light     = pdf.Color{R: 160, G: 190, B: 222}
+)
+
+var typeNames = map[string]string{
+	entity.MeasurementTypeWater: "Water",
+	entity.MeasurementTypeGas:   "Gas",
+}
+
+type Statement struct {
+	Name        string
+	Email       string
+	Period      billing.Period
+	Sections    []Section
+	GeneratedAt time.Time
+}
+
+// Section is the page of one measurement type. Previous and Current are the
+// readings the invoice was computed from and may be zero when they were
+// deleted since. Photo is the JPEG of the current reading and is left out of
+// the page when nil or unusable.
+type Section struct {
+	Invoice  entity.Invoice
+	Previous entity.Measurement
+	Current  entity.Measurement
+	History  []Usage
+	Photo    []byte
+}
+
+// Usage is the consumption billed in a period.
+type Usage struct {
+	Period      billing.Period
+	Consumption int
+	Billed      bool
+}
+
+// History returns the usage of the HistoryMonths periods ending in period,
+// oldest first, from the invoices of a single measurement type. Periods
+// without an invoice are reported as not billed.
+func History(period billing.Period, invoices []entity.Invoice) []Usage {
+	history := make([]Usage, HistoryMonths)
+	for i := range history {
+		history[i].Period = period.AddMonths(i - HistoryMonths + 1)
+	}
+	for _, invoice := range invoices {
+		for i := range history {
+			if history[i].Period.String() == invoice.Period {
+				history[i].Consumption = invoice.Consumption
+				history[i].Billed = true
+			}
+		}
+	}
+	return history
+}
+
+func Render(statement Statement, w io.Writer) error {
+	doc := pdf.New(pdf.A4Width, pdf.A4Height)
+	doc.SetTitle("Statement " + statement.Period.String())
+	for i, section := range statement.Sections {
+		page := doc.AddPage()
+		header(page, statement)
+		renderSection(doc, page, section)
+		footer(page, statement, i+1, len(statement.Sections))
+	}
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+func header(page *pdf.Page, statement Statement) {
+	page.Text(margin, 70, pdf.HelveticaBold, 18, pdf.Black, "Water and Gas Measurement")
+	page.Text(margin, 90, pdf.Helvetica, 11, gray, "Statement for "+statement.Period.Start().Format("January 2006"))
+	textRight(page, right, 70, pdf.HelveticaBold, 10, pdf.Black, statement.Name)
+	textRight(page, right, 84, pdf.Helvetica, 9, gray, statement.Email)
+	page.Line(margin, 105, right, 105, 0.5, gray)
+}
+
+func footer(page *pdf.Page, statement Statement, number, total int) {
+	page.Line(margin, 785, right, 785, 0.5, lightGray)
+	page.Text(margin, 800, pdf.Helvetica, 8, gray, "Generated on "+statement.GeneratedAt.UTC().Format("2006-01-02 15:04 UTC"))
+	textRight(page, right, 800, pdf.Helvetica, 8, gray, fmt.Sprintf("Page %d of %d", number, total))
+}
+
+func renderSection(doc *pdf.Document, page *pdf.Page, section Section) {
+	invoice := section.Invoice
+	page.Text(margin, 135, pdf.HelveticaBold, 14, accent, typeNames[invoice.Type])
+	page.Text(margin, 150, pdf.Helvetica, 8, gray, "Invoice "+invoice.ID.String())
+
+	rows := [][2]string{
+		{"Previous reading", reading(invoice.PreviousValue, section.Previous.CreatedAt)},
+		{"Current reading", reading(invoice.CurrentValue, section.Current.CreatedAt)},
+		{"Consumption", group(int64(invoice.Consumption)) + " m³"},
+		{"Amount due", money(invoice.Total, invoice.Currency)},
+	}
+	for i, row := range rows {
+		y := 178 + float64(i)*18
+		page.Text(margin, y, pdf.Helvetica, 10, gray, row[0])
+		page.Text(margin+110, y, pdf.HelveticaBold, 10, pdf.Black, row[1])
+	}
+
+	photo(doc, page, section)
+	chart(page, section.History)
+	charges(page, invoice)
+}
+
+// reading leaves the date out when the reading is no longer stored.
+func reading(value int, takenAt time.Time) string {
+	if takenAt.IsZero() {
+		return group(int64(value)) + " m³"
+	}
+	return fmt.Sprintf("%s m³ on %s", group(int64(value)), takenAt.UTC().Format(dateLayout))
+}
+
+// photo fits the reading photo in the top right corner of the section.
+func photo(doc *pdf.Document, page *pdf.Page, section Section) {
+	if len(section.Photo) == 0 {
+		return
+	}
+	img, err := doc.AddJPEG(section.Photo)
+	if err != nil || img.Width == 0 || img.Height == 0 {
+		return
+	}
+	const boxWidth, boxHeight, top = 150.0, 110.0, 125.0
+	scale := min(boxWidth/float64(img.Width), boxHeight/float64(img.Height))
+	w, h := float64(img.Width)*scale, float64(img.Height)*scale
+	page.Image(img, right-w, top, w, h)
+	textRight(page, right, top+h+12, pdf.Helvetica, 8, gray, "Meter photo, "+section.Current.CreatedAt.UTC().Format(dateLayout))
+}
+
+// chart draws one bar per period, highlighting the statement period.
+func chart(page *pdf.Page, history []Usage) {
+	const top, height, barWidth = 300.0, 120.0, 24.0
+	baseline := top + height + 15
+	page.Text(margin, top-15, pdf.HelveticaBold, 11, pdf.Black, "Consumption over the last 12 months (m³)")
+
+	highest := 1
+	for _, usage := range history {
+		highest = max(highest, usage.Consumption)
+	}
+	slot := contentWidth / float64(max(len(history), 1))
+	for i, usage := range history {
+		center := margin + slot*(float64(i)+0.5)
+		label := usage.Period.Start().Format("Jan 06")
+		textCenter(page, center, baseline+12, pdf.Helvetica, 7, gray, label)
+		if !usage.Billed {
+			continue
+		}
+		color := light
+		if i == len(history)-1 {
+			color = accent
+		}
+		h := height * float64(usage.Consumption) / float64(highest)
+		page.Rect(center-barWidth/2, baseline-h, barWidth, h, color)
+		textCenter(page, center, baseline-h-3, pdf.Helvetica, 7, pdf.Black, group(int64(usage.Consumption)))
+	}
+	page.Line(margin, baseline, right, baseline, 0.5, gray)
+}
+
+func charges(page *pdf.Page, invoice entity.Invoice) {
+	const top = 490.0
+	quantity, unitPrice := right-215, right-110
+	page.Text(margin, top, pdf.HelveticaBold, 11, pdf.Black, "Charges")
+
+	y := top + 22
+	page.Text(margin, y, pdf.HelveticaBold, 9, gray, "Description")
+	textRight(page, quantity, y, pdf.HelveticaBold, 9, gray, "Quantity")
+	textRight(page, unitPrice, y, pdf.HelveticaBold, 9, gray, "Unit price")
+	textRight(page, right, y, pdf.HelveticaBold, 9, gray, "Amount")
+	page.Line(margin, y+5, right, y+5, 0.5, gray)
+
+	for _, line := range invoice.Lines {
+		if line.Kind == entity.InvoiceLineTax {
+			continue
+		}
+		y += 16
+		page.Text(margin, y, pdf.Helvetica, 9, pdf.Black, line.Description)
+		textRight(page, quantity, y, pdf.Helvetica, 9, pdf.Black, group(int64(line.Quantity)))
+		textRight(page, unitPrice, y, pdf.Helvetica, 9, pdf.Black, money(line.UnitPrice, invoice.Currency))
+		textRight(page, right, y, pdf.Helvetica, 9, pdf.Black, money(line.Amount, invoice.Currency))
+	}
+	page.Line(margin, y+6, right, y+6, 0.5, lightGray)
+
+	y += 22
+	textRight(page, unitPrice, y, pdf.Helvetica, 9, gray, "Subtotal")
+	textRight(page, right, y, pdf.Helvetica, 9, pdf.Black, money(invoice.Subtotal, invoice.Currency))
+	for _, line := range invoice.Lines {
+		if line.Kind != entity.InvoiceLineTax {
+			continue
+		}
+		y += 16
+		textRight(page, unitPrice, y, pdf.Helvetica, 9, gray, line.Description)
+		textRight(page, right, y, pdf.Helvetica, 9, pdf.Black, money(line.Amount, invoice.Currency))
+	}
+	y += 20
+	page.Rect(unitPrice-60, y-14, right-unitPrice+65, 20, lightGray)
+	textRight(page, unitPrice, y, pdf.HelveticaBold, 10, pdf.Black, "Total")
+	textRight(page, right, y, pdf.HelveticaBold, 10, pdf.Black, money(invoice.Total, invoice.Currency))
+}
+
+func textRight(page *pdf.Page, x, y float64, font pdf.Font, size float64, color pdf.Color, text string) {
+	page.Text(x-pdf.TextWidth(font, size, text), y, font, size, color, text)
+}
+
+func textCenter(page *pdf.Page, x, y float64, font pdf.Font, size float64, color pdf.Color, text string) {
+	page.Text(x-pdf.TextWidth(font, size, text)/2, y, font, size, color, text)
+}
+
+// money formats an amount in cents, such as "BRL 1,234.50".
+func money(cents int64, currency string) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s %s%s.%02d", currency, sign, group(cents/100), cents%100)
+}
+
+// group formats n with comma separated thousands.
+func group(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	grouped := make([]byte, 0, len(digits)+len(digits)/3)
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped = append(grouped, ',')
+		}
+		grouped = append(grouped, digits[i])
+	}
+	return sign + string(grouped)
+}
@@ -0,0 +1,88 @@
+package statement
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"github.com/melkzsiqueira/water-gas-measurement/internal/billing"
+	"github.com/melkzsiqueira/water-gas-measurement/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+const user = "878ab991-20b0-41c3-9c78-849744e8312a"
+
+func newSection(t *testing.T, photo []byte) Section {
+	tariff, err := entity.NewTariff("Residential", "1", "BRL", 1500, 1250, []entity.TariffTier{{UpTo: 10, Price: 400}, {Price: 90000}}, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), nil)
+	assert.NoError(t, err)
+	previous, err := entity.NewImportedMeasurement(1100, "", "1", user, time.Date(2024, time.February, 27, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	current, err := entity.NewImportedMeasurement(1117, "", "1", user, time.Date(2024, time.March, 28, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	invoice, err := billing.Bill(billing.Period{Year: 2024, Month: time.March}, []entity.Measurement{*previous, *current}, []entity.Tariff{*tariff})
+	assert.NoError(t, err)
+	previousInvoice := *invoice
+	previousInvoice.Period = "2024-01"
+	previousInvoice.Consumption = 9
+	return Section{
+		Invoice:  *invoice,
+		Previous: *previous,
+		Current:  *current,
+		History:  History(billing.Period{Year: 2024, Month: time.March}, []entity.Invoice{previousInvoice, *invoice}),
+		Photo:    photo,
+	}
+}
+
+func TestHistory(t *testing.T) {
+	history := History(billing.Period{Year: 2024, Month: time.March}, []entity.Invoice{
+		{Period: "2024-03", Consumption: 17},
+		{Period: "2023-04", Consumption: 11},
+		{Period: "2023-03", Consumption: 30},
+	})
+
+	assert.Len(t, history, HistoryMonths)
+	assert.Equal(t, "2023-04", history[0].Period.String())
+	assert.Equal(t, Usage{Period: billing.Period{Year: 2023, Month: time.April}, Consumption: 11, Billed: true}, history[0])
+	assert.False(t, history[1].Billed)
+	assert.Equal(t, 17, history[11].Consumption)
+}
+
+func TestRender(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	img.Set(1, 1, color.RGBA{200, 10, 10, 255})
+	var photo bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&photo, img, nil))
+
+	var out bytes.Buffer
+	err := Render(Statement{
+		Name:        "John Doe",
+		Email:       "john@doe.com",
+		Period:      billing.Period{Year: 2024, Month: time.March},
+		Sections:    []Section{newSection(t, photo.Bytes()), newSection(t, []byte("not a jpeg"))},
+		GeneratedAt: time.Date(2024, time.April, 2, 9, 30, 0, 0, time.UTC),
+	}, &out)
+
+	assert.NoError(t, err)
+	pdf := out.String()
+	assert.Contains(t, pdf, "/Count 2")
+	assert.Contains(t, pdf, "(Statement for March 2024)")
+	assert.Contains(t, pdf, "(John Doe)")
+	assert.Contains(t, pdf, "(1,117 m\xb3 on 2024-03-28)")
+	assert.Contains(t, pdf, "(Mar 24)")
+	assert.Contains(t, pdf, "(BRL 6,355.00)")
+	assert.Contains(t, pdf, "(Tax 12.50%)")
+	assert.Contains(t, pdf, "(Page 2 of 2)")
+	assert.Contains(t, pdf, "/Im1 Do")
+	assert.NotContains(t, pdf, "/Im2")
+}
+
+func TestMoney(t *testing.T) {
+	assert.Equal(t, "BRL 0.05", money(5, "BRL"))
+	assert.Equal(t, "USD 1,234,567.89", money(123456789, "USD"))
+	assert.Equal(t, "EUR -12.30", money(-1230, "EUR"))
+	assert.Equal(t, "999", group(999))
+	assert.Equal(t, "-1,000", group(-1000))
+}
@@ -0,0 +1,86 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*Breaker, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := New(threshold, cooldown)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	b, _ := newTestBreaker(3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, b.Allow())
+		b.Failure()
+	}
+	assert.Equal(t, Closed, b.State())
+
+	assert.Nil(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, Open, b.State())
+	assert.Equal(t, ErrOpen, b.Allow())
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b, _ := newTestBreaker(2, time.Minute)
+
+	assert.Nil(t, b.Allow())
+	b.Failure()
+	assert.Nil(t, b.Allow())
+	b.Success()
+	assert.Nil(t, b.Allow())
+	b.Failure()
+
+	assert.Equal(t, Closed, b.State())
+}
+
+func TestBreakerHalfOpenAllowsSingleProbe(t *testing.T) {
+	b, now := newTestBreaker(1, time.Minute)
+	assert.Nil(t, b.Allow())
+	b.Failure()
+
+	*now = now.Add(time.Minute)
+	assert.Nil(t, b.Allow())
+	assert.Equal(t, HalfOpen, b.State())
+	assert.Equal(t, ErrOpen, b.Allow())
+
+	b.Success()
+	assert.Equal(t, Closed, b.State())
+	assert.Nil(t, b.Allow())
+}
+
+func TestBreakerFailedProbeReopens(t *testing.T) {
+	b, now := newTestBreaker(3, time.Minute)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, b.Allow())
+		b.Failure()
+	}
+
+	*now = now.Add(time.Minute)
+	assert.Nil(t, b.Allow())
+	b.Failure()
+
+	assert.Equal(t, Open, b.State())
+	assert.Equal(t, ErrOpen, b.Allow())
+	*now = now.Add(59 * time.Second)
+	assert.Equal(t, ErrOpen, b.Allow())
+}
+
+func TestBreakerAbandonsStaleProbe(t *testing.T) {
+	b, now := newTestBreaker(1, time.Minute)
+	assert.Nil(t, b.Allow())
+	b.Failure()
+
+	*now = now.Add(time.Minute)
+	assert.Nil(t, b.Allow())
+	*now = now.Add(time.Minute)
+	assert.Nil(t, b.Allow())
+}
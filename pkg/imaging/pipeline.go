@@ -0,0 +1,118 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+
+	defaultQuality = 85
+	// defaultMaxPixels is 40 megapixels, more than any phone camera takes.
+	defaultMaxPixels = 40_000_000
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrInvalidOutput     = errors.New("invalid output format")
+	ErrImageTooLarge     = errors.New("image has too many pixels")
+)
+
+// Options configures a Pipeline. WebP can be decoded but not encoded, since
+// there is no pure Go WebP encoder, so Format is either FormatJPEG or
+// FormatPNG. MaxPixels bounds the size of the images decoded, since a small
+// file can declare dimensions that take gigabytes to decode.
+type Options struct {
+	MaxDimension int
+	MaxPixels    int
+	Format       string
+	Quality      int
+	Normalize    bool
+}
+
+type Result struct {
+	Data   []byte
+	Mime   string
+	Width  int
+	Height int
+	// PHash is the DHash of the normalized image. Zero is a valid hash.
+	PHash uint64
+}
+
+// Pipeline normalizes uploaded photos before OCR and storage: it applies the
+// EXIF orientation, downscales, optionally stretches contrast and re-encodes
+// the image. Re-encoding drops every metadata segment, including GPS tags.
+type Pipeline struct {
+	options Options
+}
+
+func NewPipeline(options Options) (*Pipeline, error) {
+	if options.Format == "" {
+		options.Format = FormatJPEG
+	}
+	if options.Format != FormatJPEG && options.Format != FormatPNG {
+		return nil, ErrInvalidOutput
+	}
+	if options.Quality <= 0 || options.Quality > 100 {
+		options.Quality = defaultQuality
+	}
+	if options.MaxPixels <= 0 {
+		options.MaxPixels = defaultMaxPixels
+	}
+	return &Pipeline{options: options}, nil
+}
+
+// MaxPixels returns the largest number of pixels Process decodes.
+func (p *Pipeline) MaxPixels() int {
+	return p.options.MaxPixels
+}
+
+// Process decodes data, which may be JPEG, PNG or WebP, and returns the
+// normalized image. Formats the standard library cannot decode, such as
+// HEIC, return ErrUnsupportedFormat. Images with more than MaxPixels pixels
+// return ErrImageTooLarge before their pixels are decoded.
+func (p *Pipeline) Process(data []byte) (Result, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return Result{}, ErrUnsupportedFormat
+		}
+		return Result{}, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > p.options.MaxPixels/config.Height {
+		return Result{}, ErrImageTooLarge
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Result{}, err
+	}
+	if format == "jpeg" {
+		img = Orient(img, Orientation(data))
+	}
+	img = Fit(img, p.options.MaxDimension)
+	if p.options.Normalize {
+		img = Stretch(img)
+	}
+
+	var buf bytes.Buffer
+	mime := "image/" + p.options.Format
+	switch p.options.Format {
+	case FormatPNG:
+		err = png.Encode(&buf, img)
+	default:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: p.options.Quality})
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	b := img.Bounds()
+	return Result{Data: buf.Bytes(), Mime: mime, Width: b.Dx(), Height: b.Dy(), PHash: DHash(img)}, nil
+}
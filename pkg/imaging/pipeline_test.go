@@ -0,0 +1,183 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(100 + x%50), uint8(100 + y%50), 120, 255})
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	assert.Nil(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+// withOrientation inserts an EXIF APP1 segment holding the orientation tag
+// right after the SOI marker.
+func withOrientation(data []byte, orientation uint16, order binary.ByteOrder) []byte {
+	tiff := make([]byte, 26)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], tagOrientation)
+	order.PutUint16(tiff[12:], typeShort)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+
+	segment := append(append([]byte{}, exifHeader...), tiff...)
+	app1 := []byte{0xFF, markerAPP1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func TestOrientation(t *testing.T) {
+	data := encodeJPEG(t, newImage(4, 2))
+	assert.Equal(t, 1, Orientation(data))
+	assert.Equal(t, 6, Orientation(withOrientation(data, 6, binary.BigEndian)))
+	assert.Equal(t, 3, Orientation(withOrientation(data, 3, binary.LittleEndian)))
+	assert.Equal(t, 1, Orientation(withOrientation(data, 9, binary.BigEndian)))
+	assert.Equal(t, 1, Orientation([]byte("not a jpeg")))
+}
+
+func TestOrient(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	red := color.RGBA{255, 0, 0, 255}
+	img.SetRGBA(0, 0, red)
+
+	rotated := Orient(img, 6).(*image.RGBA)
+	assert.Equal(t, image.Rect(0, 0, 2, 3), rotated.Bounds())
+	assert.Equal(t, red, rotated.RGBAAt(1, 0))
+
+	rotated = Orient(img, 8).(*image.RGBA)
+	assert.Equal(t, red, rotated.RGBAAt(0, 2))
+
+	rotated = Orient(img, 3).(*image.RGBA)
+	assert.Equal(t, red, rotated.RGBAAt(2, 1))
+
+	assert.Equal(t, image.Image(img), Orient(img, 1))
+}
+
+func TestFit(t *testing.T) {
+	assert.Equal(t, image.Rect(0, 0, 100, 50), Fit(newImage(400, 200), 100).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 50, 100), Fit(newImage(200, 400), 100).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 80, 40), Fit(newImage(80, 40), 100).Bounds())
+}
+
+func TestStretch(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			v := uint8(100 + x*5)
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+
+	stretched := Stretch(img).(*image.RGBA)
+	assert.Equal(t, uint8(0), stretched.RGBAAt(0, 0).R)
+	assert.Equal(t, uint8(255), stretched.RGBAAt(9, 0).R)
+}
+
+func TestPipelineProcess(t *testing.T) {
+	pipeline, err := NewPipeline(Options{MaxDimension: 100, Normalize: true})
+	assert.Nil(t, err)
+	data := withOrientation(encodeJPEG(t, newImage(400, 200)), 6, binary.BigEndian)
+
+	result, err := pipeline.Process(data)
+	assert.Nil(t, err)
+	assert.Equal(t, "image/jpeg", result.Mime)
+	assert.Equal(t, 50, result.Width)
+	assert.Equal(t, 100, result.Height)
+	assert.False(t, bytes.Contains(result.Data, exifHeader))
+	assert.Equal(t, 1, Orientation(result.Data))
+}
+
+func TestPipelineProcessEncodesPNG(t *testing.T) {
+	pipeline, err := NewPipeline(Options{Format: FormatPNG})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	assert.Nil(t, png.Encode(&buf, newImage(20, 10)))
+
+	result, err := pipeline.Process(buf.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, "image/png", result.Mime)
+	_, format, err := image.Decode(bytes.NewReader(result.Data))
+	assert.Nil(t, err)
+	assert.Equal(t, "png", format)
+}
+
+func TestPipelineProcessWithUnsupportedFormat(t *testing.T) {
+	pipeline, err := NewPipeline(Options{})
+	assert.Nil(t, err)
+
+	_, err = pipeline.Process([]byte("\x00\x00\x00\x18ftypheic"))
+	assert.Equal(t, ErrUnsupportedFormat, err)
+}
+
+func TestPipelineProcessWithTooManyPixels(t *testing.T) {
+	pipeline, err := NewPipeline(Options{})
+	assert.Nil(t, err)
+
+	// Only the header of a 50000x50000 PNG: the pixels are never decoded.
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], 50000)
+	binary.BigEndian.PutUint32(ihdr[8:], 50000)
+	ihdr[12], ihdr[13] = 8, 2
+	data := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d")
+	data = append(data, ihdr...)
+	data = binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+	_, err = pipeline.Process(data)
+	assert.Equal(t, ErrImageTooLarge, err)
+
+	pipeline, err = NewPipeline(Options{MaxPixels: 100})
+	assert.Nil(t, err)
+	_, err = pipeline.Process(encodeJPEG(t, newImage(20, 10)))
+	assert.Equal(t, ErrImageTooLarge, err)
+}
+
+func TestNewPipelineWithInvalidFormat(t *testing.T) {
+	_, err := NewPipeline(Options{Format: "webp"})
+	assert.Equal(t, ErrInvalidOutput, err)
+}
+
+func TestDHash(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			v := uint8(80 + float64(x) + 40*math.Sin(float64(x)/5+float64(y)/9))
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	hash := DHash(img)
+
+	assert.Equal(t, 0, Distance(hash, DHash(Fit(img, 32))))
+	assert.LessOrEqual(t, Distance(hash, DHash(Stretch(img))), 4)
+	assert.Greater(t, Distance(hash, DHash(Orient(img, 3))), 16)
+}
@@ -0,0 +1,156 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/bits"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Orient returns img rotated and flipped so that it displays upright for the
+// given EXIF orientation.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	src := toRGBA(img)
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, src.RGBAAt(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// Fit downscales img so that neither side exceeds maxDimension, keeping the
+// aspect ratio. Smaller images are returned unchanged.
+func Fit(img image.Image, maxDimension int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if maxDimension <= 0 || (w <= maxDimension && h <= maxDimension) {
+		return img
+	}
+	if w >= h {
+		h = max(1, h*maxDimension/w)
+		w = maxDimension
+	} else {
+		w = max(1, w*maxDimension/h)
+		h = maxDimension
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// Stretch normalizes contrast by mapping the 1st and 99th luminance
+// percentiles to black and white. The same mapping is applied to every
+// channel so colours do not shift.
+func Stretch(img image.Image) image.Image {
+	src := toRGBA(img)
+	b := src.Bounds()
+	total := b.Dx() * b.Dy()
+	if total == 0 {
+		return src
+	}
+
+	var histogram [256]int
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			histogram[luminance(src.RGBAAt(x, y))]++
+		}
+	}
+	low, high := percentile(histogram, total/100), percentile(histogram, total-total/100-1)
+	if high <= low {
+		return src
+	}
+
+	var lut [256]uint8
+	for i := range lut {
+		v := (i - low) * 255 / (high - low)
+		lut[i] = uint8(min(255, max(0, v)))
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := src.RGBAAt(x, y)
+			dst.SetRGBA(x-b.Min.X, y-b.Min.Y, color.RGBA{lut[c.R], lut[c.G], lut[c.B], c.A})
+		}
+	}
+	return dst
+}
+
+// percentile returns the smallest value whose cumulative count exceeds rank.
+func percentile(histogram [256]int, rank int) int {
+	count := 0
+	for value, n := range histogram {
+		count += n
+		if count > rank {
+			return value
+		}
+	}
+	return 255
+}
+
+func luminance(c color.RGBA) int {
+	return (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+	return dst
+}
+
+// DHash returns the 64 bit difference hash of img: each bit tells whether a
+// pixel of a 9x8 grayscale thumbnail is brighter than its right neighbour.
+// Re-encoded, resized or slightly retouched copies of a photo hash to values
+// a few bits apart.
+func DHash(img image.Image) uint64 {
+	small := image.NewRGBA(image.Rect(0, 0, 9, 8))
+	xdraw.BiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if luminance(small.RGBAAt(x, y)) > luminance(small.RGBAAt(x+1, y)) {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance returns the number of differing bits between two hashes.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
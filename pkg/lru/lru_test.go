@@ -0,0 +1,60 @@
+package lru
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCache(size int, ttl time.Duration) (*Cache[string, int], *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New[string, int](size, ttl)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestCacheGetAndAdd(t *testing.T) {
+	c, _ := newTestCache(2, 0)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	c.Add("a", 1)
+	c.Add("a", 2)
+	value, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c, _ := newTestCache(2, 0)
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a")
+	c.Add("c", 3)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestCacheExpiresEntries(t *testing.T) {
+	c, now := newTestCache(2, time.Minute)
+
+	c.Add("a", 1)
+	*now = now.Add(59 * time.Second)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	*now = now.Add(time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
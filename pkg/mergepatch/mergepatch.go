@@ -0,0 +1,43 @@
+package mergepatch
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidPatch = errors.New("invalid merge patch")
+
+// Apply applies a JSON Merge Patch (RFC 7386) to the original document and
+// returns the patched document.
+func Apply(original, patch []byte) ([]byte, error) {
+	var originalValue any
+	if len(original) > 0 {
+		if err := json.Unmarshal(original, &originalValue); err != nil {
+			return nil, err
+		}
+	}
+	var patchValue any
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, ErrInvalidPatch
+	}
+	return json.Marshal(merge(originalValue, patchValue))
+}
+
+func merge(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = merge(targetObject[key], value)
+	}
+	return targetObject
+}
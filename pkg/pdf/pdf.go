@@ -0,0 +1,271 @@
+// Package pdf writes simple PDF documents: text in the standard Helvetica
+// fonts, filled rectangles, lines and JPEG images. Coordinates are in points
+// with the origin at the top left corner of the page and y growing
+// downwards; the y of a text is its baseline.
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"strconv"
+)
+
+// A4 page size in points.
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+var ErrUnsupportedImage = errors.New("unsupported image")
+
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+var fontNames = []string{"Helvetica", "Helvetica-Bold"}
+
+type Color struct {
+	R, G, B uint8
+}
+
+var Black = Color{}
+
+type Document struct {
+	width  float64
+	height float64
+	title  string
+	pages  []*Page
+	images []*Image
+}
+
+// Image is a JPEG added to a document. It can be drawn on any of its pages.
+type Image struct {
+	Width      int
+	Height     int
+	name       string
+	colorSpace string
+	data       []byte
+}
+
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+}
+
+func New(width, height float64) *Document {
+	return &Document{width: width, height: height}
+}
+
+func (d *Document) SetTitle(title string) {
+	d.title = title
+}
+
+func (d *Document) AddPage() *Page {
+	page := &Page{doc: d}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// AddJPEG embeds a baseline or progressive JPEG as is. Only grayscale and
+// RGB images are supported.
+func (d *Document) AddJPEG(data []byte) (*Image, error) {
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	var colorSpace string
+	switch config.ColorModel {
+	case color.GrayModel:
+		colorSpace = "DeviceGray"
+	case color.YCbCrModel:
+		colorSpace = "DeviceRGB"
+	default:
+		return nil, ErrUnsupportedImage
+	}
+	img := &Image{
+		Width:      config.Width,
+		Height:     config.Height,
+		name:       "Im" + strconv.Itoa(len(d.images)+1),
+		colorSpace: colorSpace,
+		data:       data,
+	}
+	d.images = append(d.images, img)
+	return img, nil
+}
+
+// AddImage encodes img as a JPEG and embeds it.
+func (d *Document) AddImage(img image.Image) (*Image, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return d.AddJPEG(buf.Bytes())
+}
+
+// Text draws text with its baseline at y. Characters outside Windows-1252
+// are replaced by a question mark.
+func (p *Page) Text(x, y float64, font Font, size float64, c Color, text string) {
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s rg %s %s Td (%s) Tj ET\n",
+		font+1, number(size), rgb(c), number(x), number(p.doc.height-y), escape(encode(text)))
+}
+
+// Rect fills the rectangle whose top left corner is at x, y.
+func (p *Page) Rect(x, y, w, h float64, c Color) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n",
+		rgb(c), number(x), number(p.doc.height-y-h), number(w), number(h))
+}
+
+func (p *Page) Line(x1, y1, x2, y2, width float64, c Color) {
+	fmt.Fprintf(&p.content, "%s w %s RG %s %s m %s %s l S\n",
+		number(width), rgb(c), number(x1), number(p.doc.height-y1), number(x2), number(p.doc.height-y2))
+}
+
+// Image draws img scaled to w by h with its top left corner at x, y.
+func (p *Page) Image(img *Image, x, y, w, h float64) {
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /%s Do Q\n",
+		number(w), number(h), number(x), number(p.doc.height-y-h), img.name)
+}
+
+// WriteTo writes the document. Pages, fonts and images are numbered in a
+// fixed order so the same document always produces the same bytes.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	out := &writer{w: bufio.NewWriter(w)}
+
+	const (
+		catalogObject = 1
+		pagesObject   = 2
+		infoObject    = 3
+		fontObject    = 4
+	)
+	imageObject := fontObject + len(fontNames)
+	pageObject := imageObject + len(d.images)
+	objects := pageObject + 2*len(d.pages) - 1
+
+	out.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	out.object(catalogObject, "<< /Type /Catalog /Pages %d 0 R >>", pagesObject)
+
+	kids := make([]byte, 0, 8*len(d.pages))
+	for i := range d.pages {
+		kids = fmt.Appendf(kids, "%d 0 R ", pageObject+2*i)
+	}
+	out.object(pagesObject, "<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %s %s] >>",
+		bytes.TrimSpace(kids), len(d.pages), number(d.width), number(d.height))
+	out.object(infoObject, "<< /Title (%s) /Producer (water-gas-measurement) >>", escape(encode(d.title)))
+
+	for i, name := range fontNames {
+		out.object(fontObject+i, "<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name)
+	}
+	for i, img := range d.images {
+		out.stream(imageObject+i, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /DCTDecode",
+			img.Width, img.Height, img.colorSpace), img.data)
+	}
+
+	fonts := make([]byte, 0, 32)
+	for i := range fontNames {
+		fonts = fmt.Appendf(fonts, "/F%d %d 0 R ", i+1, fontObject+i)
+	}
+	xobjects := make([]byte, 0, 16*len(d.images))
+	for i, img := range d.images {
+		xobjects = fmt.Appendf(xobjects, "/%s %d 0 R ", img.name, imageObject+i)
+	}
+	resources := fmt.Sprintf("<< /Font << %s>> /XObject << %s>> >>", fonts, xobjects)
+	for i, page := range d.pages {
+		object := pageObject + 2*i
+		out.object(object, "<< /Type /Page /Parent %d 0 R /Resources %s /Contents %d 0 R >>", pagesObject, resources, object+1)
+		out.stream(object+1, "", page.content.Bytes())
+	}
+
+	xref := out.n
+	out.printf("xref\n0 %d\n0000000000 65535 f \n", objects+1)
+	for i := 1; i <= objects; i++ {
+		out.printf("%010d 00000 n \n", out.offsets[i])
+	}
+	out.printf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", objects+1, catalogObject, infoObject, xref)
+
+	if out.err == nil {
+		out.err = out.w.Flush()
+	}
+	return out.n, out.err
+}
+
+// writer keeps the offset of every object for the cross-reference table
+// and the first error, so callers check it once.
+type writer struct {
+	w       *bufio.Writer
+	n       int64
+	offsets map[int]int64
+	err     error
+}
+
+func (w *writer) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
+
+func (w *writer) write(data []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(data)
+	w.n += int64(n)
+	w.err = err
+}
+
+func (w *writer) object(number int, format string, args ...any) {
+	w.begin(number)
+	w.printf(format, args...)
+	w.printf("\nendobj\n")
+}
+
+func (w *writer) stream(number int, dict string, data []byte) {
+	w.begin(number)
+	if dict != "" {
+		dict += " "
+	}
+	w.printf("<< %s/Length %d >>\nstream\n", dict, len(data))
+	w.write(data)
+	w.printf("\nendstream\nendobj\n")
+}
+
+func (w *writer) begin(number int) {
+	if w.offsets == nil {
+		w.offsets = make(map[int]int64)
+	}
+	w.offsets[number] = w.n
+	w.printf("%d 0 obj\n", number)
+}
+
+// number formats v with at most two decimals, which is well below what a
+// printer can resolve.
+func number(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func rgb(c Color) string {
+	return number(float64(c.R)/255) + " " + number(float64(c.G)/255) + " " + number(float64(c.B)/255)
+}
+
+func escape(text []byte) []byte {
+	escaped := make([]byte, 0, len(text))
+	for _, b := range text {
+		if b == '(' || b == ')' || b == '\\' {
+			escaped = append(escaped, '\\')
+		}
+		escaped = append(escaped, b)
+	}
+	return escaped
+}
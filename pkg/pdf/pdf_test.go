@@ -0,0 +1,91 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	assert.Nil(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+func render(t *testing.T, doc *Document) []byte {
+	var buf bytes.Buffer
+	n, err := doc.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	return buf.Bytes()
+}
+
+func TestWriteTo(t *testing.T) {
+	doc := New(A4Width, A4Height)
+	doc.SetTitle("Statement (2024-03)")
+	page := doc.AddPage()
+	page.Text(50, 60, HelveticaBold, 18, Black, "Água: 12 m³ (€ 3,50) \\ ok")
+	page.Rect(50, 100, 200, 20, Color{200, 200, 200})
+	page.Line(50, 130, 250, 130, 0.5, Black)
+	img, err := doc.AddJPEG(newJPEG(t, image.NewRGBA(image.Rect(0, 0, 4, 3))))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, img.Width)
+	page.Image(img, 300, 100, 40, 30)
+	doc.AddPage().Text(50, 60, Helvetica, 10, Black, "second page")
+
+	out := render(t, doc)
+	assert.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(out, []byte("%%EOF\n")))
+	assert.Contains(t, string(out), "/Count 2")
+	assert.Contains(t, string(out), "/Title (Statement \\(2024-03\\))")
+	assert.Contains(t, string(out), "BT /F2 18 Tf 0 0 0 rg 50 781.89 Td (\xc1gua: 12 m\xb3 \\(\x80 3,50\\) \\\\ ok) Tj ET")
+	assert.Contains(t, string(out), "0.78 0.78 0.78 rg 50 721.89 200 20 re f")
+	assert.Contains(t, string(out), "q 40 0 0 30 300 711.89 cm /Im1 Do Q")
+	assert.Contains(t, string(out), "/Width 4 /Height 3 /ColorSpace /DeviceRGB")
+
+	// Every cross-reference entry points at the object it numbers.
+	xref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	start, err := strconv.Atoi(string(xref[1]))
+	assert.Nil(t, err)
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(out[start:], -1)
+	assert.Len(t, entries, 10)
+	for i, entry := range entries {
+		offset, err := strconv.Atoi(string(entry[1]))
+		assert.Nil(t, err)
+		assert.True(t, bytes.HasPrefix(out[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+
+	assert.Equal(t, out, render(t, doc))
+}
+
+func TestAddJPEG(t *testing.T) {
+	doc := New(A4Width, A4Height)
+
+	gray := image.NewGray(image.Rect(0, 0, 2, 2))
+	gray.SetGray(0, 0, color.Gray{Y: 200})
+	img, err := doc.AddJPEG(newJPEG(t, gray))
+	assert.Nil(t, err)
+	assert.Equal(t, "DeviceGray", img.colorSpace)
+
+	_, err = doc.AddJPEG([]byte("\x89PNG\r\n\x1a\n"))
+	assert.Equal(t, ErrUnsupportedImage, err)
+
+	img, err = doc.AddImage(image.NewRGBA(image.Rect(0, 0, 8, 6)))
+	assert.Nil(t, err)
+	assert.Equal(t, "Im2", img.name)
+	assert.Equal(t, 6, img.Height)
+}
+
+func TestTextWidth(t *testing.T) {
+	assert.Equal(t, 5.56, TextWidth(Helvetica, 10, "0"))
+	assert.InDelta(t, 22.23, TextWidth(Helvetica, 10, "Total"), 0.001)
+	assert.InDelta(t, 23.89, TextWidth(HelveticaBold, 10, "Total"), 0.001)
+	assert.Equal(t, 0.0, TextWidth(Helvetica, 10, ""))
+}
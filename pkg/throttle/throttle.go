@@ -0,0 +1,36 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle lets an action run at most once per interval, such as pruning
+// expired rows after writes. It is safe for concurrent use.
+type Throttle struct {
+	interval time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func New(interval time.Duration) *Throttle {
+	return &Throttle{
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Allow reports whether the action may run now. The first call is always
+// allowed and each allowed call starts a new interval.
+func (t *Throttle) Allow() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	if !t.last.IsZero() && now.Sub(t.last) < t.interval {
+		return false
+	}
+	t.last = now
+	return true
+}
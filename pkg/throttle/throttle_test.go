@@ -0,0 +1,24 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottleAllowsOncePerInterval(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttle := New(10 * time.Minute)
+	throttle.now = func() time.Time { return now }
+
+	assert.True(t, throttle.Allow())
+	assert.False(t, throttle.Allow())
+
+	now = now.Add(9 * time.Minute)
+	assert.False(t, throttle.Allow())
+
+	now = now.Add(time.Minute)
+	assert.True(t, throttle.Allow())
+	assert.False(t, throttle.Allow())
+}
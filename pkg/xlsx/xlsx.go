@@ -0,0 +1,196 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxSheetName = 31
+
+var (
+	ErrInvalidSheetName = errors.New("invalid sheet name")
+	ErrClosed           = errors.New("writer is closed")
+)
+
+// epoch is day zero of the spreadsheet date system. Serials from 1900-03-01
+// on match Excel, which wrongly counts 1900-02-29.
+var epoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// styleDate is the index of the date cell format in styles.xml.
+const styleDate = 1
+
+// Writer streams a workbook with a single worksheet. Rows are written to the
+// underlying writer as they come, so the size of a workbook is not bounded
+// by memory. Only the zip central directory is written by Close.
+type Writer struct {
+	zip    *zip.Writer
+	sheet  io.Writer
+	rows   int
+	closed bool
+}
+
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	if sheetName == "" || len(sheetName) > maxSheetName || strings.ContainsAny(sheetName, `[]:*?/\`) {
+		return nil, ErrInvalidSheetName
+	}
+	z := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", fmt.Sprintf(workbook, escape(sheetName))},
+		{"xl/_rels/workbook.xml.rels", workbookRels},
+		{"xl/styles.xml", styles},
+	}
+	for _, part := range parts {
+		f, err := z.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+	sheet, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, sheetHeader); err != nil {
+		return nil, err
+	}
+	return &Writer{zip: z, sheet: sheet}, nil
+}
+
+// WriteRow appends a row. Values may be strings, integers, floats, booleans,
+// time.Time or pointers to them; nil values leave the cell empty and any
+// other value is written with fmt.Sprint.
+func (w *Writer) WriteRow(values ...any) error {
+	if w.closed {
+		return ErrClosed
+	}
+	w.rows++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, w.rows)
+	for i, value := range values {
+		writeCell(&b, cellRef(i, w.rows), value)
+	}
+	b.WriteString("</row>")
+	_, err := io.WriteString(w.sheet, b.String())
+	return err
+}
+
+// Close finishes the worksheet and the zip archive. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return ErrClosed
+	}
+	w.closed = true
+	if _, err := io.WriteString(w.sheet, sheetFooter); err != nil {
+		return err
+	}
+	return w.zip.Close()
+}
+
+func writeCell(b *strings.Builder, ref string, value any) {
+	switch v := value.(type) {
+	case nil:
+	case *string:
+		if v != nil {
+			writeCell(b, ref, *v)
+		}
+	case *int:
+		if v != nil {
+			writeCell(b, ref, *v)
+		}
+	case *int64:
+		if v != nil {
+			writeCell(b, ref, *v)
+		}
+	case *float64:
+		if v != nil {
+			writeCell(b, ref, *v)
+		}
+	case *bool:
+		if v != nil {
+			writeCell(b, ref, *v)
+		}
+	case *time.Time:
+		if v != nil {
+			writeCell(b, ref, *v)
+		}
+	case string:
+		fmt.Fprintf(b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escape(v))
+	case int:
+		fmt.Fprintf(b, `<c r="%s"><v>%d</v></c>`, ref, v)
+	case int64:
+		fmt.Fprintf(b, `<c r="%s"><v>%d</v></c>`, ref, v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return
+		}
+		fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		n := 0
+		if v {
+			n = 1
+		}
+		fmt.Fprintf(b, `<c r="%s" t="b"><v>%d</v></c>`, ref, n)
+	case time.Time:
+		if v.IsZero() {
+			return
+		}
+		fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, styleDate, strconv.FormatFloat(Serial(v), 'f', -1, 64))
+	default:
+		writeCell(b, ref, fmt.Sprint(v))
+	}
+}
+
+// Serial returns the spreadsheet serial number of t's wall clock time.
+func Serial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return wall.Sub(epoch).Hours() / 24
+}
+
+// cellRef returns the A1 reference of a zero based column and a one based
+// row.
+func cellRef(column, row int) string {
+	var name []byte
+	for column >= 0 {
+		name = append([]byte{byte('A' + column%26)}, name...)
+		column = column/26 - 1
+	}
+	return string(name) + strconv.Itoa(row)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const contentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`
+
+const rootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+const workbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const workbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`
+
+const styles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts><fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs></styleSheet>`
+
+const sheetHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const sheetFooter = `</sheetData></worksheet>`
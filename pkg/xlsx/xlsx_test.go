@@ -0,0 +1,92 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Style  string `xml:"s,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+type worksheet struct {
+	Rows []struct {
+		Ref   string `xml:"r,attr"`
+		Cells []cell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readSheet(t *testing.T, data []byte) worksheet {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	var names []string
+	var sheet worksheet
+	for _, f := range r.File {
+		names = append(names, f.Name)
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		assert.NoError(t, err)
+		rc.Close()
+		// Every part must be well formed XML.
+		assert.NoError(t, xml.Unmarshal(content, new(struct{})), f.Name)
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			assert.NoError(t, xml.Unmarshal(content, &sheet))
+		}
+	}
+	assert.Contains(t, names, "[Content_Types].xml")
+	assert.Contains(t, names, "xl/workbook.xml")
+	return sheet
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Readings")
+	assert.NoError(t, err)
+	value := 12
+	var missing *int
+	assert.NoError(t, w.WriteRow("id", "value", "consumption", "confirmed", "created_at"))
+	assert.NoError(t, w.WriteRow("a<b & c", 19, &value, true, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)))
+	assert.NoError(t, w.WriteRow("x", 1.5, missing, false, nil))
+	assert.NoError(t, w.Close())
+	assert.Equal(t, ErrClosed, w.WriteRow("late"))
+
+	sheet := readSheet(t, buf.Bytes())
+	assert.Len(t, sheet.Rows, 3)
+	row := sheet.Rows[1].Cells
+	assert.Equal(t, "A2", row[0].Ref)
+	assert.Equal(t, "inlineStr", row[0].Type)
+	assert.Equal(t, "a<b & c", row[0].Inline)
+	assert.Equal(t, "19", row[1].Value)
+	assert.Equal(t, "12", row[2].Value)
+	assert.Equal(t, "b", row[3].Type)
+	assert.Equal(t, "1", row[3].Value)
+	assert.Equal(t, "1", row[4].Style)
+	assert.Equal(t, "45292.5", row[4].Value)
+	assert.Len(t, sheet.Rows[2].Cells, 3)
+}
+
+func TestNewWriterWithInvalidSheetName(t *testing.T) {
+	_, err := NewWriter(io.Discard, "a/b")
+	assert.Equal(t, ErrInvalidSheetName, err)
+	_, err = NewWriter(io.Discard, "")
+	assert.Equal(t, ErrInvalidSheetName, err)
+}
+
+func TestCellRef(t *testing.T) {
+	assert.Equal(t, "A1", cellRef(0, 1))
+	assert.Equal(t, "Z3", cellRef(25, 3))
+	assert.Equal(t, "AA3", cellRef(26, 3))
+	assert.Equal(t, "AZ1", cellRef(51, 1))
+	assert.Equal(t, "BA1", cellRef(52, 1))
+}